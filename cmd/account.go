@@ -3,9 +3,15 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"github.com/ente-io/cli/internal"
 	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/internal/bandwidth"
+	"github.com/ente-io/cli/internal/output"
 	"github.com/ente-io/cli/pkg/model"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"io"
+	"os"
 )
 
 // Define the 'account' command and its subcommands
@@ -20,7 +26,29 @@ var listAccCmd = &cobra.Command{
 	Short: "list configured accounts",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		recoverWithLog()
-		return ctrl.ListAccounts(context.Background())
+		accounts, err := ctrl.ListAccounts(context.Background())
+		if err != nil {
+			return err
+		}
+		return printResult(accounts, func(w io.Writer) error {
+			fmt.Fprintf(w, "Configured accounts: %d\n", len(accounts))
+			if len(accounts) == 0 {
+				return nil
+			}
+			table := output.NewTable(w, "EMAIL", "ID", "APP", "SERVER", "DESTINATION", "ENCRYPTED")
+			for _, acc := range accounts {
+				destination := acc.ExportDir
+				if acc.S3 != nil {
+					destination = fmt.Sprintf("s3://%s/%s/%s", acc.S3.Endpoint, acc.S3.Bucket, acc.S3.Prefix)
+				}
+				server := acc.Server
+				if server == "" {
+					server = "ente.io"
+				}
+				fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%t\n", acc.Email, acc.UserID, acc.App, server, destination, acc.Encrypted)
+			}
+			return table.Flush()
+		})
 	},
 }
 
@@ -28,28 +56,53 @@ var listAccCmd = &cobra.Command{
 var addAccCmd = &cobra.Command{
 	Use:   "add",
 	Short: "Add a new account",
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Add a new account of ente.io, or of a self-hosted server with --api-endpoint.
+Self-hosted servers serve the files from the API endpoint, unless --files-endpoint is set.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		recoverWithLog()
-		ctrl.AddAccount(context.Background())
+		var endpoints api.Endpoints
+		var err error
+		// the endpoints section of the config file sets the server of new accounts
+		if endpoints.API, err = api.ParseEndpoint(viper.GetString("endpoints.api")); err != nil {
+			return err
+		}
+		if endpoints.Files, err = api.ParseEndpoint(viper.GetString("endpoints.files")); err != nil {
+			return err
+		}
+		return ctrl.AddAccount(context.Background(), endpoints)
 	},
 }
 
 // Subcommand for 'account update'
 var updateAccCmd = &cobra.Command{
 	Use:   "update",
-	Short: "Update an existing account's export directory",
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Update an existing account's export directory or S3 bucket",
+	Long: `Update the export destination, encryption or bandwidth limit of an account.
+With --encrypt, the content of the exported files and the names of the files and albums are encrypted with a key
+derived from the passphrase. The names are encrypted one by one, the same name gives the same encrypted name, and
+the folder structure and the sizes of the files are visible. Names of more than about 170 bytes don't fit in the
+file name limit of most filesystems. Use 'decrypt-export' to restore the plain files with their names.
+Exports encrypted by earlier versions keep their names in plain text.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		recoverWithLog()
 		exportDir, _ := cmd.Flags().GetString("dir")
 		app, _ := cmd.Flags().GetString("app")
 		email, _ := cmd.Flags().GetString("email")
 		if email == "" {
-			fmt.Println("email must be specified")
-			return
+			return fmt.Errorf("email must be specified")
+		}
+		s3Bucket, _ := cmd.Flags().GetString("s3-bucket")
+		disableS3, _ := cmd.Flags().GetBool("s3-disable")
+		encrypt, _ := cmd.Flags().GetBool("encrypt")
+		noEncrypt, _ := cmd.Flags().GetBool("no-encrypt")
+		resetBandwidth, _ := cmd.Flags().GetBool("bandwidth-reset")
+		bandwidthChanged := cmd.Flags().Changed("max-bandwidth") || cmd.Flags().Changed("full-speed-window") || resetBandwidth
+		if exportDir == "" && s3Bucket == "" && !disableS3 && !encrypt && !noEncrypt && !bandwidthChanged {
+			return fmt.Errorf("nothing to update, specify one of --dir, --s3-bucket, --s3-disable, --encrypt, --no-encrypt, " +
+				"--max-bandwidth, --full-speed-window or --bandwidth-reset")
 		}
-		if exportDir == "" {
-			fmt.Println("dir param must be specified")
-			return
+		if encrypt && noEncrypt {
+			return fmt.Errorf("encrypt and no-encrypt can't be used together")
 		}
 
 		validApps := map[string]bool{
@@ -59,26 +112,120 @@ var updateAccCmd = &cobra.Command{
 		}
 
 		if !validApps[app] {
-			fmt.Printf("invalid app. Accepted values are 'photos', 'locker', 'auth'")
-
+			return fmt.Errorf("invalid app. Accepted values are 'photos', 'locker', 'auth'")
+		}
+		var maxBandwidth *int64
+		if cmd.Flags().Changed("max-bandwidth") {
+			value, _ := cmd.Flags().GetString("max-bandwidth")
+			bytesPerSec, err := bandwidth.ParseRate(value)
+			if err != nil {
+				return err
+			}
+			maxBandwidth = &bytesPerSec
+		}
+		cmd.SilenceUsage = true
+		var s3Params *model.S3Params
+		if s3Bucket != "" {
+			var err error
+			if s3Params, err = getS3Params(cmd); err != nil {
+				return fmt.Errorf("error reading s3 params: %w", err)
+			}
+		}
+		var passphrase *string
+		if encrypt {
+			value, err := getExportPassphrase(true)
+			if err != nil {
+				return fmt.Errorf("error reading passphrase: %w", err)
+			}
+			passphrase = &value
+		}
+		var fullSpeedWindows []string
+		if cmd.Flags().Changed("full-speed-window") {
+			values, _ := cmd.Flags().GetStringArray("full-speed-window")
+			// an empty value removes the windows
+			fullSpeedWindows = make([]string, 0, len(values))
+			for _, value := range values {
+				if value != "" {
+					fullSpeedWindows = append(fullSpeedWindows, value)
+				}
+			}
 		}
 		err := ctrl.UpdateAccount(context.Background(), model.UpdateAccountParams{
-			Email:     email,
-			App:       api.StringToApp(app),
-			ExportDir: &exportDir,
+			Email:             email,
+			App:               api.StringToApp(app),
+			API:               accountRef(cmd).API,
+			ExportDir:         &exportDir,
+			S3:                s3Params,
+			DisableS3:         disableS3,
+			ExportPassphrase:  passphrase,
+			DisableEncryption: noEncrypt,
+			MaxBandwidth:      maxBandwidth,
+			FullSpeedWindows:  fullSpeedWindows,
+			ResetBandwidth:    resetBandwidth,
 		})
 		if err != nil {
-			fmt.Printf("Error updating account: %v\n", err)
+			return fmt.Errorf("error updating account: %w", err)
 		}
+		return nil
 	},
 }
 
+// accountSelectUsage is the usage of the --api-endpoint flag of the commands which select an existing account
+const accountSelectUsage = "API endpoint or host of the account's server, needed when the email has accounts on several servers"
+
+// accountRef reads the --email and --api-endpoint flags, which select the account of a command
+func accountRef(cmd *cobra.Command) model.AccountRef {
+	email, _ := cmd.Flags().GetString("email")
+	server, _ := cmd.Flags().GetString("api-endpoint")
+	return model.AccountRef{Email: email, API: server}
+}
+
+// getS3Params reads the s3 settings from the flags. The secret key is read from the
+// ENTE_S3_SECRET_KEY environment variable or prompted, so that it doesn't end up in the shell history.
+func getS3Params(cmd *cobra.Command) (*model.S3Params, error) {
+	params := &model.S3Params{}
+	params.Bucket, _ = cmd.Flags().GetString("s3-bucket")
+	params.Endpoint, _ = cmd.Flags().GetString("s3-endpoint")
+	params.Region, _ = cmd.Flags().GetString("s3-region")
+	params.Prefix, _ = cmd.Flags().GetString("s3-prefix")
+	params.AccessKey, _ = cmd.Flags().GetString("s3-access-key")
+	params.Insecure, _ = cmd.Flags().GetBool("s3-insecure")
+	if params.Endpoint == "" || params.AccessKey == "" {
+		return nil, fmt.Errorf("s3-endpoint and s3-access-key must be specified")
+	}
+	params.SecretKey = os.Getenv("ENTE_S3_SECRET_KEY")
+	if params.SecretKey == "" {
+		secretKey, err := internal.GetSensitiveField("Enter S3 secret key")
+		fmt.Println()
+		if err != nil {
+			return nil, err
+		}
+		params.SecretKey = secretKey
+	}
+	return params, nil
+}
+
 func init() {
 	// Add 'config' subcommands to the root command
 	rootCmd.AddCommand(accountCmd)
 	// Add 'config' subcommands to the 'config' command
+	addAccCmd.Flags().String("api-endpoint", "", "API endpoint of a self-hosted server, e.g. https://museum.example.org")
+	addAccCmd.Flags().String("files-endpoint", "", "endpoint serving the file downloads, like https://files.ente.io")
 	updateAccCmd.Flags().String("dir", "", "update export directory")
 	updateAccCmd.Flags().String("email", "", "email address of the account to update")
 	updateAccCmd.Flags().String("app", "photos", "Specify the app, default is 'photos'")
+	updateAccCmd.Flags().String("api-endpoint", "", accountSelectUsage)
+	updateAccCmd.Flags().String("s3-endpoint", "", "export to an S3 compatible bucket at this endpoint, e.g. s3.amazonaws.com or localhost:9000")
+	updateAccCmd.Flags().String("s3-region", "", "region of the S3 bucket")
+	updateAccCmd.Flags().String("s3-bucket", "", "name of the S3 bucket")
+	updateAccCmd.Flags().String("s3-prefix", "", "prefix for the exported object keys")
+	updateAccCmd.Flags().String("s3-access-key", "", "access key for the S3 bucket, the secret key is read from ENTE_S3_SECRET_KEY or prompted")
+	updateAccCmd.Flags().Bool("s3-insecure", false, "use http instead of https for the S3 endpoint")
+	updateAccCmd.Flags().Bool("s3-disable", false, "stop exporting to S3 and use the export directory instead")
+	updateAccCmd.Flags().Bool("encrypt", false, "keep the exported files and their names encrypted with a passphrase, read from ENTE_EXPORT_PASSPHRASE or prompted")
+	updateAccCmd.Flags().Bool("no-encrypt", false, "export plain files again, requires a new export destination")
+	updateAccCmd.Flags().String("max-bandwidth", "", "limit the download speed of the account's export, e.g. 2MB, 0 for no limit")
+	updateAccCmd.Flags().StringArray("full-speed-window", nil, "daily time window without the speed limit, e.g. 00:00-06:00, can be repeated, empty to remove them")
+	updateAccCmd.Flags().Bool("bandwidth-reset", false, "remove the account's bandwidth settings, the ones of the export command apply again")
 	accountCmd.AddCommand(listAccCmd, addAccCmd, updateAccCmd)
 }
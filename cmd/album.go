@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ente-io/cli/internal/output"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/spf13/cobra"
+	"io"
+	"time"
+)
+
+// Define the 'album' command and its subcommands
+var albumCmd = &cobra.Command{
+	Use:   "album",
+	Short: "Manage albums of a photos account",
+}
+
+var listAlbumCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List albums",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		albums, err := ctrl.ListAlbums(context.Background(), account, refresh)
+		if err != nil {
+			return err
+		}
+		return printResult(albums, func(w io.Writer) error {
+			if len(albums) == 0 {
+				_, err := fmt.Fprintln(w, "No albums found, run `ente export` or use --refresh to sync albums")
+				return err
+			}
+			table := output.NewTable(w, "ID", "NAME", "OWNER", "SHARED", "FILES")
+			for _, album := range albums {
+				fmt.Fprintf(table, "%d\t%s\t%s\t%t\t%d\n", album.ID, album.Name, album.Owner, album.Shared, album.Files)
+			}
+			return table.Flush()
+		})
+	},
+}
+
+var showAlbumCmd = &cobra.Command{
+	Use:   "show <album>",
+	Short: "Show details of an album, identified by its ID or name",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		album, err := ctrl.ShowAlbum(context.Background(), account, args[0])
+		if err != nil {
+			return err
+		}
+		return printResult(album, func(w io.Writer) error {
+			return printAlbum(w, album)
+		})
+	},
+}
+
+var createAlbumCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a new album",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		album, err := ctrl.CreateAlbum(context.Background(), account, args[0])
+		if err != nil {
+			return err
+		}
+		return printResult(album, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Created album %s (%d)\n", album.Name, album.ID)
+			return err
+		})
+	},
+}
+
+var renameAlbumCmd = &cobra.Command{
+	Use:   "rename <album> <newName>",
+	Short: "Rename an album, identified by its ID or name",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		album, err := ctrl.RenameAlbum(context.Background(), account, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		return printResult(album, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Renamed album %s to %s\n", args[0], album.Name)
+			return err
+		})
+	},
+}
+
+var deleteAlbumCmd = &cobra.Command{
+	Use:   "delete <album>",
+	Short: "Delete an album, identified by its ID or name",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		keepFiles, _ := cmd.Flags().GetBool("keep-files")
+		album, err := ctrl.DeleteAlbum(context.Background(), account, args[0], keepFiles)
+		if err != nil {
+			return err
+		}
+		return printResult(album, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Deleted album %s (%d)\n", album.Name, album.ID)
+			return err
+		})
+	},
+}
+
+// printAlbum prints the details of an album for the table output
+func printAlbum(w io.Writer, album *model.AlbumSummary) error {
+	fmt.Fprintln(w, "Name:    ", album.Name)
+	fmt.Fprintln(w, "ID:      ", album.ID)
+	fmt.Fprintln(w, "Owner:   ", album.Owner)
+	fmt.Fprintln(w, "Shared:  ", album.Shared)
+	fmt.Fprintln(w, "Files:   ", album.Files)
+	fmt.Fprintln(w, "Updated: ", album.UpdatedAt.Format(time.RFC3339))
+	for _, sharee := range album.Sharees {
+		fmt.Fprintf(w, "Sharee:   %s (%s)\n", sharee.Email, sharee.Role)
+	}
+	for _, link := range album.Links {
+		fmt.Fprintln(w, "Link:    ", link.URL)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(albumCmd)
+	albumCmd.PersistentFlags().String("email", "", "email address of the account, optional if only one photos account is configured")
+	albumCmd.PersistentFlags().String("api-endpoint", "", accountSelectUsage)
+	listAlbumCmd.Flags().Bool("refresh", false, "sync albums and files from remote before listing")
+	deleteAlbumCmd.Flags().Bool("keep-files", true, "keep the files that are only present in this album by moving them to uncategorized")
+	albumCmd.AddCommand(listAlbumCmd, showAlbumCmd, createAlbumCmd, renameAlbumCmd, deleteAlbumCmd)
+}
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/spf13/cobra"
+	"io"
+	"strings"
+	"time"
+)
+
+var shareAlbumCmd = &cobra.Command{
+	Use:   "share <album> <email>",
+	Short: "Share an album with another ente user",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		role, _ := cmd.Flags().GetString("role")
+		album, err := ctrl.ShareAlbum(context.Background(), account, args[0], args[1], role)
+		if err != nil {
+			return err
+		}
+		return printResult(album, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Shared album %s with %s as %s\n", album.Name, args[1], strings.ToLower(role))
+			return err
+		})
+	},
+}
+
+var setRoleAlbumCmd = &cobra.Command{
+	Use:   "set-role <album> <email> <viewer|collaborator>",
+	Short: "Change the role of a user with whom the album is shared",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		album, err := ctrl.SetShareeRole(context.Background(), account, args[0], args[1], args[2])
+		if err != nil {
+			return err
+		}
+		return printResult(album, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Shared album %s with %s as %s\n", album.Name, args[1], strings.ToLower(args[2]))
+			return err
+		})
+	},
+}
+
+var unshareAlbumCmd = &cobra.Command{
+	Use:   "unshare <album> <email>",
+	Short: "Stop sharing an album with a user",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		album, err := ctrl.UnshareAlbum(context.Background(), account, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		return printResult(album, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Stopped sharing album %s with %s\n", album.Name, args[1])
+			return err
+		})
+	},
+}
+
+// Define the 'album link' command and its subcommands
+var linkAlbumCmd = &cobra.Command{
+	Use:   "link",
+	Short: "Manage public links of albums",
+}
+
+var createLinkCmd = &cobra.Command{
+	Use:   "create <album>",
+	Short: "Create a public link for an album",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		params, err := getPublicLinkParams(cmd)
+		if err != nil {
+			return err
+		}
+		link, err := ctrl.CreateAlbumLink(context.Background(), account, args[0], *params)
+		if err != nil {
+			return err
+		}
+		return printResult(link, func(w io.Writer) error {
+			return printPublicLink(w, link)
+		})
+	},
+}
+
+var updateLinkCmd = &cobra.Command{
+	Use:   "update <album>",
+	Short: "Update the expiry, password or download options of a public link",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		params, err := getPublicLinkParams(cmd)
+		if err != nil {
+			return err
+		}
+		link, err := ctrl.UpdateAlbumLink(context.Background(), account, args[0], *params)
+		if err != nil {
+			return err
+		}
+		return printResult(link, func(w io.Writer) error {
+			return printPublicLink(w, link)
+		})
+	},
+}
+
+var revokeLinkCmd = &cobra.Command{
+	Use:   "revoke <album>",
+	Short: "Revoke the public link of an album",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		album, err := ctrl.RevokeAlbumLink(context.Background(), account, args[0])
+		if err != nil {
+			return err
+		}
+		return printResult(album, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Revoked public link of album %s\n", album.Name)
+			return err
+		})
+	},
+}
+
+// printPublicLink prints the settings of a public link for the table output
+func printPublicLink(w io.Writer, link *model.PublicLink) error {
+	fmt.Fprintln(w, "Link:           ", link.URL)
+	if link.ExpiresAt != nil {
+		fmt.Fprintln(w, "Expires:        ", link.ExpiresAt.Format(time.RFC3339))
+	} else {
+		fmt.Fprintln(w, "Expires:         never")
+	}
+	if link.DeviceLimit > 0 {
+		fmt.Fprintln(w, "Device limit:   ", link.DeviceLimit)
+	}
+	fmt.Fprintln(w, "Download:       ", link.EnableDownload)
+	fmt.Fprintln(w, "Password:       ", link.PasswordEnabled)
+	return nil
+}
+
+// getPublicLinkParams reads the link options from the flags. Only the flags which are
+// explicitly set are included, so that updates leave the other options unchanged.
+func getPublicLinkParams(cmd *cobra.Command) (*model.PublicLinkParams, error) {
+	params := &model.PublicLinkParams{}
+	if cmd.Flags().Changed("expires-in") {
+		expiresIn, _ := cmd.Flags().GetDuration("expires-in")
+		var validTill int64
+		if expiresIn > 0 {
+			validTill = time.Now().Add(expiresIn).UnixMicro()
+		}
+		params.ValidTill = &validTill
+	}
+	if cmd.Flags().Changed("device-limit") {
+		deviceLimit, _ := cmd.Flags().GetInt("device-limit")
+		params.DeviceLimit = &deviceLimit
+	}
+	if cmd.Flags().Changed("allow-download") {
+		allowDownload, _ := cmd.Flags().GetBool("allow-download")
+		params.EnableDownload = &allowDownload
+	}
+	if cmd.Flags().Lookup("remove-password") != nil {
+		params.RemovePassword, _ = cmd.Flags().GetBool("remove-password")
+	}
+	if setPassword, _ := cmd.Flags().GetBool("password"); setPassword {
+		if params.RemovePassword {
+			return nil, fmt.Errorf("--password and --remove-password can not be used together")
+		}
+		password, err := internal.GetSensitiveField("Enter link password")
+		fmt.Println()
+		if err != nil {
+			return nil, err
+		}
+		if password == "" {
+			return nil, fmt.Errorf("password can not be empty")
+		}
+		params.Password = &password
+	}
+	return params, nil
+}
+
+func init() {
+	shareAlbumCmd.Flags().String("role", "viewer", "role of the user, 'viewer' or 'collaborator'")
+	for _, c := range []*cobra.Command{createLinkCmd, updateLinkCmd} {
+		c.Flags().Duration("expires-in", 0, "duration after which the link expires, e.g. 168h (0 means never)")
+		c.Flags().Int("device-limit", 0, "max number of devices that can open the link (0 means no limit)")
+		c.Flags().Bool("allow-download", true, "allow viewers to download the files")
+		c.Flags().Bool("password", false, "prompt for a password to protect the link")
+	}
+	updateLinkCmd.Flags().Bool("remove-password", false, "remove the password protection of the link")
+	linkAlbumCmd.AddCommand(createLinkCmd, updateLinkCmd, revokeLinkCmd)
+	albumCmd.AddCommand(shareAlbumCmd, setRoleAlbumCmd, unshareAlbumCmd, linkAlbumCmd)
+}
@@ -2,56 +2,317 @@ package cmd
 
 import (
 	"fmt"
+	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/internal/bandwidth"
+	"github.com/ente-io/cli/internal/config"
+	"github.com/ente-io/cli/internal/hooks"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/internal/output"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/notify"
+	"github.com/ente-io/cli/pkg/secrets"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"io"
+	"net/url"
+	"os"
+	"strings"
 )
 
-// Define the 'config' command and its subcommands
+// configFile is the path of the config file, set once the flags are parsed
+var configFile string
+
+// configKeys are the settings of the config file, bound to their flags once all the commands are defined
+var configKeys []config.Setting
+
+// configSettings are the settings of the config file. Each of them is the default of its flag, so that
+// commands read them from viper instead of their flags.
+func configSettings() []config.Setting {
+	root := rootCmd.PersistentFlags()
+	export := exportCmd.Flags()
+	return []config.Setting{
+		{Key: "output", Flag: root.Lookup("output"), Check: checkParse(output.ParseFormat)},
+		{Key: "log.level", Flag: root.Lookup("log-level"), Check: checkParse(logging.ParseLevel)},
+		{Key: "log.format", Flag: root.Lookup("log-format"), Check: checkLogFormat},
+		{Key: "log.file", Flag: root.Lookup("log-file")},
+		{Key: "http.proxy", Flag: root.Lookup("proxy"), Check: checkParse(api.ParseProxy)},
+		{Key: "http.ca-cert", Flag: root.Lookup("ca-cert")},
+		{Key: "http.client-cert", Flag: root.Lookup("client-cert")},
+		{Key: "http.client-key", Flag: root.Lookup("client-key")},
+		{Key: "http.connect-timeout", Flag: root.Lookup("connect-timeout")},
+		{Key: "http.timeout", Flag: root.Lookup("timeout")},
+		{Key: "secrets.backend", Flag: root.Lookup("secrets-backend"), Check: secrets.ValidateBackend},
+		{Key: "secrets.file", Flag: root.Lookup("secrets-file")},
+		{Key: "secrets.key-file", Flag: root.Lookup("secrets-key-file")},
+		{Key: "endpoints.api", Flag: addAccCmd.Flags().Lookup("api-endpoint"), Check: checkParse(api.ParseEndpoint)},
+		{Key: "endpoints.files", Flag: addAccCmd.Flags().Lookup("files-endpoint"), Check: checkParse(api.ParseEndpoint)},
+		{Key: "export.no-progress", Flag: export.Lookup("no-progress")},
+		{Key: "export.metrics-addr", Flag: export.Lookup("metrics-addr")},
+		{Key: "export.daemon", Flag: export.Lookup("daemon")},
+		{Key: "export.interval", Flag: export.Lookup("interval"), Check: checkParse(parseSchedule)},
+		{Key: "export.max-bandwidth", Flag: export.Lookup("max-bandwidth"), Check: checkParse(bandwidth.ParseRate)},
+		{Key: "export.full-speed-window", Flag: export.Lookup("full-speed-window"), Check: checkParse(bandwidth.ParseWindow)},
+		{Key: "hooks.url", Flag: export.Lookup("hook-url")},
+		{Key: "hooks.command", Flag: export.Lookup("hook-command")},
+		{Key: "hooks.events", Flag: export.Lookup("hook-events"), Check: checkParse(hooks.ParseEvents)},
+		{Key: "hooks.timeout", Flag: export.Lookup("hook-timeout")},
+		{Key: "notify.urls", Flag: export.Lookup("notify"), Check: checkParse(notify.Parse)},
+		{Key: "notify.on", Flag: export.Lookup("notify-on"), Check: checkNotifyOn},
+		{Key: "accounts", Decode: func(v *viper.Viper) error {
+			_, err := accountSettings(v)
+			return err
+		}},
+	}
+}
+
+func checkParse[T any](parse func(string) (T, error)) func(string) error {
+	return func(value string) error {
+		_, err := parse(value)
+		return err
+	}
+}
+
+func checkLogFormat(value string) error {
+	if value != logging.FormatText && value != logging.FormatJSON {
+		return fmt.Errorf("invalid log format %q, expected text or json", value)
+	}
+	return nil
+}
+
+func checkNotifyOn(value string) error {
+	if value != "always" && value != "failure" {
+		return fmt.Errorf("invalid notify.on %q, expected always or failure", value)
+	}
+	return nil
+}
+
+// accountConfig is an entry of the accounts section of the config file
+type accountConfig struct {
+	Email string `mapstructure:"email"`
+	App   string `mapstructure:"app"`
+	// APIEndpoint selects the server of the account, by its API endpoint or host
+	APIEndpoint      string   `mapstructure:"api-endpoint"`
+	Skip             bool     `mapstructure:"skip"`
+	MaxBandwidth     string   `mapstructure:"max-bandwidth"`
+	FullSpeedWindows []string `mapstructure:"full-speed-window"`
+}
+
+// accountSettings decodes the accounts section of the config file
+func accountSettings(v *viper.Viper) ([]model.AccountSettings, error) {
+	var entries []accountConfig
+	if err := v.UnmarshalKey("accounts", &entries, func(c *mapstructure.DecoderConfig) {
+		c.ErrorUnused = true
+	}); err != nil {
+		return nil, err
+	}
+	settings := make([]model.AccountSettings, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Email == "" {
+			return nil, fmt.Errorf("an account has no email")
+		}
+		account := model.AccountSettings{Email: entry.Email, App: api.AppPhotos, API: entry.APIEndpoint, Skip: entry.Skip}
+		switch entry.App {
+		case "", string(api.AppPhotos):
+		case string(api.AppAuth), string(api.AppLocker):
+			account.App = api.App(entry.App)
+		default:
+			return nil, fmt.Errorf("invalid app %q of account %s", entry.App, entry.Email)
+		}
+		if entry.MaxBandwidth == "" {
+			if len(entry.FullSpeedWindows) > 0 {
+				return nil, fmt.Errorf("full-speed-window of account %s requires max-bandwidth", entry.Email)
+			}
+		} else {
+			bytesPerSec, err := bandwidth.ParseRate(entry.MaxBandwidth)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %w", entry.Email, err)
+			}
+			for _, window := range entry.FullSpeedWindows {
+				if _, err := bandwidth.ParseWindow(window); err != nil {
+					return nil, fmt.Errorf("account %s: %w", entry.Email, err)
+				}
+			}
+			account.Bandwidth = &model.BandwidthConfig{MaxBytesPerSec: bytesPerSec, FullSpeedWindows: entry.FullSpeedWindows}
+		}
+		settings = append(settings, account)
+	}
+	return settings, nil
+}
+
+// loadConfig reads the config file selected by --config, $ENTE_CONFIG or found in the config directory.
+// The commands fail when it's invalid, rather than ignoring some of its settings.
+func loadConfig(cmd *cobra.Command, validate bool) error {
+	path, _ := cmd.Flags().GetString("config")
+	path, err := config.FindFile(path)
+	if err != nil {
+		return err
+	}
+	configFile = path
+	if validate {
+		file := viper.New()
+		if err := config.Load(file, path); err != nil {
+			return err
+		}
+		if problems := config.Validate(file, configKeys); len(problems) > 0 {
+			return fmt.Errorf("invalid config file %s: %w, see 'ente config validate'", path, problems[0])
+		}
+	}
+	return config.Load(viper.GetViper(), path)
+}
+
+// configEntry is a setting as shown by config show
+type configEntry struct {
+	Key    string      `json:"key" yaml:"key"`
+	Value  interface{} `json:"value" yaml:"value"`
+	Source string      `json:"source" yaml:"source"`
+}
+
+// redact hides the passwords of the urls in the value
+func redact(value interface{}) interface{} {
+	redactURL := func(s string) string {
+		if u, err := url.Parse(s); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+		return s
+	}
+	switch v := value.(type) {
+	case string:
+		return redactURL(v)
+	case []string:
+		redacted := make([]string, len(v))
+		for i, s := range v {
+			redacted[i] = redactURL(s)
+		}
+		return redacted
+	}
+	return value
+}
+
 var configCmd = &cobra.Command{
 	Use:   "config",
-	Short: "Manage configuration settings",
+	Short: "Show and edit the configuration",
+	Long: `The settings of the config file are the defaults of the flags of the same name, e.g. log.level of
+--log-level and export.max-bandwidth of the --max-bandwidth flag of export. A setting is taken from its flag when
+it's set, then from its environment variable, e.g. ENTE_LOG_LEVEL or ENTE_EXPORT_MAX_BANDWIDTH, then from the file.
+The file is the --config flag, $ENTE_CONFIG, or config.yaml, config.yml or config.toml in the ente folder of the
+user's config directory, e.g. ~/.config/ente/config.yaml on Linux. The accounts section holds per-account settings,
+api-endpoint is needed when the email has accounts on several servers:
+  accounts:
+    - email: me@example.org
+      app: photos
+      api-endpoint: https://museum.example.org
+      skip: false
+      max-bandwidth: 2MB
+      full-speed-window: ["00:00-06:00"]`,
+	PersistentPreRunE: setupConfigCommand,
 }
 
-// Subcommand for 'config show'
-var showCmd = &cobra.Command{
+// setupConfigCommand prepares the commands which edit the configuration. They must work with an invalid
+// config file, to fix it.
+func setupConfigCommand(cmd *cobra.Command, args []string) error {
+	if err := loadConfig(cmd, false); err != nil {
+		return err
+	}
+	return setupOutput()
+}
+
+var showConfigCmd = &cobra.Command{
 	Use:   "show",
-	Short: "Show configuration settings",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("host:", viper.GetString("host"))
+	Short: "Show the effective value of every setting and where it comes from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries := make([]configEntry, 0)
+		for _, s := range configKeys {
+			entries = append(entries, configEntry{
+				Key:    s.Key,
+				Value:  redact(config.Value(viper.GetViper(), s)),
+				Source: config.Source(viper.GetViper(), s),
+			})
+		}
+		return printResult(entries, func(w io.Writer) error {
+			if _, err := fmt.Fprintf(w, "Config file: %s\n", configFile); err != nil {
+				return err
+			}
+			table := output.NewTable(w, "KEY", "VALUE", "SOURCE")
+			for _, entry := range entries {
+				value := entry.Value
+				if value == nil {
+					value = ""
+				} else if entry.Key == "accounts" {
+					value = "(see the config file)"
+				}
+				fmt.Fprintf(table, "%s\t%v\t%s\n", entry.Key, value, entry.Source)
+			}
+			return table.Flush()
+		})
 	},
 }
 
-// Subcommand for 'config update'
-var updateCmd = &cobra.Command{
-	Use:   "update",
-	Short: "Update a configuration setting",
-	Run: func(cmd *cobra.Command, args []string) {
-		viper.Set("host", host)
-		err := viper.WriteConfig()
-		if err != nil {
-			fmt.Println("Error updating 'host' configuration:", err)
-			return
+var getConfigCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s, ok := config.Lookup(configKeys, args[0])
+		if !ok {
+			return fmt.Errorf("unknown setting %s, see 'ente config show'", args[0])
 		}
-		fmt.Println("Updating 'host' configuration:", host)
+		value := config.Value(viper.GetViper(), s)
+		return printResult(value, func(w io.Writer) error {
+			if values, ok := value.([]string); ok {
+				_, err := fmt.Fprintln(w, strings.Join(values, "\n"))
+				return err
+			}
+			_, err := fmt.Fprintln(w, value)
+			return err
+		})
 	},
 }
 
-// Flag to specify the 'host' configuration value
-var host string
-
-func init() {
-	// Set up Viper configuration
-	// Set a default value for 'host' configuration
-	viper.SetDefault("host", "https://api.ente.io")
-
-	// Add 'config' subcommands to the root command
-	//rootCmd.AddCommand(configCmd)
+var setConfigCmd = &cobra.Command{
+	Use:   "set <key> <value>...",
+	Short: "Write a setting to the config file",
+	Long: `Write a setting to the config file, creating it if needed. Lists take several values, e.g.
+  ente config set export.full-speed-window 00:00-06:00 22:00-24:00
+The value is validated first. The comments of the file aren't kept.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if err := config.Set(configFile, configKeys, args[0], args[1:]...); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Updated %s in %s\n", args[0], configFile)
+		return nil
+	},
+}
 
-	// Add flags to the 'config store' and 'config update' subcommands
-	updateCmd.Flags().StringVarP(&host, "host", "H", viper.GetString("host"), "Update the 'host' configuration")
-	// Mark 'host' flag as required for the 'update' command
-	updateCmd.MarkFlagRequired("host")
+var validateConfigCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for unknown settings and invalid values",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		if _, err := os.Stat(configFile); err != nil {
+			return fmt.Errorf("no config file at %s", configFile)
+		}
+		file := viper.New()
+		if err := config.Load(file, configFile); err != nil {
+			return err
+		}
+		problems := config.Validate(file, configKeys)
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%d problems in %s", len(problems), configFile)
+		}
+		fmt.Fprintf(os.Stderr, "%s is valid\n", configFile)
+		return nil
+	},
+}
 
-	// Add 'config' subcommands to the 'config' command
-	configCmd.AddCommand(showCmd, updateCmd)
+func init() {
+	configCmd.AddCommand(showConfigCmd, getConfigCmd, setConfigCmd, validateConfigCmd)
+	rootCmd.AddCommand(configCmd)
 }
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/pkg"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+var decryptExportCmd = &cobra.Command{
+	Use:   "decrypt-export <encryptedDir> <outputDir>",
+	Short: "Restore the plain files of an encrypted export",
+	Long: `Decrypt an export created with "account update --encrypt" into the output directory, with the original
+names of the files and albums.
+The passphrase is read from ENTE_EXPORT_PASSPHRASE or prompted. No account or network access is required.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		srcDir, err := internal.ResolvePath(args[0])
+		if err != nil {
+			return err
+		}
+		destDir, err := internal.ResolvePath(args[1])
+		if err != nil {
+			return err
+		}
+		passphrase, err := getExportPassphrase(false)
+		if err != nil {
+			return err
+		}
+		count, err := pkg.DecryptExport(context.Background(), srcDir, destDir, passphrase)
+		result := model.DecryptExportResult{Files: count}
+		if printErr := printResult(result, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Files decrypted: %d\n", result.Files)
+			return err
+		}); printErr != nil {
+			return printErr
+		}
+		return err
+	},
+}
+
+// getExportPassphrase returns the passphrase of an encrypted export from ENTE_EXPORT_PASSPHRASE,
+// or prompts for it. With confirm, the prompt asks for the passphrase twice.
+func getExportPassphrase(confirm bool) (string, error) {
+	return getPassphrase("ENTE_EXPORT_PASSPHRASE", "export", confirm)
+}
+
+// getPassphrase returns the passphrase from the environment variable, or prompts for it
+func getPassphrase(envName, name string, confirm bool) (string, error) {
+	if passphrase := os.Getenv(envName); passphrase != "" {
+		return passphrase, nil
+	}
+	passphrase, err := internal.GetSensitiveField(fmt.Sprintf("Enter %s passphrase", name))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the %s passphrase, it can be set in %s: %w", name, envName, err)
+	}
+	if passphrase == "" {
+		return "", errors.New("passphrase cannot be empty")
+	}
+	if confirm {
+		again, err := internal.GetSensitiveField(fmt.Sprintf("Confirm %s passphrase", name))
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+func init() {
+	rootCmd.AddCommand(decryptExportCmd)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"github.com/robfig/cron/v3"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 30, 0, 0, time.Local)
+	tests := []struct {
+		value   string
+		next    time.Time
+		wantErr string
+	}{
+		{"6h", start.Add(6 * time.Hour), ""},
+		{"1m", start.Add(time.Minute), ""},
+		{"30s", time.Time{}, "too short"},
+		{"0 3 * * *", time.Date(2024, 1, 2, 3, 0, 0, 0, time.Local), ""},
+		{"*/15 * * * *", time.Date(2024, 1, 1, 10, 45, 0, 0, time.Local), ""},
+		{"daily", time.Time{}, "invalid interval"},
+		{"0 3 * *", time.Time{}, "invalid interval"},
+		{"", time.Time{}, "invalid interval"},
+	}
+	for _, tt := range tests {
+		schedule, err := parseSchedule(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseSchedule(%q): expected an error with %q, got %v", tt.value, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSchedule(%q): %v", tt.value, err)
+		} else if next := schedule.Next(start); !next.Equal(tt.next) {
+			t.Errorf("parseSchedule(%q): next run at %v, want %v", tt.value, next, tt.next)
+		}
+	}
+}
+
+// scheduleFunc is a cron.Schedule returning the time of the next run from the time of the previous one
+type scheduleFunc func(time.Time) time.Time
+
+func (f scheduleFunc) Next(t time.Time) time.Time {
+	return f(t)
+}
+
+var _ cron.Schedule = scheduleFunc(nil)
+
+func TestExportDaemonStopsWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runs := 0
+	export := func(ctx context.Context) error {
+		runs++
+		if runs == 1 {
+			// the daemon keeps running after a failed export
+			return errors.New("server unavailable")
+		}
+		return nil
+	}
+	schedule := scheduleFunc(func(t time.Time) time.Time {
+		if runs == 1 {
+			return t
+		}
+		// the next export is in an hour, the daemon is interrupted while it waits for it
+		cancel()
+		return t.Add(time.Hour)
+	})
+	done := make(chan error, 1)
+	go func() { done <- runExportDaemon(ctx, schedule, export) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected the daemon to stop without an error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the daemon to stop once its context is cancelled")
+	}
+	if runs != 2 {
+		t.Errorf("expected 2 exports, got %d", runs)
+	}
+}
+
+func TestExportDaemonStopsDuringExport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	export := func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	}
+	schedule := scheduleFunc(func(t time.Time) time.Time { return t.Add(time.Hour) })
+	if err := runExportDaemon(ctx, schedule, export); !errors.Is(err, errInterrupted) {
+		t.Errorf("expected the daemon to be interrupted, got %v", err)
+	}
+}
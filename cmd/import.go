@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/spf13/cobra"
+	"io"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <exportDir>",
+	Short: "Upload an existing export directory to an account",
+	Long: `Upload the albums and files of an export directory created by this cli to an account.
+Albums are matched by name and created if missing. Files which already exist remotely are not uploaded again.
+Thumbnails of videos and of images in formats like HEIC are generated with ffmpeg. When it isn't installed,
+these files are uploaded with a plain grey thumbnail, which the apps keep showing for them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		email, _ := cmd.Flags().GetString("email")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if email == "" {
+			return fmt.Errorf("email must be specified")
+		}
+		exportDir, err := internal.ResolvePath(args[0])
+		if err != nil {
+			return err
+		}
+		result, err := ctrl.ImportExport(context.Background(), model.ImportParams{
+			Email:     email,
+			App:       api.AppPhotos,
+			API:       accountRef(cmd).API,
+			ExportDir: exportDir,
+			DryRun:    dryRun,
+		})
+		if result != nil {
+			if printErr := printResult(result, func(w io.Writer) error {
+				fmt.Fprintf(w, "Albums created: %d\n", result.AlbumsCreated)
+				fmt.Fprintf(w, "Files uploaded: %d\n", result.Uploaded)
+				fmt.Fprintf(w, "Files added to albums: %d\n", result.AddedToAlbum)
+				fmt.Fprintf(w, "Files skipped: %d\n", result.Skipped)
+				fmt.Fprintf(w, "Files failed: %d\n", result.Failed)
+				return nil
+			}); printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	},
+}
+
+func init() {
+	importCmd.Flags().String("email", "", "email address of the account to import into")
+	importCmd.Flags().String("api-endpoint", "", accountSelectUsage)
+	importCmd.Flags().Bool("dry-run", false, "only print the changes without uploading anything")
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/ente-io/cli/internal/metrics"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// startMetricsServer serves the metrics and the health check on addr, until the returned function is called
+func startMetricsServer(addr string) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	server := &http.Server{Handler: metrics.Handler(), ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics server failed", "error", err)
+		}
+	}()
+	slog.Info("serving metrics", "addr", listener.Addr().String())
+	return func() { _ = server.Close() }, nil
+}
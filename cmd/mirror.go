@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/pkg"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/spf13/cobra"
+	"io"
+)
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Keep a raw encrypted copy of an account for disaster recovery",
+}
+
+var mirrorSyncCmd = &cobra.Command{
+	Use:   "sync <mirrorDir>",
+	Short: "Update the encrypted mirror of an account",
+	Long: `Copy the encrypted files, the encrypted album and file records and the key attributes of the account
+to the mirror directory, without decrypting anything. Later runs only fetch the changes.
+Use "mirror decrypt" to restore the files from the mirror with just the account password.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		mirrorDir, err := internal.ResolvePath(args[0])
+		if err != nil {
+			return err
+		}
+		result, err := ctrl.MirrorAccount(context.Background(), model.MirrorParams{
+			Email:     account.Email,
+			App:       api.AppPhotos,
+			API:       account.API,
+			MirrorDir: mirrorDir,
+		})
+		if result != nil {
+			if printErr := printResult(result, func(w io.Writer) error {
+				fmt.Fprintf(w, "Collections updated: %d\n", result.Collections)
+				fmt.Fprintf(w, "File records updated: %d\n", result.FileRecords)
+				fmt.Fprintf(w, "Encrypted files downloaded: %d\n", result.BlobsDownloaded)
+				fmt.Fprintf(w, "Encrypted files removed: %d\n", result.BlobsRemoved)
+				return nil
+			}); printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	},
+}
+
+var mirrorDecryptCmd = &cobra.Command{
+	Use:   "decrypt <mirrorDir> <outputDir>",
+	Short: "Decrypt a mirror into a regular export, offline",
+	Long: `Decrypt the mirror into the output directory using only the account password.
+No configured account or access to the server is required. The output has the same layout as "export".`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		mirrorDir, err := internal.ResolvePath(args[0])
+		if err != nil {
+			return err
+		}
+		destDir, err := internal.ResolvePath(args[1])
+		if err != nil {
+			return err
+		}
+		password, err := internal.GetSensitiveField("Enter password")
+		fmt.Println()
+		if err != nil {
+			return err
+		}
+		result, err := pkg.DecryptMirror(context.Background(), mirrorDir, destDir, password)
+		if result != nil {
+			if printErr := printResult(result, func(w io.Writer) error {
+				fmt.Fprintf(w, "Albums: %d\n", result.Albums)
+				fmt.Fprintf(w, "Files decrypted: %d\n", result.Files)
+				fmt.Fprintf(w, "Files failed: %d\n", result.Failed)
+				return nil
+			}); printErr != nil {
+				return printErr
+			}
+		}
+		return err
+	},
+}
+
+func init() {
+	mirrorSyncCmd.Flags().String("email", "", "email address of the account to mirror, optional if only one photos account is configured")
+	mirrorSyncCmd.Flags().String("api-endpoint", "", accountSelectUsage)
+	mirrorCmd.AddCommand(mirrorSyncCmd, mirrorDecryptCmd)
+	rootCmd.AddCommand(mirrorCmd)
+}
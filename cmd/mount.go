@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/pkg/library"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+)
+
+var mountCmd = &cobra.Command{
+	Use:   "mount <mountpoint>",
+	Short: "Mount the photos library as a read-only filesystem",
+	Long: `Expose the albums of a photos account as directories at the mountpoint, without exporting them.
+Files are downloaded and decrypted when they are first read, and kept in a local cache.
+The album and file list comes from the last export or mount with --refresh. Requires FUSE (Linux or macOS).
+Stop with Ctrl+C to unmount.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		account := accountRef(cmd)
+		refresh, _ := cmd.Flags().GetBool("refresh")
+		cacheSize, _ := cmd.Flags().GetInt64("cache-size")
+		mountpoint, err := internal.ResolvePath(args[0])
+		if err != nil {
+			return err
+		}
+		cacheDir, err := getLibraryCacheDir(cmd)
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		lib, err := ctrl.OpenLibrary(ctx, account, refresh, library.Options{
+			CacheDir:  cacheDir,
+			CacheSize: cacheSize * 1024 * 1024,
+		})
+		if err != nil {
+			return err
+		}
+		defer lib.Close()
+		fmt.Fprintf(os.Stderr, "Mounted %d albums at %s, press Ctrl+C to unmount\n", len(lib.Albums()), mountpoint)
+		return library.Mount(ctx, lib, mountpoint)
+	},
+}
+
+// getLibraryCacheDir returns the --cache-dir flag, defaulting to a folder in the user's cache directory
+func getLibraryCacheDir(cmd *cobra.Command) (string, error) {
+	cacheDir, _ := cmd.Flags().GetString("cache-dir")
+	if cacheDir != "" {
+		return internal.ResolvePath(cacheDir)
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userCacheDir, "ente", "library"), nil
+}
+
+func init() {
+	mountCmd.Flags().String("email", "", "email address of the photos account, optional if only one is configured")
+	mountCmd.Flags().String("api-endpoint", "", accountSelectUsage)
+	mountCmd.Flags().Bool("refresh", false, "fetch the latest albums and files before mounting")
+	mountCmd.Flags().String("cache-dir", "", "directory for the decrypted files, defaults to the user's cache directory")
+	mountCmd.Flags().Int64("cache-size", 2048, "size of the cache in MB, least recently used files are evicted beyond it")
+	rootCmd.AddCommand(mountCmd)
+}
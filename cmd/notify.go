@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/notify"
+	"github.com/spf13/viper"
+	"log/slog"
+	"time"
+)
+
+// exportNotifier sends the summary of each export run to the notifiers configured by the flags
+type exportNotifier struct {
+	notifiers     []notify.Notifier
+	onlyOnFailure bool
+}
+
+// newExportNotifier returns the notifier for the notify settings, or nil if there are none
+func newExportNotifier() (*exportNotifier, error) {
+	urls := viper.GetStringSlice("notify.urls")
+	notifyOn := viper.GetString("notify.on")
+	if err := checkNotifyOn(notifyOn); err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	n := &exportNotifier{onlyOnFailure: notifyOn == "failure"}
+	for _, u := range urls {
+		notifier, err := notify.Parse(u)
+		if err != nil {
+			return nil, err
+		}
+		n.notifiers = append(n.notifiers, notifier)
+	}
+	return n, nil
+}
+
+// notify sends the summary of the export. Failures to send it are logged, they don't fail the export.
+func (n *exportNotifier) notify(ctx context.Context, result *model.ExportResult, exportErr error) {
+	if n == nil {
+		return
+	}
+	msg := notify.Summarize(result, exportErr)
+	if n.onlyOnFailure && !msg.Failed {
+		return
+	}
+	// the summary is also sent when the export was interrupted
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+	defer cancel()
+	for _, notifier := range n.notifiers {
+		if err := notifier.Notify(ctx, msg); err != nil {
+			slog.Warn("failed to send the export summary", "notifier", fmt.Sprintf("%T", notifier), "error", err)
+		}
+	}
+}
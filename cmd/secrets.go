@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal/config"
+	"github.com/ente-io/cli/pkg/secrets"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+)
+
+// deviceKeyPassphraseEnv holds the passphrase of the keyfile backend, it's prompted otherwise
+const deviceKeyPassphraseEnv = "ENTE_DEVICE_KEY_PASSPHRASE"
+
+// secretOptions returns the options of the device key store of the secrets settings
+func secretOptions() (secrets.Options, error) {
+	opts := secrets.Options{
+		Backend: viper.GetString("secrets.backend"),
+		DataDir: ctrl.DataDir,
+		Passphrase: func(confirm bool) (string, error) {
+			return getPassphrase(deviceKeyPassphraseEnv, "device key", confirm)
+		},
+	}
+	var err error
+	if opts.File, err = resolveFilePath(viper.GetString("secrets.file")); err != nil {
+		return opts, err
+	}
+	if opts.KeyFile, err = resolveFilePath(viper.GetString("secrets.key-file")); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// setupDeviceKey loads the device key from the store of the secrets settings, or creates it
+func setupDeviceKey() error {
+	opts, err := secretOptions()
+	if err != nil {
+		return err
+	}
+	store, err := secrets.NewStore(opts)
+	if err != nil {
+		return err
+	}
+	key, err := secrets.LoadOrCreate(store)
+	if err != nil {
+		return err
+	}
+	ctrl.KeyHolder = secrets.NewKeyHolder(key)
+	return nil
+}
+
+// needsDeviceKey reports whether the command uses the secrets of the accounts. The other commands
+// work without unlocking the device key, e.g. to fix its settings.
+func needsDeviceKey(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == configCmd || c == secretsCmd || c == versionCmd {
+			return false
+		}
+	}
+	return true
+}
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the device key which encrypts the secrets of the accounts",
+	Long: `The secrets of the accounts are stored in the cli database, encrypted with the device key. The device key is
+kept in the backend selected by secrets.backend in the config file, or --secrets-backend:
+  auto     the OS keyring, with the file backend as a fallback in containers (default)
+  keyring  the OS keyring, the Secret Service on Linux
+  keyfile  a file encrypted with a passphrase, read from $ENTE_DEVICE_KEY_PASSPHRASE or prompted.
+           The file is secrets.key-file, device-key.json in the cli data directory by default.
+  env      $ENTE_DEVICE_KEY, the base64 encoded key
+  file     a file with the base64 encoded key, e.g. a mounted secret like /run/secrets/ente_device_key.
+           The file is secrets.file, .secret.txt in the cli data directory by default.`,
+	PersistentPreRunE: setupConfigCommand,
+}
+
+var migrateSecretsCmd = &cobra.Command{
+	Use:   "migrate <backend>",
+	Short: "Move the device key to another backend",
+	Long: `Copy the device key from the current backend to another one, and select it in the config file.
+The key isn't removed from the current backend. For the env backend, the key is printed to be set in $ENTE_DEVICE_KEY.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		from, err := secretOptions()
+		if err != nil {
+			return err
+		}
+		to := from
+		to.Backend = args[0]
+		if err = secrets.ValidateBackend(to.Backend); err != nil {
+			return err
+		}
+		file, _ := cmd.Flags().GetString("file")
+		if file, err = absFilePath(file); err != nil {
+			return err
+		} else if file != "" {
+			to.File = file
+		}
+		keyFile, _ := cmd.Flags().GetString("key-file")
+		if keyFile, err = absFilePath(keyFile); err != nil {
+			return err
+		} else if keyFile != "" {
+			to.KeyFile = keyFile
+		}
+		source, err := secrets.NewStore(from)
+		if err != nil {
+			return err
+		}
+		target, err := secrets.NewStore(to)
+		if err != nil {
+			return err
+		}
+		if source.Name() == target.Name() {
+			return fmt.Errorf("the device key is already in %s", source.Name())
+		}
+		key, err := source.Load()
+		if errors.Is(err, secrets.ErrNotFound) {
+			return fmt.Errorf("%s has no device key to migrate", source.Name())
+		} else if err != nil {
+			return err
+		}
+		if err = target.Save(key); errors.Is(err, secrets.ErrReadOnly) {
+			fmt.Fprintf(os.Stderr, "Set the device key in the environment of the cli:\n")
+			fmt.Printf("%s=%s\n", secrets.DeviceKeyEnv, base64.StdEncoding.EncodeToString(key))
+		} else if err != nil {
+			return err
+		}
+		updates := [][2]string{{"secrets.backend", to.Backend}, {"secrets.file", file}, {"secrets.key-file", keyFile}}
+		for _, update := range updates {
+			if update[1] == "" {
+				continue
+			}
+			if err = config.Set(configFile, configKeys, update[0], update[1]); err != nil {
+				return fmt.Errorf("the device key was copied to %s, but the config file wasn't updated: %w", target.Name(), err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Device key copied from %s to %s, %s selects it\n", source.Name(), target.Name(), configFile)
+		return nil
+	},
+}
+
+var rotateSecretsCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Replace the device key with a new one",
+	Long: `Generate a new device key, re-encrypt the secrets of the accounts and the keys of their albums and files with it,
+and store it in the current backend. The database is only updated once the new key is stored, the keyfile backend
+asks for the passphrase of the new key file. For the env backend, the new key is printed to be set in $ENTE_DEVICE_KEY.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		opts, err := secretOptions()
+		if err != nil {
+			return err
+		}
+		store, err := secrets.NewStore(opts)
+		if err != nil {
+			return err
+		}
+		oldKey, err := store.Load()
+		if errors.Is(err, secrets.ErrNotFound) {
+			return fmt.Errorf("%s has no device key to rotate", store.Name())
+		} else if err != nil {
+			return err
+		}
+		newKey, err := secrets.NewDeviceKey()
+		if err != nil {
+			return err
+		}
+		saved := false
+		count, err := ctrl.RotateDeviceKey(oldKey, newKey, func() error {
+			err := store.Save(newKey)
+			if errors.Is(err, secrets.ErrReadOnly) {
+				fmt.Fprintf(os.Stderr, "Set the new device key in the environment of the cli:\n")
+				fmt.Printf("%s=%s\n", secrets.DeviceKeyEnv, base64.StdEncoding.EncodeToString(newKey))
+				return nil
+			}
+			saved = err == nil
+			return err
+		})
+		if err != nil {
+			if !saved {
+				return fmt.Errorf("the device key wasn't rotated: %w", err)
+			}
+			// the database kept the previous key, put it back in the store
+			if restoreErr := store.Save(oldKey); restoreErr != nil {
+				return fmt.Errorf("the database wasn't updated: %w, and the previous device key couldn't be restored in %s: %v, "+
+					"it is %s", err, store.Name(), restoreErr, base64.StdEncoding.EncodeToString(oldKey))
+			}
+			return fmt.Errorf("the device key wasn't rotated: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Device key in %s rotated, %d secrets re-encrypted\n", store.Name(), count)
+		return nil
+	},
+}
+
+// absFilePath returns the absolute path of a file, for the paths stored in the config file
+func absFilePath(path string) (string, error) {
+	path, err := resolveFilePath(path)
+	if err != nil || path == "" {
+		return path, err
+	}
+	return filepath.Abs(path)
+}
+
+func init() {
+	migrateSecretsCmd.Flags().String("file", "", "path of the key of the file backend")
+	migrateSecretsCmd.Flags().String("key-file", "", "path of the passphrase protected key of the keyfile backend")
+	secretsCmd.AddCommand(migrateSecretsCmd, rotateSecretsCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
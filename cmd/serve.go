@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/pkg"
+	"github.com/ente-io/cli/pkg/gallery"
+	"github.com/ente-io/cli/pkg/library"
+	"github.com/spf13/cobra"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the photos library over the network",
+}
+
+var serveWebDAVCmd = &cobra.Command{
+	Use:   "webdav",
+	Short: "Serve the photos library read-only over WebDAV",
+	Long: `Expose the albums of a photos account as WebDAV collections, for devices and tools that can't use "mount".
+Files are downloaded and decrypted when they are first read, and kept in a local cache.
+Set --user to require basic authentication, the password is read from ENTE_SERVE_PASSWORD or prompted.
+Use --tls-cert and --tls-key when the server is reachable from other machines.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		opts, err := getServeOptions(cmd)
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		lib, err := openServeLibrary(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		defer lib.Close()
+		fmt.Fprintf(os.Stderr, "Serving %d albums over WebDAV on %s, press Ctrl+C to stop\n", len(lib.Albums()), opts.addr)
+		return runServer(ctx, library.NewWebDAVHandler(lib), opts)
+	},
+}
+
+var serveGalleryCmd = &cobra.Command{
+	Use:   "gallery [exportDir]",
+	Short: "Serve a web gallery of the photos library or of an export",
+	Long: `Start a web server for browsing the albums in a browser, with a thumbnail grid and the details of each file.
+With an export directory, the gallery shows the exported files and needs no account. Without it, the gallery
+shows the albums of a photos account from the local store, downloading the files when they are viewed.
+Set --user to require basic authentication, the password is read from ENTE_SERVE_PASSWORD or prompted.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		recoverWithLog()
+		opts, err := getServeOptions(cmd)
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		var source gallery.Source
+		if len(args) == 1 {
+			exportDir, err := internal.ResolvePath(args[0])
+			if err != nil {
+				return err
+			}
+			if source, err = pkg.NewExportGallery(ctx, exportDir); err != nil {
+				return err
+			}
+		} else {
+			lib, err := openServeLibrary(ctx, cmd)
+			if err != nil {
+				return err
+			}
+			defer lib.Close()
+			source = gallery.NewLibrarySource(lib)
+		}
+		title, _ := cmd.Flags().GetString("title")
+		fmt.Fprintf(os.Stderr, "Serving the gallery on %s, press Ctrl+C to stop\n", opts.addr)
+		return runServer(ctx, gallery.NewHandler(source, title), opts)
+	},
+}
+
+type serveOptions struct {
+	addr     string
+	user     string
+	password string
+	tlsCert  string
+	tlsKey   string
+}
+
+func getServeOptions(cmd *cobra.Command) (*serveOptions, error) {
+	opts := &serveOptions{}
+	opts.addr, _ = cmd.Flags().GetString("addr")
+	opts.user, _ = cmd.Flags().GetString("user")
+	opts.tlsCert, _ = cmd.Flags().GetString("tls-cert")
+	opts.tlsKey, _ = cmd.Flags().GetString("tls-key")
+	if (opts.tlsCert == "") != (opts.tlsKey == "") {
+		return nil, errors.New("--tls-cert and --tls-key must be set together")
+	}
+	var err error
+	if opts.password, err = getServePassword(opts.user); err != nil {
+		return nil, err
+	}
+	if opts.user == "" && !isLoopbackAddr(opts.addr) {
+		slog.Warn("serving without authentication, set --user to require a password", "addr", opts.addr)
+	}
+	return opts, nil
+}
+
+// openServeLibrary opens the library of the account selected by the command's flags
+func openServeLibrary(ctx context.Context, cmd *cobra.Command) (*library.Library, error) {
+	account := accountRef(cmd)
+	refresh, _ := cmd.Flags().GetBool("refresh")
+	cacheSize, _ := cmd.Flags().GetInt64("cache-size")
+	cacheDir, err := getLibraryCacheDir(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return ctrl.OpenLibrary(ctx, account, refresh, library.Options{
+		CacheDir:  cacheDir,
+		CacheSize: cacheSize * 1024 * 1024,
+	})
+}
+
+// getServePassword returns the password for basic authentication, if a user is set
+func getServePassword(user string) (string, error) {
+	if user == "" {
+		return "", nil
+	}
+	if password := os.Getenv("ENTE_SERVE_PASSWORD"); password != "" {
+		return password, nil
+	}
+	password, err := internal.GetSensitiveField("Enter password for " + user)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if password == "" {
+		return "", errors.New("password can't be empty")
+	}
+	return password, nil
+}
+
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// runServer serves until the context is cancelled, then waits for the active requests to complete
+func runServer(ctx context.Context, handler http.Handler, opts *serveOptions) error {
+	server := &http.Server{
+		Addr:              opts.addr,
+		Handler:           library.BasicAuth(handler, opts.user, opts.password),
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		if opts.tlsCert != "" {
+			errCh <- server.ListenAndServeTLS(opts.tlsCert, opts.tlsKey)
+		} else {
+			errCh <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+func addServeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("addr", "127.0.0.1:8080", "address to listen on")
+	cmd.Flags().String("user", "", "require basic authentication with this user name")
+	cmd.Flags().String("tls-cert", "", "TLS certificate file, to serve over HTTPS")
+	cmd.Flags().String("tls-key", "", "TLS private key file, to serve over HTTPS")
+	cmd.Flags().String("email", "", "email address of the photos account, optional if only one is configured")
+	cmd.Flags().String("api-endpoint", "", accountSelectUsage)
+	cmd.Flags().Bool("refresh", false, "fetch the latest albums and files before serving")
+	cmd.Flags().String("cache-dir", "", "directory for the decrypted files, defaults to the user's cache directory")
+	cmd.Flags().Int64("cache-size", 2048, "size of the cache in MB, least recently used files are evicted beyond it")
+}
+
+func init() {
+	addServeFlags(serveWebDAVCmd)
+	addServeFlags(serveGalleryCmd)
+	serveGalleryCmd.Flags().String("title", "Photos", "title shown at the top of the gallery")
+	serveCmd.AddCommand(serveWebDAVCmd, serveGalleryCmd)
+	rootCmd.AddCommand(serveCmd)
+}
@@ -2,17 +2,35 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"runtime"
 
 	"github.com/spf13/cobra"
 )
 
+type versionInfo struct {
+	Version   string `json:"version" yaml:"version"`
+	GoVersion string `json:"goVersion" yaml:"goVersion"`
+	OS        string `json:"os" yaml:"os"`
+	Arch      string `json:"arch" yaml:"arch"`
+}
+
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Prints the current version",
 	Long:  ``,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("Version %s\n", AppVersion)
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info := versionInfo{
+			Version:   AppVersion,
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+		}
+		return printResult(info, func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Version %s\n", info.Version)
+			return err
+		})
 	},
 }
 
@@ -2,12 +2,24 @@ package api
 
 import (
 	"fmt"
+	"github.com/go-resty/resty/v2"
 	"strings"
+	"time"
 )
 
 type ApiError struct {
 	Message    string
 	StatusCode int
+	// RetryAfter is the wait requested by the server before trying again
+	RetryAfter time.Duration
+}
+
+func newApiError(r *resty.Response) *ApiError {
+	return &ApiError{
+		StatusCode: r.StatusCode(),
+		Message:    r.String(),
+		RetryAfter: retryAfter(r.Header()),
+	}
 }
 
 func (e *ApiError) Error() string {
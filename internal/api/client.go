@@ -2,9 +2,12 @@ package api
 
 import (
 	"context"
+	"github.com/ente-io/cli/internal/bandwidth"
+	"github.com/ente-io/cli/internal/metrics"
 	"github.com/go-resty/resty/v2"
-	"log"
-	"time"
+	"log/slog"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -23,12 +26,15 @@ type Client struct {
 	restClient *resty.Client
 	// use separate client for downloading files
 	downloadClient *resty.Client
+	logger         *slog.Logger
 }
 
 type Params struct {
 	Debug bool
 	Trace bool
 	Host  string
+	// Logger defaults to slog.Default()
+	Logger *slog.Logger
 }
 
 func readValueFromContext(ctx context.Context, key string) interface{} {
@@ -49,8 +55,20 @@ func NewClient(p Params) *Client {
 		}
 		req.Header.Set(ClientPkgHeader, StringToApp(app.(string)).ClientPkg())
 		attachToken(req)
+		// requests of accounts on self-hosted servers go to their API endpoint instead of the base url
+		if host := endpoints(req.Context()).API; host != "" && strings.HasPrefix(req.URL, "/") {
+			req.URL = host + req.URL
+		}
+		return nil
+	})
+	client := &Client{restClient: enteAPI, logger: p.Logger}
+	enteAPI.OnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
+		client.log().DebugContext(resp.Request.Context(), "api request",
+			"method", resp.Request.Method, "url", resp.Request.URL, "status", resp.StatusCode(), "duration", resp.Time())
 		return nil
 	})
+	enteAPI.OnAfterResponse(countErrorResponse)
+	DefaultRetryPolicy.apply(enteAPI, "api", client.log)
 	if p.Debug {
 		enteAPI.OnBeforeRequest(func(c *resty.Client, req *resty.Request) error {
 			logRequest(req)
@@ -67,20 +85,31 @@ func NewClient(p Params) *Client {
 	} else {
 		enteAPI.SetBaseURL(EnteAPIEndpoint)
 	}
-	return &Client{
-		restClient: enteAPI,
-		downloadClient: resty.New().
-			SetRetryCount(3).
-			SetRetryWaitTime(5 * time.Second).
-			SetRetryMaxWaitTime(10 * time.Second).
-			AddRetryCondition(func(r *resty.Response, err error) bool {
-				shouldRetry := r.StatusCode() == 429 || r.StatusCode() > 500
-				if shouldRetry {
-					log.Printf("retrying download due to %d code", r.StatusCode())
-				}
-				return shouldRetry
-			}),
+	client.downloadClient = resty.New().
+		OnAfterResponse(countErrorResponse)
+	DefaultRetryPolicy.apply(client.downloadClient, "download", client.log)
+	// downloads are limited to the bandwidth of their context
+	client.downloadClient.SetTransport(bandwidth.NewTransport(client.downloadClient.GetClient().Transport))
+	return client
+}
+
+func countErrorResponse(c *resty.Client, resp *resty.Response) error {
+	if resp.IsError() {
+		metrics.APIErrors.WithLabelValues(strconv.Itoa(resp.StatusCode())).Inc()
+	}
+	return nil
+}
+
+// SetLogger replaces the logger of the client, for when it's only configured after the client is created
+func (c *Client) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+}
+
+func (c *Client) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
 	}
+	return slog.Default()
 }
 
 func attachToken(req *resty.Request) {
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 )
 
@@ -15,10 +16,7 @@ func (c *Client) GetCollections(ctx context.Context, sinceTime int64) ([]Collect
 		SetResult(&res).
 		Get("/collections/v2")
 	if r.IsError() {
-		return nil, &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return nil, newApiError(r)
 	}
 	return res.Collections, err
 }
@@ -35,10 +33,7 @@ func (c *Client) GetFiles(ctx context.Context, collectionID, sinceTime int64) ([
 		SetResult(&res).
 		Get("/collections/v2/diff")
 	if r.IsError() {
-		return nil, false, &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return nil, false, newApiError(r)
 	}
 	return res.Files, res.HasMore, err
 }
@@ -55,10 +50,80 @@ func (c *Client) GetFile(ctx context.Context, collectionID, fileID int64) (*File
 		SetResult(&res).
 		Get("/collections/file")
 	if r.IsError() {
-		return nil, &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return nil, newApiError(r)
 	}
 	return &res.File, err
 }
+
+func (c *Client) CreateCollection(ctx context.Context, req CreateCollectionRequest) (*Collection, error) {
+	var res struct {
+		Collection Collection `json:"collection"`
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetResult(&res).
+		SetBody(req).
+		Post("/collections")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, newApiError(r)
+	}
+	return &res.Collection, nil
+}
+
+// AddFilesToCollection adds existing files to the given collection. The file keys
+// should be encrypted with the key of the target collection.
+func (c *Client) AddFilesToCollection(ctx context.Context, collectionID int64, files []CollectionFileItem) error {
+	payload := map[string]interface{}{
+		"collectionID": collectionID,
+		"files":        files,
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetBody(payload).
+		Post("/collections/add-files")
+	if err != nil {
+		return err
+	}
+	if r.IsError() {
+		return newApiError(r)
+	}
+	return nil
+}
+
+func (c *Client) RenameCollection(ctx context.Context, collectionID int64, encryptedName, nameDecryptionNonce string) error {
+	payload := map[string]interface{}{
+		"collectionID":        collectionID,
+		"encryptedName":       encryptedName,
+		"nameDecryptionNonce": nameDecryptionNonce,
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetBody(payload).
+		Post("/collections/rename")
+	if err != nil {
+		return err
+	}
+	if r.IsError() {
+		return newApiError(r)
+	}
+	return nil
+}
+
+// TrashCollection deletes the collection. If keepFiles is true, the files which are only
+// present in this collection are moved to the uncategorized collection instead of trash.
+func (c *Client) TrashCollection(ctx context.Context, collectionID int64, keepFiles bool) error {
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetQueryParam("keepFiles", strconv.FormatBool(keepFiles)).
+		Delete(fmt.Sprintf("/collections/v3/%d", collectionID))
+	if err != nil {
+		return err
+	}
+	if r.IsError() {
+		return newApiError(r)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	RoleViewer       = "VIEWER"
+	RoleCollaborator = "COLLABORATOR"
+)
+
+// PublicURL represents a public link of a collection
+type PublicURL struct {
+	URL             string `json:"url"`
+	DeviceLimit     int    `json:"deviceLimit"`
+	ValidTill       int64  `json:"validTill"`
+	EnableDownload  bool   `json:"enableDownload"`
+	EnableCollect   bool   `json:"enableCollect"`
+	PasswordEnabled bool   `json:"passwordEnabled"`
+}
+
+// UpdatePublicURLRequest contains the attributes of a public link that should be updated.
+// Attributes which are nil are left unchanged.
+type UpdatePublicURLRequest struct {
+	CollectionID    int64   `json:"collectionID"`
+	ValidTill       *int64  `json:"validTill,omitempty"`
+	DeviceLimit     *int    `json:"deviceLimit,omitempty"`
+	PassHash        *string `json:"passHash,omitempty"`
+	Nonce           *string `json:"nonce,omitempty"`
+	MemLimit        *int64  `json:"memLimit,omitempty"`
+	OpsLimit        *int64  `json:"opsLimit,omitempty"`
+	EnableDownload  *bool   `json:"enableDownload,omitempty"`
+	DisablePassword *bool   `json:"disablePassword,omitempty"`
+}
+
+// GetPublicKey returns the user ID and the public key of the ente user with the given email
+func (c *Client) GetPublicKey(ctx context.Context, email string) (int64, string, error) {
+	var res struct {
+		UserID    int64  `json:"userID"`
+		PublicKey string `json:"publicKey"`
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetQueryParam("email", email).
+		SetResult(&res).
+		Get("/users/public-key")
+	if err != nil {
+		return 0, "", err
+	}
+	if r.IsError() {
+		return 0, "", newApiError(r)
+	}
+	return res.UserID, res.PublicKey, nil
+}
+
+// ShareCollection shares the collection with the given user. It is also used to change
+// the role of an existing sharee.
+func (c *Client) ShareCollection(ctx context.Context, collectionID int64, email, encryptedKey, role string) error {
+	payload := map[string]interface{}{
+		"collectionID": collectionID,
+		"email":        email,
+		"encryptedKey": encryptedKey,
+		"role":         role,
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetBody(payload).
+		Post("/collections/share")
+	if err != nil {
+		return err
+	}
+	if r.IsError() {
+		return newApiError(r)
+	}
+	return nil
+}
+
+func (c *Client) UnshareCollection(ctx context.Context, collectionID int64, email string) error {
+	payload := map[string]interface{}{
+		"collectionID": collectionID,
+		"email":        email,
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetBody(payload).
+		Post("/collections/unshare")
+	if err != nil {
+		return err
+	}
+	if r.IsError() {
+		return newApiError(r)
+	}
+	return nil
+}
+
+func (c *Client) CreatePublicURL(ctx context.Context, collectionID int64, validTill int64, deviceLimit int) (*PublicURL, error) {
+	var res struct {
+		Result PublicURL `json:"result"`
+	}
+	payload := map[string]interface{}{
+		"collectionID": collectionID,
+		"validTill":    validTill,
+		"deviceLimit":  deviceLimit,
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetBody(payload).
+		SetResult(&res).
+		Post("/collections/share-url")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, newApiError(r)
+	}
+	return &res.Result, nil
+}
+
+func (c *Client) UpdatePublicURL(ctx context.Context, req UpdatePublicURLRequest) (*PublicURL, error) {
+	var res struct {
+		Result PublicURL `json:"result"`
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetBody(req).
+		SetResult(&res).
+		Put("/collections/share-url")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, newApiError(r)
+	}
+	return &res.Result, nil
+}
+
+func (c *Client) DeletePublicURL(ctx context.Context, collectionID int64) error {
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		Delete(fmt.Sprintf("/collections/share-url/%d", collectionID))
+	if err != nil {
+		return err
+	}
+	if r.IsError() {
+		return newApiError(r)
+	}
+	return nil
+}
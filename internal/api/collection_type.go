@@ -11,6 +11,7 @@ type Collection struct {
 	NameDecryptionNonce string           `json:"nameDecryptionNonce"`
 	Type                string           `json:"type" binding:"required"`
 	Sharees             []CollectionUser `json:"sharees"`
+	PublicURLs          []PublicURL      `json:"publicURLs"`
 	UpdationTime        int64            `json:"updationTime"`
 	IsDeleted           bool             `json:"isDeleted,omitempty"`
 	MagicMetadata       *MagicMetadata   `json:"magicMetadata,omitempty"`
@@ -35,6 +36,16 @@ type MagicMetadata struct {
 	Header  string `json:"header,omitempty" binding:"required"`
 }
 
+// CreateCollectionRequest is the payload for creating a new collection
+type CreateCollectionRequest struct {
+	EncryptedKey        string         `json:"encryptedKey"`
+	KeyDecryptionNonce  string         `json:"keyDecryptionNonce"`
+	EncryptedName       string         `json:"encryptedName"`
+	NameDecryptionNonce string         `json:"nameDecryptionNonce"`
+	Type                string         `json:"type"`
+	MagicMetadata       *MagicMetadata `json:"magicMetadata,omitempty"`
+}
+
 // CollectionFileItem represents a file in an AddFilesRequest and MoveFilesRequest
 type CollectionFileItem struct {
 	ID                 int64  `json:"id" binding:"required"`
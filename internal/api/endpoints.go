@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	enteFilesEndpoint      = "https://files.ente.io"
+	enteThumbnailsEndpoint = "https://thumbnails.ente.io"
+)
+
+// Endpoints of the server of an account. The zero value is ente.io.
+type Endpoints struct {
+	// API is the endpoint of the museum server
+	API string
+	// Files serves the file downloads as ?fileID=, like files.ente.io. Self-hosted servers serve them from
+	// the API endpoint when it's empty.
+	Files string
+}
+
+// ParseEndpoint validates an http(s) endpoint and strips its trailing slash
+func ParseEndpoint(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid endpoint %q, expected an http or https url", value)
+	}
+	return strings.TrimSuffix(value, "/"), nil
+}
+
+// IsDefault reports whether the endpoints are the ones of ente.io
+func (e Endpoints) IsDefault() bool {
+	return e.isEnteAPI() && e.Files == ""
+}
+
+// isEnteAPI reports whether the museum server is the one of ente.io, which is also the case of an account with
+// only a files endpoint
+func (e Endpoints) isEnteAPI() bool {
+	return e.API == "" || e.API == EnteAPIEndpoint
+}
+
+func (e Endpoints) fileURL(fileID int64) string {
+	id := strconv.FormatInt(fileID, 10)
+	switch {
+	case e.Files != "":
+		return e.Files + "/?fileID=" + id
+	case e.IsDefault():
+		return enteFilesEndpoint + "/?fileID=" + id
+	default:
+		return e.API + "/files/download/" + id
+	}
+}
+
+func (e Endpoints) thumbnailURL(fileID int64) string {
+	id := strconv.FormatInt(fileID, 10)
+	if e.isEnteAPI() {
+		return enteThumbnailsEndpoint + "/?fileID=" + id
+	}
+	return e.API + "/files/preview/" + id
+}
+
+// endpoints returns the endpoints of the account of the request context
+func endpoints(ctx context.Context) Endpoints {
+	e, _ := readValueFromContext(ctx, "endpoints").(Endpoints)
+	return e
+}
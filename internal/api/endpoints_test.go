@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpointURLs(t *testing.T) {
+	tests := []struct {
+		endpoints Endpoints
+		file      string
+		thumbnail string
+	}{
+		{Endpoints{}, "https://files.ente.io/?fileID=7", "https://thumbnails.ente.io/?fileID=7"},
+		{Endpoints{API: EnteAPIEndpoint}, "https://files.ente.io/?fileID=7", "https://thumbnails.ente.io/?fileID=7"},
+		{Endpoints{API: "http://localhost:8080"}, "http://localhost:8080/files/download/7", "http://localhost:8080/files/preview/7"},
+		{Endpoints{API: "https://museum.example.org", Files: "https://files.example.org"},
+			"https://files.example.org/?fileID=7", "https://museum.example.org/files/preview/7"},
+		{Endpoints{Files: "https://files.example.org"}, "https://files.example.org/?fileID=7", "https://thumbnails.ente.io/?fileID=7"},
+	}
+	for _, tt := range tests {
+		if got := tt.endpoints.fileURL(7); got != tt.file {
+			t.Errorf("fileURL of %+v = %s, want %s", tt.endpoints, got, tt.file)
+		}
+		if got := tt.endpoints.thumbnailURL(7); got != tt.thumbnail {
+			t.Errorf("thumbnailURL of %+v = %s, want %s", tt.endpoints, got, tt.thumbnail)
+		}
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	if got, err := ParseEndpoint("https://museum.example.org/"); err != nil || got != "https://museum.example.org" {
+		t.Errorf("unexpected endpoint %q: %v", got, err)
+	}
+	if got, err := ParseEndpoint(""); err != nil || got != "" {
+		t.Errorf("expected no endpoint, got %q: %v", got, err)
+	}
+	for _, value := range []string{"museum.example.org", "ftp://museum.example.org", "http://"} {
+		if _, err := ParseEndpoint(value); err == nil {
+			t.Errorf("ParseEndpoint(%q) expected an error", value)
+		}
+	}
+}
+
+func TestRequestsUseAccountEndpoint(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		_, _ = w.Write([]byte(`{"collections":[]}`))
+	}))
+	defer server.Close()
+	client := NewClient(Params{Host: "http://127.0.0.1:1"})
+	ctx := context.WithValue(context.Background(), "app", string(AppPhotos))
+	ctx = context.WithValue(ctx, "endpoints", Endpoints{API: server.URL})
+
+	if _, err := client.GetCollections(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DownloadThumbnail(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 || paths[0] != "/collections/v2" || paths[1] != "/files/preview/7" {
+		t.Errorf("unexpected requests to the account's server %v", paths)
+	}
+}
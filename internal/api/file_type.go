@@ -28,4 +28,7 @@ type FileInfo struct {
 type FileAttributes struct {
 	EncryptedData    string `json:"encryptedData,omitempty"`
 	DecryptionHeader string `json:"decryptionHeader" binding:"required"`
+	// ObjectKey and Size are only set while uploading a file
+	ObjectKey string `json:"objectKey,omitempty"`
+	Size      int64  `json:"size,omitempty"`
 }
@@ -2,11 +2,8 @@ package api
 
 import (
 	"context"
-	"strconv"
-)
-
-var (
-	downloadHost = "https://files.ente.io/?fileID="
+	"io"
+	"os"
 )
 
 func (c *Client) DownloadFile(ctx context.Context, fileID int64, absolutePath string) error {
@@ -14,12 +11,50 @@ func (c *Client) DownloadFile(ctx context.Context, fileID int64, absolutePath st
 		SetContext(ctx).
 		SetOutput(absolutePath)
 	attachToken(req)
-	r, err := req.Get(downloadHost + strconv.FormatInt(fileID, 10))
+	r, err := req.Get(endpoints(ctx).fileURL(fileID))
+	if err == nil && r.IsError() {
+		err = newApiError(r)
+	}
+	if err != nil {
+		// don't leave a partial download behind, e.g. when the context is cancelled
+		_ = os.Remove(absolutePath)
+	}
+	return err
+}
+
+// DownloadFileStream returns the encrypted content of the file as a stream. The caller must close it.
+func (c *Client) DownloadFileStream(ctx context.Context, fileID int64) (io.ReadCloser, error) {
+	req := c.downloadClient.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true)
+	attachToken(req)
+	r, err := req.Get(endpoints(ctx).fileURL(fileID))
+	if err != nil {
+		return nil, err
+	}
 	if r.IsError() {
-		return &ApiError{
+		body, _ := io.ReadAll(r.RawBody())
+		r.RawBody().Close()
+		return nil, &ApiError{
 			StatusCode: r.StatusCode(),
-			Message:    r.String(),
+			Message:    string(body),
+			RetryAfter: retryAfter(r.Header()),
 		}
 	}
-	return err
+	return r.RawBody(), nil
+}
+
+// DownloadThumbnail returns the encrypted thumbnail of the file
+func (c *Client) DownloadThumbnail(ctx context.Context, fileID int64) ([]byte, error) {
+	req := c.downloadClient.R().
+		SetContext(ctx)
+	attachToken(req)
+	r, err := req.Get(endpoints(ctx).thumbnailURL(fileID))
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, newApiError(r)
+	}
+	return r.Body(), nil
 }
@@ -19,10 +19,7 @@ func (c *Client) GetSRPAttributes(ctx context.Context, email string) (*SRPAttrib
 		return nil, err
 	}
 	if r.IsError() {
-		return nil, &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return nil, newApiError(r)
 	}
 	return res.SRPAttributes, err
 }
@@ -46,10 +43,7 @@ func (c *Client) CreateSRPSession(
 		return nil, err
 	}
 	if r.IsError() {
-		return nil, &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return nil, newApiError(r)
 	}
 	return &res, nil
 }
@@ -75,10 +69,7 @@ func (c *Client) VerifySRPSession(
 		return nil, err
 	}
 	if r.IsError() {
-		return nil, &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return nil, newApiError(r)
 	}
 	return &res, nil
 }
@@ -100,10 +91,7 @@ func (c *Client) SendEmailOTP(
 		return err
 	}
 	if r.IsError() {
-		return &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return newApiError(r)
 	}
 	return nil
 }
@@ -127,10 +115,7 @@ func (c *Client) VerifyEmail(
 		return nil, err
 	}
 	if r.IsError() {
-		return nil, &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return nil, newApiError(r)
 	}
 	return &res, nil
 }
@@ -154,10 +139,7 @@ func (c *Client) VerifyTotp(
 		return nil, err
 	}
 	if r.IsError() {
-		return nil, &ApiError{
-			StatusCode: r.StatusCode(),
-			Message:    r.String(),
-		}
+		return nil, newApiError(r)
 	}
 	return &res, nil
 }
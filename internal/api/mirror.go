@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// GetRawCollections returns the collections changed since the given time, exactly as sent by the server
+func (c *Client) GetRawCollections(ctx context.Context, sinceTime int64) ([]json.RawMessage, error) {
+	var res struct {
+		Collections []json.RawMessage `json:"collections"`
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetQueryParam("sinceTime", strconv.FormatInt(sinceTime, 10)).
+		SetResult(&res).
+		Get("/collections/v2")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, newApiError(r)
+	}
+	return res.Collections, nil
+}
+
+// GetRawFiles returns the file records of the collection changed since the given time, exactly as sent by the server
+func (c *Client) GetRawFiles(ctx context.Context, collectionID, sinceTime int64) ([]json.RawMessage, bool, error) {
+	var res struct {
+		Files   []json.RawMessage `json:"diff"`
+		HasMore bool              `json:"hasMore"`
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetQueryParam("sinceTime", strconv.FormatInt(sinceTime, 10)).
+		SetQueryParam("collectionID", strconv.FormatInt(collectionID, 10)).
+		SetResult(&res).
+		Get("/collections/v2/diff")
+	if err != nil {
+		return nil, false, err
+	}
+	if r.IsError() {
+		return nil, false, newApiError(r)
+	}
+	return res.Files, res.HasMore, nil
+}
+
+// GetRawKeyAttributes returns the key attributes of the user, exactly as sent by the server.
+// Along with the password, they are enough to decrypt all the data of the account.
+func (c *Client) GetRawKeyAttributes(ctx context.Context) (json.RawMessage, error) {
+	var res struct {
+		HasSetKeys    bool            `json:"hasSetKeys"`
+		KeyAttributes json.RawMessage `json:"keyAttributes"`
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetResult(&res).
+		Get("/users/session-validity/v2")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, newApiError(r)
+	}
+	if !res.HasSetKeys || len(res.KeyAttributes) == 0 {
+		return nil, fmt.Errorf("key attributes are not set for the account")
+	}
+	return res.KeyAttributes, nil
+}
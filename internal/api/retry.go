@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/internal/metrics"
+	"github.com/go-resty/resty/v2"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrorKind classifies errors to decide if they are worth retrying
+type ErrorKind string
+
+const (
+	// ErrorKindNetwork is a failure to connect or a connection broken while reading the response
+	ErrorKindNetwork ErrorKind = "network"
+	// ErrorKindServer is a 5xx response
+	ErrorKindServer ErrorKind = "server"
+	// ErrorKindRateLimit is a 429 response
+	ErrorKindRateLimit ErrorKind = "rate_limit"
+	// ErrorKindAuth is a 401 or 403 response, the token is invalid or expired
+	ErrorKindAuth ErrorKind = "auth"
+	// ErrorKindClient is any other 4xx response
+	ErrorKindClient ErrorKind = "client"
+	// ErrorKindCanceled is a cancelled context
+	ErrorKindCanceled ErrorKind = "canceled"
+	ErrorKindOther    ErrorKind = "other"
+)
+
+// ClassifyError returns the kind of the error, an empty kind for nil
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) {
+		return classifyStatus(apiErr.StatusCode)
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorKindCanceled
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindNetwork
+	}
+	return ErrorKindOther
+}
+
+func classifyStatus(status int) ErrorKind {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrorKindRateLimit
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorKindAuth
+	case status >= 500 && status != http.StatusNotImplemented:
+		return ErrorKindServer
+	case status >= 400:
+		return ErrorKindClient
+	}
+	return ""
+}
+
+// IsRetryable reports whether the request which failed with err may succeed when retried
+func IsRetryable(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorKindNetwork, ErrorKindServer, ErrorKindRateLimit:
+		return true
+	}
+	return false
+}
+
+// RetryPolicy decides how often failed requests are retried and how long to wait before each retry.
+// The wait doubles with every retry, up to MaxDelay, and is randomized so that clients don't retry in
+// lockstep. A Retry-After sent with a 429 or 503 response takes precedence.
+type RetryPolicy struct {
+	// MaxRetries after the first attempt
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// DefaultRetryPolicy is used by the requests of the client
+var DefaultRetryPolicy = RetryPolicy{MaxRetries: 4, BaseDelay: 2 * time.Second, MaxDelay: 2 * time.Minute}
+
+// Backoff returns the wait before the given retry, 1 for the first one. It's between half and all of
+// the exponential delay.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// Delay returns the wait before the given retry of the request which failed with err
+func (p RetryPolicy) Delay(retry int, err error) time.Duration {
+	var apiErr *ApiError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return p.capDelay(apiErr.RetryAfter)
+	}
+	return p.Backoff(retry)
+}
+
+func (p RetryPolicy) capDelay(delay time.Duration) time.Duration {
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// permanentError stops the retries of RetryPolicy.Do
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so that RetryPolicy.Do returns it without retrying, e.g. because it was already retried
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Do calls fn until it succeeds, fails with an error which isn't retryable or the retries are exhausted.
+// It stops waiting when ctx is done. kind labels the retries in the logs and metrics.
+func (p RetryPolicy) Do(ctx context.Context, kind string, fn func() error) error {
+	for retry := 0; ; retry++ {
+		err := fn()
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if err == nil || !IsRetryable(err) || retry >= p.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+		delay := p.Delay(retry+1, err)
+		logging.FromContext(ctx, nil).Warn("retrying", "kind", kind, "retry", retry+1, "wait", delay, "error", err)
+		metrics.Retries.WithLabelValues(kind).Inc()
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// apply configures the retries of the resty client. Only requests of the idempotent methods are retried.
+func (p RetryPolicy) apply(client *resty.Client, kind string, logger func() *slog.Logger) {
+	client.SetRetryCount(p.MaxRetries).
+		SetRetryWaitTime(p.BaseDelay).
+		SetRetryMaxWaitTime(p.MaxDelay).
+		SetRetryAfter(func(_ *resty.Client, r *resty.Response) (time.Duration, error) {
+			if delay := retryAfter(r.Header()); delay > 0 {
+				return p.capDelay(delay), nil
+			}
+			return p.Backoff(r.Request.Attempt), nil
+		}).
+		AddRetryCondition(func(r *resty.Response, err error) bool {
+			if r == nil || r.Request == nil || !isIdempotent(r.Request.Method) {
+				return false
+			}
+			if err != nil {
+				return ClassifyError(err) == ErrorKindNetwork
+			}
+			switch classifyStatus(r.StatusCode()) {
+			case ErrorKindServer, ErrorKindRateLimit:
+				return true
+			}
+			return false
+		}).
+		AddRetryHook(func(r *resty.Response, err error) {
+			if r == nil || r.Request == nil || r.Request.Attempt > p.MaxRetries {
+				return
+			}
+			// the body of a streamed response isn't read, close it as it's replaced by the retry
+			if body := r.RawBody(); body != nil {
+				_ = body.Close()
+			}
+			logger().WarnContext(r.Request.Context(), "retrying request", "kind", kind, "url", r.Request.URL,
+				"attempt", r.Request.Attempt, "status", r.StatusCode(), "error", err)
+			metrics.Retries.WithLabelValues(kind).Inc()
+		})
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header, in seconds or as a date. It returns 0 without a valid header.
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
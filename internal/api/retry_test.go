@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-resty/resty/v2"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorKind
+	}{
+		{nil, ""},
+		{&ApiError{StatusCode: 429}, ErrorKindRateLimit},
+		{&ApiError{StatusCode: 401}, ErrorKindAuth},
+		{&ApiError{StatusCode: 403}, ErrorKindAuth},
+		{&ApiError{StatusCode: 404}, ErrorKindClient},
+		{&ApiError{StatusCode: 502}, ErrorKindServer},
+		{&ApiError{StatusCode: 501}, ErrorKindClient},
+		{fmt.Errorf("get collections: %w", &ApiError{StatusCode: 503}), ErrorKindServer},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrorKindNetwork},
+		{fmt.Errorf("download: %w", io.ErrUnexpectedEOF), ErrorKindNetwork},
+		{context.DeadlineExceeded, ErrorKindNetwork},
+		{fmt.Errorf("sync: %w", context.Canceled), ErrorKindCanceled},
+		{errors.New("fileID already present"), ErrorKindOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("ClassifyError(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 8 * time.Second, 8: 10 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if got := p.Backoff(retry); got < want/2 || got > want {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", retry, got, want/2, want)
+			}
+		}
+	}
+	if got := p.Delay(1, &ApiError{StatusCode: 429, RetryAfter: 5 * time.Second}); got != 5*time.Second {
+		t.Errorf("expected Retry-After to take precedence, got %s", got)
+	}
+	if got := p.Delay(1, &ApiError{StatusCode: 429, RetryAfter: time.Hour}); got != p.MaxDelay {
+		t.Errorf("expected Retry-After to be capped, got %s", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := http.Header{}
+	if got := retryAfter(header); got != 0 {
+		t.Errorf("expected no delay without header, got %s", got)
+	}
+	header.Set("Retry-After", "7")
+	if got := retryAfter(header); got != 7*time.Second {
+		t.Errorf("expected 7s, got %s", got)
+	}
+	header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if got := retryAfter(header); got < 58*time.Second || got > time.Minute {
+		t.Errorf("expected about a minute, got %s", got)
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	calls := 0
+	err := p.Do(context.Background(), "test", func() error {
+		calls++
+		if calls < 3 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success after 3 calls, got %d: %v", calls, err)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), "test", func() error {
+		calls++
+		return &ApiError{StatusCode: 401}
+	})
+	if calls != 1 || ClassifyError(err) != ErrorKindAuth {
+		t.Errorf("expected no retry of an auth error, got %d calls: %v", calls, err)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), "test", func() error {
+		calls++
+		return Permanent(&ApiError{StatusCode: 503})
+	})
+	var apiErr *ApiError
+	if calls != 1 || !errors.As(err, &apiErr) || apiErr.StatusCode != 503 {
+		t.Errorf("expected no retry of a permanent error, got %d calls: %v", calls, err)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), "test", func() error {
+		calls++
+		return &ApiError{StatusCode: 500}
+	})
+	if calls != 4 || err == nil {
+		t.Errorf("expected 4 calls, got %d: %v", calls, err)
+	}
+}
+
+func TestRestyRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+	client := resty.New()
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	policy.apply(client, "test", (&Client{}).log)
+
+	r, err := client.R().Get(server.URL)
+	if err != nil || r.String() != "ok" || calls.Load() != 3 {
+		t.Errorf("expected success after 3 calls, got %d: %v %q", calls.Load(), err, r.String())
+	}
+
+	// non idempotent requests aren't retried
+	calls.Store(1)
+	r, err = client.R().Post(server.URL)
+	if err != nil || r.StatusCode() != http.StatusBadGateway || calls.Load() != 2 {
+		t.Errorf("expected a single call, got %d: %v %d", calls.Load(), err, r.StatusCode())
+	}
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// UploadURL is a pre-signed URL which can be used to upload an encrypted object
+type UploadURL struct {
+	ObjectKey string `json:"objectKey"`
+	URL       string `json:"url"`
+}
+
+// CreateFileRequest is the payload for registering an uploaded file with the server
+type CreateFileRequest struct {
+	CollectionID       int64          `json:"collectionID"`
+	EncryptedKey       string         `json:"encryptedKey"`
+	KeyDecryptionNonce string         `json:"keyDecryptionNonce"`
+	File               FileAttributes `json:"file"`
+	Thumbnail          FileAttributes `json:"thumbnail"`
+	Metadata           FileAttributes `json:"metadata"`
+	PubicMagicMetadata *MagicMetadata `json:"pubMagicMetadata,omitempty"`
+}
+
+func (c *Client) GetUploadURLs(ctx context.Context, count int) ([]UploadURL, error) {
+	var res struct {
+		URLs []UploadURL `json:"urls"`
+	}
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetQueryParam("count", strconv.Itoa(count)).
+		SetResult(&res).
+		Get("/files/upload-urls")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, newApiError(r)
+	}
+	return res.URLs, nil
+}
+
+// UploadObject uploads the file at the given path to the pre-signed url
+func (c *Client) UploadObject(ctx context.Context, url string, filePath string) (int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	// build the request manually, as pre-signed urls require an explicit content length
+	// which is not derived by resty for streamed bodies
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, file)
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = stat.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+	r, err := c.downloadClient.GetClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Body.Close()
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		body, _ := io.ReadAll(r.Body)
+		return 0, &ApiError{
+			StatusCode: r.StatusCode,
+			Message:    string(body),
+		}
+	}
+	return stat.Size(), nil
+}
+
+func (c *Client) CreateFile(ctx context.Context, req CreateFileRequest) (*File, error) {
+	var res File
+	r, err := c.restClient.R().
+		SetContext(ctx).
+		SetResult(&res).
+		SetBody(req).
+		Post("/files")
+	if err != nil {
+		return nil, err
+	}
+	if r.IsError() {
+		return nil, newApiError(r)
+	}
+	return &res, nil
+}
@@ -0,0 +1,197 @@
+// Package bandwidth limits the download speed of the export with a token bucket. The limit can be lifted
+// during daily time windows, e.g. at night, so that exports can run continuously without saturating the
+// network during the day.
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/time/rate"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minBurst is the minimum size of the bucket, it's the most read at once from a limited stream
+const minBurst = 32 * 1024
+
+// Window is a daily time range, from Start to End since midnight in local time. It wraps around
+// midnight when End is before Start.
+type Window struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseWindow parses a time range like 00:00-06:00 or 22:00-06:00
+func ParseWindow(value string) (Window, error) {
+	startValue, endValue, ok := strings.Cut(value, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", value)
+	}
+	start, err := parseClock(startValue)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid time window %q: %w", value, err)
+	}
+	end, err := parseClock(endValue)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid time window %q: %w", value, err)
+	}
+	return Window{Start: start, End: end}, nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		// 24:00 is accepted as the end of the day
+		if strings.TrimSpace(value) == "24:00" {
+			return 24 * time.Hour, nil
+		}
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w Window) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", int(w.Start.Hours()), int(w.Start.Minutes())%60, int(w.End.Hours()), int(w.End.Minutes())%60)
+}
+
+// ParseRate parses a speed in bytes per second like 500K, 2.5MB or 1MB/s, units are decimal
+func ParseRate(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(s, "/S")
+	s = strings.TrimSuffix(s, "B")
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1e3
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1e6
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1e9
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid bandwidth %q, expected a speed like 500K or 2MB", value)
+	}
+	return int64(number * multiplier), nil
+}
+
+// Limiter limits the speed of the streams read through it, except during its full speed windows.
+// All its methods can be called on a nil Limiter, which doesn't limit anything.
+type Limiter struct {
+	limiter *rate.Limiter
+	windows []Window
+	now     func() time.Time
+}
+
+// NewLimiter returns a limiter to bytesPerSec outside the windows, or nil if bytesPerSec isn't positive
+func NewLimiter(bytesPerSec int64, windows []Window) *Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	burst := int(bytesPerSec)
+	if burst < minBurst {
+		burst = minBurst
+	}
+	return &Limiter{limiter: rate.NewLimiter(rate.Limit(bytesPerSec), burst), windows: windows, now: time.Now}
+}
+
+// Limited reports whether the limit applies right now
+func (l *Limiter) Limited() bool {
+	if l == nil {
+		return false
+	}
+	now := l.now()
+	for _, w := range l.windows {
+		if w.Contains(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reader returns r, limited to the speed of the limiter
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiter: l}
+}
+
+type reader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if !r.limiter.Limited() {
+		return r.r.Read(p)
+	}
+	if burst := r.limiter.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.limiter.WaitN(r.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+// NewTransport returns a transport which limits the response bodies to the speed of the limiter of the
+// request context
+func NewTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if limiter := FromContext(req.Context()); limiter != nil {
+		resp.Body = readCloser{Reader: limiter.Reader(req.Context(), resp.Body), Closer: resp.Body}
+	}
+	return resp, nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which carries the limiter
+func NewContext(ctx context.Context, l *Limiter) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the limiter of the context, or nil if it has none
+func FromContext(ctx context.Context) *Limiter {
+	l, _ := ctx.Value(contextKey{}).(*Limiter)
+	return l
+}
@@ -0,0 +1,134 @@
+package bandwidth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := map[string]int64{
+		"0":      0,
+		"1000":   1000,
+		"500K":   500_000,
+		"500kb":  500_000,
+		"2.5MB":  2_500_000,
+		"1MB/s":  1_000_000,
+		"1G":     1_000_000_000,
+		" 10 M ": 10_000_000,
+	}
+	for value, want := range tests {
+		got, err := ParseRate(value)
+		if err != nil || got != want {
+			t.Errorf("ParseRate(%q) = %d, %v, want %d", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "fast", "-1M", "10X"} {
+		if _, err := ParseRate(value); err == nil {
+			t.Errorf("ParseRate(%q) expected an error", value)
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2024, 3, 1, hour, minute, 0, 0, time.Local)
+	}
+	night, err := ParseWindow("00:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !night.Contains(at(0, 0)) || !night.Contains(at(5, 59)) || night.Contains(at(6, 0)) || night.Contains(at(23, 0)) {
+		t.Errorf("unexpected range of %s", night)
+	}
+	overnight, err := ParseWindow("22:30-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !overnight.Contains(at(23, 0)) || !overnight.Contains(at(2, 0)) || overnight.Contains(at(22, 0)) || overnight.Contains(at(12, 0)) {
+		t.Errorf("unexpected range of %s", overnight)
+	}
+	evening, err := ParseWindow("18:00-24:00")
+	if err != nil || !evening.Contains(at(23, 59)) || evening.Contains(at(0, 0)) {
+		t.Errorf("unexpected range of %s: %v", evening, err)
+	}
+	for _, value := range []string{"", "00:00", "6-8", "25:00-26:00"} {
+		if _, err = ParseWindow(value); err == nil {
+			t.Errorf("ParseWindow(%q) expected an error", value)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	var nilLimiter *Limiter
+	if nilLimiter.Limited() || NewLimiter(0, nil) != nil {
+		t.Fatalf("expected no limit")
+	}
+
+	limiter := NewLimiter(minBurst, nil)
+	data := make([]byte, 3*minBurst)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, limiter.Reader(context.Background(), bytes.NewReader(data)))
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("copied %d: %v", n, err)
+	}
+	// the first burst is free, the rest takes a second per burst
+	if elapsed := time.Since(start); elapsed < 1500*time.Millisecond {
+		t.Errorf("expected the read to be limited, it took %s", elapsed)
+	}
+
+	window, _ := ParseWindow("00:00-24:00")
+	limiter = NewLimiter(1, []Window{window})
+	if limiter.Limited() {
+		t.Errorf("expected no limit during the full speed window")
+	}
+	start = time.Now()
+	if _, err = io.Copy(io.Discard, limiter.Reader(context.Background(), bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected full speed, it took %s", elapsed)
+	}
+}
+
+func TestLimiterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	limiter := NewLimiter(1, nil)
+	r := limiter.Reader(ctx, bytes.NewReader(make([]byte, 2*minBurst)))
+	buf := make([]byte, minBurst)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := r.Read(buf); err == nil {
+		t.Errorf("expected the read to fail once the context is cancelled")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(make([]byte, 2*minBurst))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	ctx := NewContext(context.Background(), NewLimiter(minBurst, nil))
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("expected the response to be limited, it took %s", elapsed)
+	}
+}
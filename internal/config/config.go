@@ -0,0 +1,307 @@
+// Package config locates the config file of the cli and binds its settings to the flags they're the defaults of.
+// A setting is taken from its flag when it's set, then from its ENTE_ environment variable, then from the
+// config file, and from the default of the flag otherwise.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvPrefix is the prefix of the environment variables of the settings, e.g. ENTE_LOG_LEVEL for log.level
+	EnvPrefix = "ENTE"
+	// FileEnv is the environment variable with the path of the config file
+	FileEnv = "ENTE_CONFIG"
+)
+
+// fileNames are the names of the config file looked up in Dir, in order
+var fileNames = []string{"config.yaml", "config.yml", "config.toml"}
+
+// Setting is a key of the config file
+type Setting struct {
+	// Key is the dotted path of the setting in the file, e.g. export.max-bandwidth
+	Key string
+	// Flag is the flag the setting is the default of, nil for the sections which are only in the file
+	Flag *pflag.Flag
+	// Check validates a value of the setting, each of them for lists
+	Check func(value string) error
+	// Decode validates the sections which have no flag, from the settings of v
+	Decode func(v *viper.Viper) error
+}
+
+// Kind is the type of the flag of the setting, e.g. string, bool, duration or stringArray, or section
+func (s Setting) Kind() string {
+	if s.Flag == nil {
+		return "section"
+	}
+	return s.Flag.Value.Type()
+}
+
+// Usage describes the setting, it's the usage of its flag
+func (s Setting) Usage() string {
+	if s.Flag == nil {
+		return ""
+	}
+	return s.Flag.Usage
+}
+
+func (s Setting) isList() bool {
+	return strings.HasSuffix(s.Kind(), "Array") || strings.HasSuffix(s.Kind(), "Slice")
+}
+
+// Dir returns the directory of the config file, ente in the user's config directory,
+// e.g. $XDG_CONFIG_HOME/ente or ~/.config/ente on Linux
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ente"), nil
+}
+
+// FindFile returns the path of the config file: path if it's set, then $ENTE_CONFIG, then the first of
+// config.yaml, config.yml and config.toml which exists in Dir. Without any, it's config.yaml in Dir.
+func FindFile(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	if path = os.Getenv(FileEnv); path != "" {
+		return path, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	for _, name := range fileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return filepath.Join(dir, name), nil
+		}
+	}
+	return filepath.Join(dir, fileNames[0]), nil
+}
+
+// EnvName returns the environment variable which overrides the setting
+func EnvName(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// Bind makes the flags of the settings the defaults of their keys in v, and enables their environment variables
+func Bind(v *viper.Viper, settings []Setting) error {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	v.AutomaticEnv()
+	for _, s := range settings {
+		if s.Flag == nil {
+			continue
+		}
+		if err := v.BindPFlag(s.Key, s.Flag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load reads the config file at path into v, a missing file is not an error
+func Load(v *viper.Viper, path string) error {
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read the config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Lookup returns the setting with the key
+func Lookup(settings []Setting, key string) (Setting, bool) {
+	key = strings.ToLower(key)
+	for _, s := range settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Value returns the effective value of the setting in v
+func Value(v *viper.Viper, s Setting) interface{} {
+	switch {
+	case s.Flag == nil:
+		return v.Get(s.Key)
+	case s.isList():
+		return v.GetStringSlice(s.Key)
+	}
+	switch s.Kind() {
+	case "bool":
+		return v.GetBool(s.Key)
+	case "duration":
+		return v.GetDuration(s.Key).String()
+	case "int", "int64":
+		return v.GetInt64(s.Key)
+	}
+	return v.GetString(s.Key)
+}
+
+// Source returns where the effective value of the setting in v comes from: flag, env, file or default
+func Source(v *viper.Viper, s Setting) string {
+	if s.Flag != nil && s.Flag.Changed {
+		return "flag"
+	}
+	// empty variables are ignored, like viper does
+	if os.Getenv(EnvName(s.Key)) != "" && s.Flag != nil {
+		return "env"
+	}
+	if v.InConfig(s.Key) {
+		return "file"
+	}
+	return "default"
+}
+
+// Validate checks the settings of v, which only holds a config file, and returns all the problems found
+func Validate(v *viper.Viper, settings []Setting) []error {
+	var problems []error
+	checked := make(map[string]bool)
+	keys := v.AllKeys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		s, ok := Lookup(settings, key)
+		if !ok {
+			// the keys of the sections are listed one by one when they're maps
+			if section, _, found := strings.Cut(key, "."); found {
+				if s, ok = Lookup(settings, section); ok && s.Flag != nil {
+					ok = false
+				}
+			}
+		}
+		if !ok {
+			problems = append(problems, fmt.Errorf("unknown setting %s", key))
+			continue
+		}
+		if checked[s.Key] {
+			continue
+		}
+		checked[s.Key] = true
+		if err := s.validate(v); err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", s.Key, err))
+		}
+	}
+	return problems
+}
+
+func (s Setting) validate(v *viper.Viper) error {
+	if s.Flag == nil {
+		if s.Decode == nil {
+			return nil
+		}
+		return s.Decode(v)
+	}
+	var values []string
+	var err error
+	if s.isList() {
+		values, err = cast.ToStringSliceE(v.Get(s.Key))
+	} else {
+		var value string
+		value, err = cast.ToStringE(v.Get(s.Key))
+		values = []string{value}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s value: %w", s.Kind(), err)
+	}
+	_, err = s.parse(values)
+	return err
+}
+
+// parse validates the values of the setting given as strings, and returns the value to store in the file
+func (s Setting) parse(values []string) (interface{}, error) {
+	if s.isList() {
+		for _, value := range values {
+			if s.Check != nil {
+				if err := s.Check(value); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return values, nil
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("%s takes a single value", s.Key)
+	}
+	value := values[0]
+	var parsed interface{} = value
+	var err error
+	switch s.Kind() {
+	case "bool":
+		parsed, err = strconv.ParseBool(value)
+	case "duration":
+		// durations are kept as written, e.g. 30s
+		_, err = time.ParseDuration(value)
+	case "int", "int64":
+		parsed, err = strconv.ParseInt(value, 10, 64)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q", s.Kind(), value)
+	}
+	if s.Check != nil && value != "" {
+		if err := s.Check(value); err != nil {
+			return nil, err
+		}
+	}
+	return parsed, nil
+}
+
+// Set validates the values of the setting and writes them to the config file at path, which is created if
+// it doesn't exist. The other settings of the file are kept, its comments aren't. The file can hold credentials,
+// e.g. in notify.urls or http.proxy, it's only readable by the user.
+func Set(path string, settings []Setting, key string, values ...string) error {
+	s, ok := Lookup(settings, key)
+	if !ok {
+		return fmt.Errorf("unknown setting %s", key)
+	}
+	if s.Flag == nil {
+		return fmt.Errorf("%s can only be edited in the config file %s", s.Key, path)
+	}
+	value, err := s.parse(values)
+	if err != nil {
+		return err
+	}
+	v := viper.New()
+	if err := Load(v, path); err != nil {
+		return err
+	}
+	v.Set(s.Key, value)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeConfigFile(v, path)
+}
+
+// writeConfigFile writes the settings of v to path with the mode 0600. viper creates the files it writes with the
+// mode 0644, so they're written to a temporary file first, which also replaces the file atomically.
+func writeConfigFile(v *viper.Viper, path string) error {
+	// the extension of the file is its format
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	_ = tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err = v.WriteConfigAs(tmp.Name()); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
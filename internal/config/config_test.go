@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSettings() []Setting {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.String("log-level", "info", "")
+	flags.Duration("timeout", 30*time.Second, "")
+	flags.Bool("daemon", false, "")
+	flags.StringArray("window", nil, "")
+	checkWindow := func(value string) error {
+		if !strings.Contains(value, "-") {
+			return fmt.Errorf("invalid window %q", value)
+		}
+		return nil
+	}
+	return []Setting{
+		{Key: "log.level", Flag: flags.Lookup("log-level")},
+		{Key: "http.timeout", Flag: flags.Lookup("timeout")},
+		{Key: "export.daemon", Flag: flags.Lookup("daemon")},
+		{Key: "export.window", Flag: flags.Lookup("window"), Check: checkWindow},
+		{Key: "accounts", Decode: func(v *viper.Viper) error {
+			if _, ok := v.Get("accounts").([]interface{}); !ok {
+				return fmt.Errorf("expected a list")
+			}
+			return nil
+		}},
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(FileEnv, "")
+	dir, err := Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := FindFile(""); path != filepath.Join(dir, "config.yaml") {
+		t.Errorf("expected config.yaml by default, got %s", path)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "config.toml"), "")
+	if path, _ := FindFile(""); path != filepath.Join(dir, "config.toml") {
+		t.Errorf("expected the existing config.toml, got %s", path)
+	}
+	t.Setenv(FileEnv, "/etc/ente.yaml")
+	if path, _ := FindFile(""); path != "/etc/ente.yaml" {
+		t.Errorf("expected the file of %s, got %s", FileEnv, path)
+	}
+	if path, _ := FindFile("ente.toml"); path != "ente.toml" {
+		t.Errorf("expected the explicit file, got %s", path)
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	settings := testSettings()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "log:\n  level: warn\n")
+	v := viper.New()
+	if err := Bind(v, settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(v, path); err != nil {
+		t.Fatal(err)
+	}
+	level, _ := Lookup(settings, "log.level")
+	timeout, _ := Lookup(settings, "http.timeout")
+	if Value(v, timeout) != "30s" || Source(v, timeout) != "default" {
+		t.Errorf("expected the default of the flag, got %v from %s", Value(v, timeout), Source(v, timeout))
+	}
+	if Value(v, level) != "warn" || Source(v, level) != "file" {
+		t.Errorf("expected the value of the file, got %v from %s", Value(v, level), Source(v, level))
+	}
+	t.Setenv("ENTE_LOG_LEVEL", "error")
+	if Value(v, level) != "error" || Source(v, level) != "env" {
+		t.Errorf("expected the environment variable, got %v from %s", Value(v, level), Source(v, level))
+	}
+	if err := level.Flag.Value.Set("debug"); err != nil {
+		t.Fatal(err)
+	}
+	level.Flag.Changed = true
+	if Value(v, level) != "debug" || Source(v, level) != "flag" {
+		t.Errorf("expected the flag, got %v from %s", Value(v, level), Source(v, level))
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	if err := Load(viper.New(), filepath.Join(t.TempDir(), "config.yaml")); err != nil {
+		t.Errorf("expected no error for a missing file, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, `
+log:
+  level: debug
+  colour: true
+http:
+  timeout: 30
+export:
+  daemon: true
+  window: ["00:00-06:00", "noon"]
+accounts:
+  email: a@b.c
+`)
+	v := viper.New()
+	if err := Load(v, path); err != nil {
+		t.Fatal(err)
+	}
+	var problems []string
+	for _, problem := range Validate(v, testSettings()) {
+		problems = append(problems, problem.Error())
+	}
+	expected := []string{
+		"accounts: expected a list",
+		`export.window: invalid window "noon"`,
+		`http.timeout: invalid duration "30"`,
+		"unknown setting log.colour",
+	}
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected problems:\n%s", strings.Join(problems, "\n"))
+	}
+}
+
+func TestSet(t *testing.T) {
+	for _, name := range []string{"config.yaml", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			settings := testSettings()
+			path := filepath.Join(t.TempDir(), "ente", name)
+			if err := Set(path, settings, "log.level", "debug"); err != nil {
+				t.Fatal(err)
+			}
+			if err := Set(path, settings, "export.window", "00:00-06:00", "22:00-24:00"); err != nil {
+				t.Fatal(err)
+			}
+			if err := Set(path, settings, "export.daemon", "true"); err != nil {
+				t.Fatal(err)
+			}
+			for _, invalid := range [][]string{{"http.timeout", "30"}, {"export.window", "noon"}, {"log.level"}, {"log.colour", "true"}, {"accounts", "a"}} {
+				if err := Set(path, settings, invalid[0], invalid[1:]...); err == nil {
+					t.Errorf("expected an error setting %v", invalid)
+				}
+			}
+			v := viper.New()
+			if err := Load(v, path); err != nil {
+				t.Fatal(err)
+			}
+			if problems := Validate(v, settings); len(problems) > 0 {
+				t.Errorf("unexpected problems %v", problems)
+			}
+			if v.GetString("log.level") != "debug" || !v.GetBool("export.daemon") ||
+				strings.Join(v.GetStringSlice("export.window"), ",") != "00:00-06:00,22:00-24:00" {
+				t.Errorf("unexpected settings %v", v.AllSettings())
+			}
+		})
+	}
+}
+
+func TestSetFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix file modes on windows")
+	}
+	settings := testSettings()
+	dir := t.TempDir()
+	created := filepath.Join(dir, "created.yaml")
+	existing := filepath.Join(dir, "existing.yaml")
+	if err := os.WriteFile(existing, []byte("log:\n  level: warn\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{created, existing} {
+		if err := Set(path, settings, "log.level", "debug"); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("expected %s to be only readable by the user, got %v", path, info.Mode().Perm())
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("expected the temporary files to be removed, got %v", entries)
+	}
+}
@@ -2,12 +2,13 @@ package crypto
 
 import (
 	"bufio"
+	"crypto/rand"
 	"errors"
 	"github.com/ente-io/cli/utils/encoding"
 	"golang.org/x/crypto/nacl/box"
 	"golang.org/x/crypto/nacl/secretbox"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 )
 
@@ -98,6 +99,21 @@ func SecretBoxOpenBase64(cipher string, nonce string, k []byte) ([]byte, error)
 	return SecretBoxOpen(encoding.DecodeBase64(cipher), encoding.DecodeBase64(nonce), k)
 }
 
+// SecretBoxSeal encrypts the given message using XSalsa20-Poly1305 with a random nonce.
+// It returns the cipher text and the nonce used for encryption.
+func SecretBoxSeal(m []byte, k []byte) ([]byte, []byte, error) {
+	if len(k) != 32 {
+		return nil, nil, invalidKey
+	}
+	var nonce [24]byte
+	var key [32]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, nil, err
+	}
+	copy(key[:], k)
+	return secretbox.Seal(nil, m, &nonce, &key), nonce[:], nil
+}
+
 func SecretBoxOpen(c []byte, n []byte, k []byte) ([]byte, error) {
 	// Check for valid lengths of nonce and key
 	if len(n) != 24 || len(k) != 32 {
@@ -152,6 +168,74 @@ func SealedBoxOpen(cipherText, publicKey, masterSecret []byte) ([]byte, error) {
 	return decrypted, nil
 }
 
+// SealedBoxSeal encrypts the given message for the owner of the given public key
+// using an ephemeral key pair, similar to libsodium's crypto_box_seal.
+func SealedBoxSeal(m []byte, publicKey []byte) ([]byte, error) {
+	if len(publicKey) != 32 {
+		return nil, invalidKey
+	}
+	var recipient [32]byte
+	copy(recipient[:], publicKey)
+	return box.SealAnonymous(nil, m, &recipient, rand.Reader)
+}
+
+// EncryptFile encrypts the file at plainFilePath using the secretstream construction
+// in chunks of decryptionBufferSize and writes the result to encryptedFilePath.
+// It returns the stream header which is required for decryption.
+func EncryptFile(plainFilePath string, encryptedFilePath string, key []byte) ([]byte, error) {
+	inputFile, err := os.Open(plainFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer inputFile.Close()
+
+	outputFile, err := os.Create(encryptedFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer outputFile.Close()
+
+	encryptor, header, err := NewEncryptor(key)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(inputFile)
+	writer := bufio.NewWriter(outputFile)
+	buf := make([]byte, decryptionBufferSize)
+	next := make([]byte, decryptionBufferSize)
+	readCount, err := io.ReadFull(reader, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	for {
+		// read ahead to find out if the current chunk is the last one
+		nextCount, nextErr := io.ReadFull(reader, next)
+		if nextErr != nil && nextErr != io.EOF && nextErr != io.ErrUnexpectedEOF {
+			return nil, nextErr
+		}
+		tag := byte(TagMessage)
+		if nextCount == 0 {
+			tag = TagFinal
+		}
+		cipher, pushErr := encryptor.Push(buf[:readCount], tag)
+		if pushErr != nil {
+			return nil, pushErr
+		}
+		if _, err := writer.Write(cipher); err != nil {
+			return nil, err
+		}
+		if tag == TagFinal {
+			break
+		}
+		buf, next = next, buf
+		readCount = nextCount
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
 func DecryptFile(encryptedFilePath string, decryptedFilePath string, key, nonce []byte) error {
 	inputFile, err := os.Open(encryptedFilePath)
 	if err != nil {
@@ -177,7 +261,7 @@ func DecryptFile(encryptedFilePath string, decryptedFilePath string, key, nonce
 	for {
 		readCount, err := reader.Read(buf)
 		if err != nil && err != io.EOF {
-			log.Println("Failed to read from input file", err)
+			slog.Error("failed to read from input file", "error", err)
 			return err
 		}
 		if readCount == 0 {
@@ -185,12 +269,12 @@ func DecryptFile(encryptedFilePath string, decryptedFilePath string, key, nonce
 		}
 		n, tag, errErr := decryptor.Pull(buf[:readCount])
 		if errErr != nil && errErr != io.EOF {
-			log.Println("Failed to read from decoder", errErr)
+			slog.Error("failed to read from decoder", "error", errErr)
 			return errErr
 		}
 
 		if _, err := writer.Write(n); err != nil {
-			log.Println("Failed to write to output file", err)
+			slog.Error("failed to write to output file", "error", err)
 			return err
 		}
 		if errErr == io.EOF {
@@ -201,7 +285,7 @@ func DecryptFile(encryptedFilePath string, decryptedFilePath string, key, nonce
 		}
 	}
 	if err := writer.Flush(); err != nil {
-		log.Println("Failed to flush writer", err)
+		slog.Error("failed to flush writer", "error", err)
 		return err
 	}
 	return nil
@@ -237,14 +321,14 @@ func DecryptFile(encryptedFilePath string, decryptedFilePath string, key, nonce
 //	for {
 //		n, errErr := decoder.Read(buf)
 //		if errErr != nil && errErr != io.EOF {
-//			log.Println("Failed to read from decoder", errErr)
+//			slog.Error("failed to read from decoder", "error", errErr)
 //			return errErr
 //		}
 //		if n == 0 {
 //			break
 //		}
 //		if _, err := writer.Write(buf[:n]); err != nil {
-//			log.Println("Failed to write to output file", err)
+//			slog.Error("failed to write to output file", "error", err)
 //			return err
 //		}
 //		if errErr == io.EOF {
@@ -252,7 +336,7 @@ func DecryptFile(encryptedFilePath string, decryptedFilePath string, key, nonce
 //		}
 //	}
 //	if err := writer.Flush(); err != nil {
-//		log.Println("Failed to flush writer", err)
+//		slog.Error("failed to flush writer", "error", err)
 //		return err
 //	}
 //	return nil
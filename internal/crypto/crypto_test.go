@@ -1,8 +1,12 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -86,3 +90,90 @@ func TestSecretBoxOpenBase64(t *testing.T) {
 		t.Fatalf("Decrypted text : %s does not match the expected text: %s", string(plainText), expectedSealedText)
 	}
 }
+
+func TestEncryptAndDecryptFile(t *testing.T) {
+	key := NewStreamKey()
+	// span multiple chunks to verify that only the last one is tagged as final
+	data := make([]byte, decryptionBufferSize*2+1024)
+	_, _ = rand.Read(data)
+	dir := t.TempDir()
+	plainPath := filepath.Join(dir, "plain")
+	encPath := filepath.Join(dir, "enc")
+	decPath := filepath.Join(dir, "dec")
+	if err := os.WriteFile(plainPath, data, 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	header, err := EncryptFile(plainPath, encPath, key)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	if err = DecryptFile(encPath, decPath, key, header); err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	decrypted, err := os.ReadFile(decPath)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("Decrypted data does not match the original data")
+	}
+}
+
+func TestSecretBoxSeal(t *testing.T) {
+	key := NewStreamKey()
+	cipher, nonce, err := SecretBoxSeal([]byte("plain_text"), key)
+	if err != nil {
+		t.Fatalf("Failed to encrypt: %v", err)
+	}
+	plainText, err := SecretBoxOpen(cipher, nonce, key)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if string(plainText) != "plain_text" {
+		t.Fatalf("Decrypted text : %s does not match the expected text: %s", string(plainText), "plain_text")
+	}
+}
+
+func TestStreamWriterAndReader(t *testing.T) {
+	key := NewStreamKey()
+	data := make([]byte, decryptionBufferSize*2+1024)
+	_, _ = rand.Read(data)
+	var encrypted bytes.Buffer
+	writer, err := NewStreamWriter(&encrypted, key)
+	if err != nil {
+		t.Fatalf("Failed to create writer: %v", err)
+	}
+	// write in uneven pieces to cross the chunk boundaries
+	for offset := 0; offset < len(data); offset += 1000 * 1000 {
+		end := offset + 1000*1000
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err = writer.Write(data[offset:end]); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	reader, err := NewStreamReader(bytes.NewReader(encrypted.Bytes()), key)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	decrypted, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("Decrypted data does not match the original data")
+	}
+	// dropping the final chunk must be detected
+	truncated := encrypted.Bytes()[:StreamHeaderBytes+decryptionBufferSize+XChaCha20Poly1305IetfABYTES]
+	reader, err = NewStreamReader(bytes.NewReader(truncated), key)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if _, err = io.ReadAll(reader); err != ErrTruncatedStream {
+		t.Fatalf("Expected ErrTruncatedStream, got %v", err)
+	}
+}
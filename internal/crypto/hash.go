@@ -0,0 +1,26 @@
+package crypto
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"os"
+
+	"github.com/minio/blake2b-simd"
+)
+
+// ComputeFileHash returns the base64 encoded BLAKE2b-512 hash of the file content.
+// This matches the hash computed by ente clients (crypto_generichash with the max output length),
+// which is stored in the file metadata and used for de-duplication.
+func ComputeFileHash(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hasher := blake2b.New512()
+	if _, err = io.Copy(hasher, bufio.NewReaderSize(file, decryptionBufferSize)); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(hasher.Sum(nil)), nil
+}
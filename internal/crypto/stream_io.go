@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrTruncatedStream is returned when an encrypted stream ends before its final chunk
+var ErrTruncatedStream = errors.New("encrypted stream is truncated")
+
+// streamWriter encrypts everything written to it using the secretstream construction.
+// The stream header is written first, followed by chunks of decryptionBufferSize.
+type streamWriter struct {
+	w         io.Writer
+	encryptor Encryptor
+	buf       []byte
+	closed    bool
+}
+
+// NewStreamWriter returns a writer which encrypts the data written to it with the given key and writes
+// the result to w. Close must be called to write the final chunk, it doesn't close w.
+func NewStreamWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	encryptor, header, err := NewEncryptor(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(header); err != nil {
+		return nil, err
+	}
+	return &streamWriter{w: w, encryptor: encryptor, buf: make([]byte, 0, decryptionBufferSize)}, nil
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed stream")
+	}
+	written := 0
+	for len(p) > 0 {
+		// a full chunk is only pushed once more data arrives, so that the last chunk can be tagged as final
+		if len(s.buf) == decryptionBufferSize {
+			if err := s.push(TagMessage); err != nil {
+				return written, err
+			}
+		}
+		n := copy(s.buf[len(s.buf):decryptionBufferSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (s *streamWriter) push(tag byte) error {
+	cipher, err := s.encryptor.Push(s.buf, tag)
+	if err != nil {
+		return err
+	}
+	s.buf = s.buf[:0]
+	_, err = s.w.Write(cipher)
+	return err
+}
+
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.push(TagFinal)
+}
+
+// streamReader decrypts a stream written by streamWriter or EncryptFile
+type streamReader struct {
+	r         io.Reader
+	decryptor Decryptor
+	cipher    []byte
+	plain     []byte
+	done      bool
+}
+
+// NewStreamReader reads the stream header from r and returns a reader for the decrypted content.
+// Reading fails with ErrTruncatedStream if r ends before the final chunk.
+func NewStreamReader(r io.Reader, key []byte) (io.Reader, error) {
+	header := make([]byte, StreamHeaderBytes)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrTruncatedStream
+		}
+		return nil, err
+	}
+	decryptor, err := NewDecryptor(key, header)
+	if err != nil {
+		return nil, err
+	}
+	return &streamReader{
+		r:         r,
+		decryptor: decryptor,
+		cipher:    make([]byte, decryptionBufferSize+XChaCha20Poly1305IetfABYTES),
+	}, nil
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(s.r, s.cipher)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			if errors.Is(err, io.EOF) {
+				return 0, ErrTruncatedStream
+			}
+			return 0, err
+		}
+		plain, tag, err := s.decryptor.Pull(s.cipher[:n])
+		if err != nil {
+			return 0, err
+		}
+		if tag == TagFinal {
+			s.done = true
+		} else if n < len(s.cipher) {
+			return 0, ErrTruncatedStream
+		}
+		s.plain = plain
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+// DecryptedSize returns the size of the plain content of a secretstream encrypted with chunks of
+// decryptionBufferSize, excluding the header.
+func DecryptedSize(encryptedSize int64) int64 {
+	chunkSize := int64(decryptionBufferSize + XChaCha20Poly1305IetfABYTES)
+	chunks := (encryptedSize + chunkSize - 1) / chunkSize
+	return encryptedSize - chunks*XChaCha20Poly1305IetfABYTES
+}
@@ -0,0 +1,232 @@
+// Package hooks notifies a webhook and/or runs a local command on the events of an export, e.g. to start
+// downstream jobs when files are added to the export. The event is sent as a JSON payload, in the body of
+// a POST request to the webhook and on the standard input of the command.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type EventType string
+
+const (
+	SyncStarted  EventType = "sync.started"
+	SyncFinished EventType = "sync.finished"
+	AlbumCreated EventType = "album.created"
+	AlbumRenamed EventType = "album.renamed"
+	AlbumDeleted EventType = "album.deleted"
+	FileAdded    EventType = "file.added"
+	FileRemoved  EventType = "file.removed"
+)
+
+var EventTypes = []EventType{SyncStarted, SyncFinished, AlbumCreated, AlbumRenamed, AlbumDeleted, FileAdded, FileRemoved}
+
+// Event is the payload of a hook. Paths are relative to the export destination of the account.
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Account string    `json:"account,omitempty"`
+	App     string    `json:"app,omitempty"`
+	AlbumID int64     `json:"albumID,omitempty"`
+	Album   string    `json:"album,omitempty"`
+	Folder  string    `json:"folder,omitempty"`
+	// OldFolder is the previous folder of a renamed album
+	OldFolder string   `json:"oldFolder,omitempty"`
+	FileID    int64    `json:"fileID,omitempty"`
+	Files     []string `json:"files,omitempty"`
+	// Status and Result describe the outcome of a finished sync
+	Status string `json:"status,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Result any    `json:"result,omitempty"`
+}
+
+type Options struct {
+	// URL of the webhook
+	URL string
+	// Command is run by the shell for every event
+	Command string
+	// Events to send, all of them when empty
+	Events []EventType
+	// Timeout of each delivery
+	Timeout time.Duration
+	// Transport of the webhook requests, the default one when it's nil
+	Transport http.RoundTripper
+}
+
+// ParseEvents parses a comma separated list of event types
+func ParseEvents(value string) ([]EventType, error) {
+	var events []EventType
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		known := false
+		for _, eventType := range EventTypes {
+			if string(eventType) == name {
+				events = append(events, eventType)
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown hook event %q", name)
+		}
+	}
+	return events, nil
+}
+
+// Dispatcher delivers the events in the background, in the order they were fired. A failed delivery is
+// logged with the default logger and doesn't affect the export. All its methods can be called on a nil Dispatcher, which does nothing.
+type Dispatcher struct {
+	opts    Options
+	client  *http.Client
+	events  chan Event
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// New returns a dispatcher for the options, or nil if neither a webhook nor a command is configured.
+// It must be closed to deliver the pending events.
+func New(opts Options) *Dispatcher {
+	if opts.URL == "" && opts.Command == "" {
+		return nil
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	d := &Dispatcher{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout, Transport: opts.Transport},
+		events: make(chan Event, 100),
+		done:   make(chan struct{}),
+	}
+	go d.run()
+	return d
+}
+
+// Fire queues the event for delivery. It never blocks the caller: when the queue is full, because the
+// webhook or the command is slower than the export, the event is dropped and logged.
+func (d *Dispatcher) Fire(event Event) {
+	if d == nil || !d.wants(event.Type) {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	select {
+	case d.events <- event:
+	default:
+		dropped := d.dropped.Add(1)
+		slog.Warn("hook queue is full, dropping the event", "event", event.Type, "dropped", dropped)
+	}
+}
+
+// Dropped returns the number of events which were dropped because the queue was full
+func (d *Dispatcher) Dropped() int64 {
+	if d == nil {
+		return 0
+	}
+	return d.dropped.Load()
+}
+
+// Close delivers the queued events and stops the dispatcher
+func (d *Dispatcher) Close() {
+	if d == nil {
+		return
+	}
+	close(d.events)
+	<-d.done
+	if dropped := d.dropped.Load(); dropped > 0 {
+		slog.Warn("some hook events were dropped", "dropped", dropped)
+	}
+}
+
+func (d *Dispatcher) wants(eventType EventType) bool {
+	if len(d.opts.Events) == 0 {
+		return true
+	}
+	for _, e := range d.opts.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Dispatcher) run() {
+	defer close(d.done)
+	for event := range d.events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			slog.Error("failed to encode hook event", "event", event.Type, "error", err)
+			continue
+		}
+		if d.opts.URL != "" {
+			if err = d.post(payload); err != nil {
+				slog.Warn("webhook failed", "event", event.Type, "error", err)
+			}
+		}
+		if d.opts.Command != "" {
+			if err = d.runCommand(event.Type, payload); err != nil {
+				slog.Warn("hook command failed", "event", event.Type, "error", err)
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) post(payload []byte) error {
+	resp, err := d.client.Post(d.opts.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response %s", resp.Status)
+	}
+	return nil
+}
+
+func (d *Dispatcher) runCommand(eventType EventType, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.Timeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", d.opts.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", d.opts.Command)
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Env = append(os.Environ(), "ENTE_HOOK_EVENT="+string(eventType))
+	out, err := cmd.CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which carries the dispatcher
+func NewContext(ctx context.Context, d *Dispatcher) context.Context {
+	return context.WithValue(ctx, contextKey{}, d)
+}
+
+// FromContext returns the dispatcher of the context, or nil if it has none
+func FromContext(ctx context.Context) *Dispatcher {
+	d, _ := ctx.Value(contextKey{}).(*Dispatcher)
+	return d
+}
@@ -0,0 +1,139 @@
+package hooks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewWithoutHooks(t *testing.T) {
+	d := New(Options{})
+	if d != nil {
+		t.Fatalf("expected no dispatcher without a webhook or command")
+	}
+	// a nil dispatcher does nothing
+	d.Fire(Event{Type: FileAdded})
+	d.Close()
+}
+
+func TestWebhook(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("invalid payload: %v", err)
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	d := New(Options{URL: server.URL, Events: []EventType{FileAdded, FileRemoved}})
+	d.Fire(Event{Type: SyncStarted, Account: "a@example.org"})
+	d.Fire(Event{Type: FileAdded, AlbumID: 1, Files: []string{"Album/a.jpg"}})
+	d.Fire(Event{Type: FileRemoved, FileID: 2})
+	d.Close()
+
+	if len(received) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(received))
+	}
+	if received[0].Type != FileAdded || received[0].Files[0] != "Album/a.jpg" || received[0].Time.IsZero() {
+		t.Errorf("unexpected first event %+v", received[0])
+	}
+	if received[1].Type != FileRemoved || received[1].FileID != 2 {
+		t.Errorf("unexpected second event %+v", received[1])
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWebhookTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	d := New(Options{URL: server.URL, Transport: transport})
+	d.Fire(Event{Type: FileAdded})
+	d.Close()
+	if transport.requests != 1 {
+		t.Errorf("expected the webhook to use the transport of the options, got %d requests", transport.requests)
+	}
+}
+
+func TestFireDoesNotBlockOnHangingWebhook(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	d := New(Options{URL: server.URL, Timeout: time.Minute})
+	fired := make(chan struct{})
+	go func() {
+		defer close(fired)
+		for i := 0; i < 1000; i++ {
+			d.Fire(Event{Type: FileAdded, FileID: int64(i)})
+		}
+	}()
+	select {
+	case <-fired:
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("Fire blocked on the hanging webhook")
+	}
+	// one event is being delivered and at most the size of the queue is pending
+	if dropped := d.Dropped(); dropped < 1000-101 {
+		t.Errorf("expected the events over the queue to be dropped, got %d", dropped)
+	}
+	close(release)
+	d.Close()
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "events")
+	d := New(Options{Command: "echo $ENTE_HOOK_EVENT >> " + out + " && cat >> " + out})
+	d.Fire(Event{Type: AlbumCreated, Album: "Trip"})
+	d.Close()
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitN(string(data), "\n", 2)
+	if lines[0] != string(AlbumCreated) {
+		t.Errorf("expected the event type in the environment, got %q", lines[0])
+	}
+	var event Event
+	if err = json.Unmarshal([]byte(lines[1]), &event); err != nil || event.Album != "Trip" {
+		t.Errorf("unexpected payload %q: %v", lines[1], err)
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	events, err := ParseEvents("file.added, sync.finished")
+	if err != nil || len(events) != 2 || events[0] != FileAdded || events[1] != SyncFinished {
+		t.Errorf("unexpected events %v: %v", events, err)
+	}
+	if _, err = ParseEvents("file.updated"); err == nil {
+		t.Errorf("expected an error for an unknown event")
+	}
+}
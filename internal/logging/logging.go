@@ -0,0 +1,98 @@
+// Package logging sets up the structured logger of the cli and carries it through a context,
+// so that everything logged while exporting an account is tagged with that account.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type Options struct {
+	// Level is one of debug, info, warn or error
+	Level  string
+	Format string
+	// File is the path of the log file, logs are written to Output if it's empty
+	File string
+	// Output defaults to stderr
+	Output io.Writer
+	// MaxSizeMB is the size at which the log file is rotated
+	MaxSizeMB int
+	// MaxBackups is the number of rotated files which are kept
+	MaxBackups int
+}
+
+type contextKey struct{}
+
+// New returns the logger for the options. The returned closer releases the log file, if any.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, nil, err
+	}
+	var w io.Writer = os.Stderr
+	if opts.Output != nil {
+		w = opts.Output
+	}
+	var closer io.Closer = io.NopCloser(nil)
+	if opts.File != "" {
+		file := &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSizeMB,
+			MaxBackups: opts.MaxBackups,
+			Compress:   true,
+		}
+		w, closer = file, file
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "", FormatText:
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		return nil, nil, fmt.Errorf("invalid log format %q, expected text or json", opts.Format)
+	}
+	return slog.New(handler), closer, nil
+}
+
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return level, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", value)
+	}
+	return level, nil
+}
+
+// NewContext returns a copy of ctx which carries the logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of the context. If the context has none, fallback is returned,
+// or the default logger if fallback is nil.
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return slog.Default()
+}
@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewWritesToFile(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "ente.log")
+	logger, closer, err := New(Options{Level: "warn", Format: FormatJSON, File: logFile, MaxSizeMB: 1})
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+	ctx := NewContext(context.Background(), logger.With("email", "a@b.c"))
+	FromContext(ctx, nil).Info("hidden")
+	FromContext(ctx, nil).Warn("shown")
+	if err = closer.Close(); err != nil {
+		t.Fatalf("Failed to close log file: %v", err)
+	}
+	data, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(data), "hidden") || !strings.Contains(string(data), `"msg":"shown","email":"a@b.c"`) {
+		t.Fatalf("Unexpected log file content: %s", data)
+	}
+}
+
+func TestFromContextFallback(t *testing.T) {
+	fallback := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	if FromContext(context.Background(), fallback) != fallback {
+		t.Errorf("Expected the fallback logger")
+	}
+	if FromContext(context.Background(), nil) != slog.Default() {
+		t.Errorf("Expected the default logger")
+	}
+	if _, _, err := New(Options{Format: "xml"}); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,95 @@
+// Package metrics defines the Prometheus metrics of the export, for monitoring exports which run on a schedule.
+// Accounts are labelled with their account key (app and user id) rather than their email.
+package metrics
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "ente"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	FilesDownloaded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_files_downloaded_total",
+		Help:      "Files downloaded and written to the export destination.",
+	}, []string{"account"})
+	FilesRemoved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_files_removed_total",
+		Help:      "Files removed from the export destination after they were deleted remotely.",
+	}, []string{"account"})
+	BytesDownloaded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_downloaded_bytes_total",
+		Help:      "Encrypted bytes downloaded while exporting.",
+	}, []string{"account"})
+	DecryptionFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_decryption_failures_total",
+		Help:      "Files which couldn't be decrypted.",
+	}, []string{"account"})
+	LivePhotoErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "export_live_photo_errors_total",
+		Help:      "Live photos which couldn't be unpacked.",
+	}, []string{"account"})
+	APIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "api_errors_total",
+		Help:      "Error responses of the API and of the file downloads, by status code.",
+	}, []string{"status"})
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retries_total",
+		Help:      "Retries of api requests, downloads, files and account syncs.",
+	}, []string{"kind"})
+	LastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "export_last_success_timestamp_seconds",
+		Help:      "Time of the last successful sync of the account.",
+	}, []string{"account"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		FilesDownloaded, FilesRemoved, BytesDownloaded, DecryptionFailures, LivePhotoErrors,
+		APIErrors, Retries, LastSuccess,
+	)
+}
+
+// Handler serves the metrics on /metrics and a liveness check on /healthz
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	return mux
+}
+
+// Reader adds the bytes read from r to BytesDownloaded
+type Reader struct {
+	r       io.Reader
+	counter prometheus.Counter
+}
+
+func NewReader(r io.Reader, account string) *Reader {
+	return &Reader{r: r, counter: BytesDownloaded.WithLabelValues(account)}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.counter.Add(float64(n))
+	return n, err
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+	get := func(p string) string {
+		resp, err := http.Get(server.URL + p)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", p, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Unexpected status %d for %s", resp.StatusCode, p)
+		}
+		return string(body)
+	}
+	if body := get("/healthz"); body != "ok\n" {
+		t.Errorf("Unexpected health check %q", body)
+	}
+	APIErrors.WithLabelValues("429").Inc()
+	_, _ = io.ReadAll(NewReader(strings.NewReader("12345"), "photos-1"))
+	body := get("/metrics")
+	for _, line := range []string{`ente_api_errors_total{status="429"} 1`, `ente_export_downloaded_bytes_total{account="photos-1"} 5`} {
+		if !strings.Contains(body, line) {
+			t.Errorf("Expected %q in metrics", line)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Package output prints command results in the format selected with the global --output flag.
+// Results are written to stdout, while logs go to stderr, so that the output can be piped to other tools.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+)
+
+var Formats = []Format{Table, JSON, YAML}
+
+func ParseFormat(value string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(value, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q, expected one of table, json, yaml", value)
+}
+
+// Write encodes v in the given format. For the table format, table is called to print v for humans.
+func Write(w io.Writer, format Format, v interface{}, table func(w io.Writer) error) error {
+	switch format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case YAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return table(w)
+	}
+}
+
+// NewTable returns a writer which aligns tab separated columns, with the given header row.
+// Flush must be called once all the rows are written.
+func NewTable(w io.Writer, headers ...string) *tabwriter.Writer {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(headers) > 0 {
+		_, _ = fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	}
+	return tw
+}
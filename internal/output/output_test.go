@@ -0,0 +1,41 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+type result struct {
+	Name  string `json:"name" yaml:"name"`
+	Count int    `json:"count" yaml:"count"`
+}
+
+func TestWrite(t *testing.T) {
+	v := result{Name: "Trip", Count: 2}
+	table := func(w io.Writer) error {
+		tw := NewTable(w, "NAME", "COUNT")
+		_, _ = io.WriteString(tw, "Trip\t2\n")
+		return tw.Flush()
+	}
+	expected := map[Format]string{
+		JSON:  "{\n  \"name\": \"Trip\",\n  \"count\": 2\n}\n",
+		YAML:  "name: Trip\ncount: 2\n",
+		Table: "NAME  COUNT\nTrip  2\n",
+	}
+	for format, want := range expected {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, v, table); err != nil {
+			t.Fatalf("Failed to write %s: %v", format, err)
+		}
+		if buf.String() != want {
+			t.Errorf("Unexpected %s output:\n%s", format, buf.String())
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+	if format, err := ParseFormat("JSON"); err != nil || format != JSON {
+		t.Errorf("Unexpected format %q, %v", format, err)
+	}
+}
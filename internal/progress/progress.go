@@ -0,0 +1,304 @@
+// Package progress tracks the files and bytes of a running export and reports them, either as a status line
+// which is redrawn in place on a terminal, or as plain lines printed periodically when the output is redirected.
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ente-io/cli/utils"
+)
+
+const (
+	terminalInterval = 500 * time.Millisecond
+	plainInterval    = 30 * time.Second
+	barWidth         = 20
+)
+
+// Counts of files and bytes, done versus total
+type Counts struct {
+	Files      int
+	TotalFiles int
+	Bytes      int64
+	TotalBytes int64
+}
+
+// Tracker collects the progress of an export. All its methods can be called on a nil Tracker, which does nothing.
+type Tracker struct {
+	mu       sync.Mutex
+	out      io.Writer
+	terminal bool
+	// width of the terminal, the status line is truncated to it so that it can be redrawn
+	width    int
+	interval time.Duration
+
+	account string
+	start   time.Time
+	total   Counts
+	albums  map[string]*Counts
+	// album and bytes of the file being synced
+	current     string
+	fileBytes   int64
+	transferred int64
+	// width of the status line currently drawn on the terminal
+	drawn int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a tracker which reports to out. width is the width of the terminal, or 0 if out isn't one.
+// On a terminal the status line is redrawn in place, and anything else written to out while the tracker
+// runs must go through the tracker's Write.
+func New(out io.Writer, width int) *Tracker {
+	t := &Tracker{out: out, terminal: width > 0, width: width, interval: plainInterval, albums: make(map[string]*Counts)}
+	if t.terminal {
+		t.interval = terminalInterval
+	}
+	return t
+}
+
+// Start reports the progress periodically until Stop is called
+func (t *Tracker) Start() {
+	if t == nil {
+		return
+	}
+	t.stop = make(chan struct{})
+	t.done = make(chan struct{})
+	go func() {
+		defer close(t.done)
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.stop:
+				return
+			case <-ticker.C:
+				t.report()
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic reports and clears the status line
+func (t *Tracker) Stop() {
+	if t == nil || t.stop == nil {
+		return
+	}
+	close(t.stop)
+	<-t.done
+	t.stop = nil
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.clear()
+}
+
+// StartAccount resets the counts for the export of a new account
+func (t *Tracker) StartAccount(account string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.account = account
+	t.start = time.Now()
+	t.total = Counts{}
+	t.albums = make(map[string]*Counts)
+	t.current = ""
+	t.transferred = 0
+}
+
+// AddPending adds a file of the given size to the files which will be synced for the album
+func (t *Tracker) AddPending(album string, size int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := t.album(album)
+	counts.TotalFiles++
+	counts.TotalBytes += size
+	t.total.TotalFiles++
+	t.total.TotalBytes += size
+}
+
+// StartFile marks the start of the sync of a file of the album
+func (t *Tracker) StartFile(album string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.current = album
+	t.fileBytes = 0
+}
+
+// AddBytes counts bytes downloaded for the current file
+func (t *Tracker) AddBytes(n int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fileBytes += n
+	t.transferred += n
+	t.album(t.current).Bytes += n
+	t.total.Bytes += n
+}
+
+// RestartFile discards the bytes downloaded for the current file, when its download starts over
+func (t *Tracker) RestartFile() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.album(t.current).Bytes -= t.fileBytes
+	t.total.Bytes -= t.fileBytes
+	t.fileBytes = 0
+}
+
+// FinishFile marks the current file as synced, or skipped. The part of its size which wasn't downloaded is
+// counted as done, so that the totals add up when a file is skipped or was already downloaded before.
+func (t *Tracker) FinishFile(size int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	counts := t.album(t.current)
+	counts.Files++
+	t.total.Files++
+	if remaining := size - t.fileBytes; remaining > 0 {
+		counts.Bytes += remaining
+		t.total.Bytes += remaining
+	}
+	t.fileBytes = 0
+}
+
+// Write writes p to the output, above the status line when it's drawn on a terminal
+func (t *Tracker) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.terminal || t.drawn == 0 {
+		return t.out.Write(p)
+	}
+	t.clear()
+	n, err := t.out.Write(p)
+	t.draw()
+	return n, err
+}
+
+func (t *Tracker) album(name string) *Counts {
+	counts, ok := t.albums[name]
+	if !ok {
+		counts = &Counts{}
+		t.albums[name] = counts
+	}
+	return counts
+}
+
+func (t *Tracker) report() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.account == "" || t.total.TotalFiles == 0 {
+		return
+	}
+	if t.terminal {
+		t.clear()
+		t.draw()
+	} else {
+		_, _ = fmt.Fprintln(t.out, t.status())
+	}
+}
+
+func (t *Tracker) clear() {
+	if t.drawn > 0 {
+		_, _ = fmt.Fprint(t.out, "\r\033[K")
+		t.drawn = 0
+	}
+}
+
+func (t *Tracker) draw() {
+	if t.account == "" || t.total.TotalFiles == 0 {
+		return
+	}
+	line := t.status()
+	if runes := []rune(line); len(runes) >= t.width {
+		line = string(runes[:t.width-1])
+	}
+	t.drawn = len(line)
+	_, _ = fmt.Fprint(t.out, line)
+}
+
+// status describes the progress in a single line
+func (t *Tracker) status() string {
+	var sb strings.Builder
+	sb.WriteString(t.account)
+	if t.current != "" {
+		album := t.album(t.current)
+		fmt.Fprintf(&sb, " | %s %d/%d", t.current, album.Files, album.TotalFiles)
+	}
+	if t.terminal {
+		sb.WriteString(" " + bar(t.total))
+	}
+	fmt.Fprintf(&sb, " | %d/%d files | %s/%s", t.total.Files, t.total.TotalFiles,
+		utils.ByteCountDecimal(t.total.Bytes), utils.ByteCountDecimal(t.total.TotalBytes))
+	elapsed := time.Since(t.start)
+	if elapsed <= 0 || t.transferred == 0 {
+		return sb.String()
+	}
+	speed := float64(t.transferred) / elapsed.Seconds()
+	fmt.Fprintf(&sb, " | %s/s", utils.ByteCountDecimal(int64(speed)))
+	if remaining := t.total.TotalBytes - t.total.Bytes; remaining > 0 {
+		eta := time.Duration(float64(remaining)/speed) * time.Second
+		fmt.Fprintf(&sb, " | ETA %s", eta.Round(time.Second))
+	}
+	return sb.String()
+}
+
+func bar(c Counts) string {
+	filled := 0
+	if c.TotalBytes > 0 {
+		filled = int(c.Bytes * barWidth / c.TotalBytes)
+	} else if c.TotalFiles > 0 {
+		filled = c.Files * barWidth / c.TotalFiles
+	}
+	if filled > barWidth {
+		filled = barWidth
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled) + "]"
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx which carries the tracker
+func NewContext(ctx context.Context, t *Tracker) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tracker of the context, or nil if it has none
+func FromContext(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(contextKey{}).(*Tracker)
+	return t
+}
+
+// Reader counts the bytes read from r as downloaded for the current file
+type Reader struct {
+	r       io.Reader
+	tracker *Tracker
+}
+
+func NewReader(r io.Reader, t *Tracker) *Reader {
+	return &Reader{r: r, tracker: t}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.tracker.AddBytes(int64(n))
+	return n, err
+}
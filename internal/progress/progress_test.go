@@ -0,0 +1,73 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTrackerCounts(t *testing.T) {
+	var out bytes.Buffer
+	tracker := New(&out, 200)
+	tracker.StartAccount("a@b.c")
+	tracker.AddPending("Trip", 1000)
+	tracker.AddPending("Trip", 3000)
+	tracker.AddPending("Home", 6000)
+
+	tracker.StartFile("Trip")
+	tracker.AddBytes(400)
+	tracker.AddBytes(600)
+	tracker.FinishFile(1000)
+	// a file which was already downloaded counts as done without any transfer
+	tracker.StartFile("Trip")
+	tracker.FinishFile(3000)
+
+	if trip := *tracker.albums["Trip"]; trip != (Counts{Files: 2, TotalFiles: 2, Bytes: 4000, TotalBytes: 4000}) {
+		t.Errorf("Unexpected album counts %+v", trip)
+	}
+	if tracker.total != (Counts{Files: 2, TotalFiles: 3, Bytes: 4000, TotalBytes: 10000}) || tracker.transferred != 1000 {
+		t.Errorf("Unexpected total counts %+v, transferred %d", tracker.total, tracker.transferred)
+	}
+	status := tracker.status()
+	for _, part := range []string{"a@b.c | Trip 2/2", "[########............]", "2/3 files", "4.0 kB/10.0 kB", "ETA"} {
+		if !strings.Contains(status, part) {
+			t.Errorf("Expected %q in status %q", part, status)
+		}
+	}
+
+	tracker.report()
+	if _, err := tracker.Write([]byte("log line\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	// the status line is cleared before the log line and drawn again after it
+	if written := out.String(); !strings.Contains(written, "\r\033[Klog line\n"+status[:10]) {
+		t.Errorf("Unexpected terminal output %q", written)
+	}
+}
+
+func TestTrackerRestartFile(t *testing.T) {
+	tracker := New(&bytes.Buffer{}, 0)
+	tracker.StartAccount("a@b.c")
+	tracker.AddPending("Trip", 1000)
+	tracker.StartFile("Trip")
+	tracker.AddBytes(700)
+	// the connection broke, the download starts over
+	tracker.RestartFile()
+	tracker.AddBytes(1000)
+	tracker.FinishFile(1000)
+	if tracker.total != (Counts{Files: 1, TotalFiles: 1, Bytes: 1000, TotalBytes: 1000}) || tracker.transferred != 1700 {
+		t.Errorf("Unexpected total counts %+v, transferred %d", tracker.total, tracker.transferred)
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tracker *Tracker
+	tracker.StartAccount("a@b.c")
+	tracker.AddPending("Trip", 10)
+	tracker.StartFile("Trip")
+	tracker.AddBytes(10)
+	tracker.RestartFile()
+	tracker.FinishFile(10)
+	tracker.Start()
+	tracker.Stop()
+}
@@ -5,7 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ente-io/cli/internal/api"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -67,7 +67,6 @@ func GetCode(promptText string, length int) (string, error) {
 			return "", err
 		}
 		if ott == "" {
-			log.Fatal("no OTP entered")
 			return "", errors.New("no OTP entered")
 		}
 		if ott == "c" {
@@ -85,21 +84,21 @@ func GetExportDir() string {
 	for {
 		exportDir, err := GetUserInput("Enter export directory")
 		if err != nil {
-			log.Printf("invalid export directory input: %s\n", err)
+			slog.Error("invalid export directory input", "error", err)
 			return ""
 		}
 		if exportDir == "" {
-			log.Printf("invalid export directory: %s\n", err)
+			slog.Warn("export directory can't be empty")
 			continue
 		}
 		exportDir, err = ResolvePath(exportDir)
 		if err != nil {
-			log.Printf("invalid export directory: %s\n", err)
+			slog.Warn("invalid export directory", "error", err)
 			continue
 		}
 		_, err = ValidateDirForWrite(exportDir)
 		if err != nil {
-			log.Printf("invalid export directory: %s\n", err)
+			slog.Warn("invalid export directory", "error", err)
 			continue
 		}
 
@@ -51,19 +51,22 @@ func main() {
 			Debug: false,
 			//Host:  "http://localhost:8080",
 		}),
-		DB:        db,
-		KeyHolder: secrets.NewKeyHolder(secrets.GetOrCreateClISecret()),
+		DB:      db,
+		DataDir: cliDBPath,
 	}
 	err = ctrl.Init()
 	if err != nil {
 		panic(err)
 	}
-	defer func() {
-		if err := db.Close(); err != nil {
-			panic(err)
+	code := cmd.Execute(&ctrl)
+	// os.Exit skips deferred calls, close the database first so that its state is flushed
+	if err := db.Close(); err != nil {
+		log.Printf("failed to close the cli db: %v", err)
+		if code == 0 {
+			code = 1
 		}
-	}()
-	cmd.Execute(&ctrl)
+	}
+	os.Exit(code)
 }
 
 // GetCLIConfigPath returns the path to the .ente-cli folder and creates it if it doesn't exist.
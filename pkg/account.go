@@ -3,35 +3,31 @@ package pkg
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ente-io/cli/internal"
 	"github.com/ente-io/cli/internal/api"
 	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/storage"
 	"github.com/ente-io/cli/utils/encoding"
-	"log"
 
 	bolt "go.etcd.io/bbolt"
 )
 
 const AccBucket = "accounts"
 
-func (c *ClICtrl) AddAccount(cxt context.Context) {
-	var flowErr error
-	defer func() {
-		if flowErr != nil {
-			log.Fatal(flowErr)
-		}
-	}()
+// AddAccount signs in to an account of the server with the given endpoints, empty for ente.io, and stores it
+func (c *ClICtrl) AddAccount(cxt context.Context, endpoints api.Endpoints) error {
 	app := internal.GetAppType()
 	cxt = context.WithValue(cxt, "app", string(app))
+	cxt = context.WithValue(cxt, "endpoints", endpoints)
 	dir := internal.GetExportDir()
 	if dir == "" {
-		flowErr = fmt.Errorf("export directory not set")
-		return
+		return fmt.Errorf("export directory not set")
 	}
 	email, flowErr := internal.GetUserInput("Enter email address")
 	if flowErr != nil {
-		return
+		return flowErr
 	}
 	var verifyEmail bool
 
@@ -42,7 +38,7 @@ func (c *ClICtrl) AddAccount(cxt context.Context) {
 		if apiErr, ok := flowErr.(*api.ApiError); ok && apiErr.StatusCode == 404 {
 			verifyEmail = true
 		} else {
-			return
+			return flowErr
 		}
 	}
 	var authResponse *api.AuthorizationResponse
@@ -53,31 +49,30 @@ func (c *ClICtrl) AddAccount(cxt context.Context) {
 		authResponse, keyEncKey, flowErr = c.signInViaPassword(cxt, srpAttr)
 	}
 	if flowErr != nil {
-		return
+		return flowErr
 	}
 	if authResponse.IsMFARequired() {
 		authResponse, flowErr = c.validateTOTP(cxt, authResponse)
+		if flowErr != nil {
+			return flowErr
+		}
 	}
 	if authResponse.EncryptedToken == "" || authResponse.KeyAttributes == nil {
-		panic("no encrypted token or keyAttributes")
+		return errors.New("no encrypted token or keyAttributes")
 	}
-	secretInfo, decErr := c.decryptAccSecretInfo(cxt, authResponse, keyEncKey)
-	if decErr != nil {
-		flowErr = decErr
-		return
-	}
-
-	err := c.storeAccount(cxt, email, authResponse.ID, app, secretInfo, dir)
+	secretInfo, err := c.decryptAccSecretInfo(cxt, authResponse, keyEncKey)
 	if err != nil {
-		flowErr = err
-		return
-	} else {
-		fmt.Println("Account added successfully")
-		fmt.Println("run `ente export` to initiate export of your account data")
+		return err
+	}
+	if err = c.storeAccount(cxt, email, authResponse.ID, app, secretInfo, dir, endpoints); err != nil {
+		return err
 	}
+	fmt.Println("Account added successfully")
+	fmt.Println("run `ente export` to initiate export of your account data")
+	return nil
 }
 
-func (c *ClICtrl) storeAccount(_ context.Context, email string, userID int64, app api.App, secretInfo *model.AccSecretInfo, exportDir string) error {
+func (c *ClICtrl) storeAccount(_ context.Context, email string, userID int64, app api.App, secretInfo *model.AccSecretInfo, exportDir string, endpoints api.Endpoints) error {
 	// get password
 	err := c.DB.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(AccBucket))
@@ -94,6 +89,10 @@ func (c *ClICtrl) storeAccount(_ context.Context, email string, userID int64, ap
 			PublicKey: encoding.EncodeBase64(secretInfo.PublicKey),
 			ExportDir: exportDir,
 		}
+		if !endpoints.IsDefault() {
+			accInfo.APIEndpoint = endpoints.API
+			accInfo.FilesEndpoint = endpoints.Files
+		}
 		accInfoBytes, err := json.Marshal(accInfo)
 		if err != nil {
 			return err
@@ -125,45 +124,132 @@ func (c *ClICtrl) GetAccounts(cxt context.Context) ([]model.Account, error) {
 	return accounts, err
 }
 
-func (c *ClICtrl) ListAccounts(cxt context.Context) error {
+// ListAccounts returns a summary of the configured accounts, without any of their secrets
+func (c *ClICtrl) ListAccounts(cxt context.Context) ([]model.AccountSummary, error) {
 	accounts, err := c.GetAccounts(cxt)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	fmt.Printf("Configured accounts: %d\n", len(accounts))
+	summaries := make([]model.AccountSummary, 0, len(accounts))
 	for _, acc := range accounts {
-		fmt.Println("====================================")
-		fmt.Println("Email:    ", acc.Email)
-		fmt.Println("ID:       ", acc.UserID)
-		fmt.Println("App:      ", acc.App)
-		fmt.Println("ExportDir:", acc.ExportDir)
-		fmt.Println("====================================")
+		summaries = append(summaries, acc.Summary())
 	}
-	return nil
+	return summaries, nil
 }
 
-func (c *ClICtrl) UpdateAccount(ctx context.Context, params model.UpdateAccountParams) error {
+// getAccount returns the configured account of the app selected by ref.
+// If the email is empty and only one account is configured for the app, that account is returned. It fails
+// when the email is used on several servers and ref doesn't select one of them.
+func (c *ClICtrl) getAccount(ctx context.Context, ref model.AccountRef, app api.App) (*model.Account, error) {
 	accounts, err := c.GetAccounts(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var acc *model.Account
+	var matches []model.Account
 	for _, a := range accounts {
-		if a.Email == params.Email && a.App == params.App {
-			acc = &a
-			break
+		if a.App != app || !a.IsOnServer(ref.API) {
+			continue
 		}
+		if ref.Email == "" || a.Email == ref.Email {
+			matches = append(matches, a)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		return &matches[0], nil
+	case len(matches) > 1 && ref.Email == "":
+		return nil, fmt.Errorf("multiple %s accounts configured, specify the account email", app)
+	case len(matches) > 1:
+		return nil, fmt.Errorf("%s has %s accounts on several servers, specify the server with --api-endpoint", ref.Email, app)
 	}
-	if acc == nil {
-		return fmt.Errorf("account not found, use `account list` to list accounts")
+	return nil, fmt.Errorf("account not found, use `account list` to list accounts")
+}
+
+func (c *ClICtrl) UpdateAccount(ctx context.Context, params model.UpdateAccountParams) error {
+	acc, err := c.getAccount(ctx, model.AccountRef{Email: params.Email, API: params.API}, params.App)
+	if err != nil {
+		return err
 	}
+	targetChanged := false
 	if params.ExportDir != nil && *params.ExportDir != "" {
 		_, err := internal.ValidateDirForWrite(*params.ExportDir)
 		if err != nil {
 			return err
 		}
+		targetChanged = acc.S3 == nil && acc.ExportDir != *params.ExportDir
 		acc.ExportDir = *params.ExportDir
 	}
+	if params.DisableS3 && acc.S3 != nil {
+		acc.S3 = nil
+		targetChanged = true
+	}
+	if params.S3 != nil {
+		s3Config := model.S3Config{
+			Endpoint:  params.S3.Endpoint,
+			Region:    params.S3.Region,
+			Bucket:    params.S3.Bucket,
+			Prefix:    params.S3.Prefix,
+			AccessKey: params.S3.AccessKey,
+			SecretKey: *model.MakeEncString([]byte(params.S3.SecretKey), c.KeyHolder.DeviceKey),
+			Insecure:  params.S3.Insecure,
+		}
+		if _, err = storage.NewS3Client(ctx, c.s3Options(s3Config)); err != nil {
+			return err
+		}
+		targetChanged = acc.S3 == nil || acc.S3.Endpoint != s3Config.Endpoint ||
+			acc.S3.Bucket != s3Config.Bucket || acc.S3.Prefix != s3Config.Prefix
+		acc.S3 = &s3Config
+	}
+	if params.ResetBandwidth {
+		acc.Bandwidth = nil
+	}
+	if params.MaxBandwidth != nil || params.FullSpeedWindows != nil {
+		config := model.BandwidthConfig{}
+		if acc.Bandwidth != nil {
+			config = *acc.Bandwidth
+		}
+		if params.MaxBandwidth != nil {
+			config.MaxBytesPerSec = *params.MaxBandwidth
+		}
+		if params.FullSpeedWindows != nil {
+			config.FullSpeedWindows = params.FullSpeedWindows
+		}
+		if _, err = newBandwidthLimiter(config); err != nil {
+			return err
+		}
+		acc.Bandwidth = &config
+	}
+	if params.DisableEncryption && acc.ExportKey != nil {
+		if !targetChanged {
+			return errors.New("a new export destination is required to disable encryption")
+		}
+		acc.ExportKey = nil
+	}
+	if params.ExportPassphrase != nil || (targetChanged && acc.ExportKey != nil) {
+		if params.ExportPassphrase == nil {
+			return errors.New("the export is encrypted, the passphrase is required to change its destination")
+		}
+		accCtx := c.buildRequestContext(ctx, *acc)
+		if err = createDataBuckets(c.DB, *acc); err != nil {
+			return err
+		}
+		backend, err := c.getDestinationBackend(accCtx, *acc)
+		if err != nil {
+			return err
+		}
+		key, err := storage.SetupEncryption(accCtx, backend, *params.ExportPassphrase)
+		if err != nil {
+			return err
+		}
+		targetChanged = targetChanged || acc.ExportKey == nil
+		acc.ExportKey = model.MakeEncString(key, c.KeyHolder.DeviceKey)
+	}
+	if targetChanged {
+		// files exported to the previous target need to be exported again
+		if err = c.resetExportState(c.buildRequestContext(ctx, *acc), *acc); err != nil {
+			return err
+		}
+	}
 	err = c.DB.Update(func(tx *bolt.Tx) error {
 		b, err := tx.CreateBucketIfNotExists([]byte(AccBucket))
 		if err != nil {
@@ -0,0 +1,92 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/pkg/model"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newAccountsTestCtrl(t *testing.T, accounts ...model.Account) *ClICtrl {
+	db, err := GetDB(filepath.Join(t.TempDir(), "ente-cli.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(AccBucket))
+		if err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			value, _ := json.Marshal(account)
+			if err = bucket.Put([]byte(account.AccountKey()), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ClICtrl{DB: db}
+}
+
+func TestGetAccount(t *testing.T) {
+	enteAccount := model.Account{Email: "me@example.org", UserID: 1, App: api.AppPhotos}
+	selfHosted := model.Account{Email: "me@example.org", UserID: 1, App: api.AppPhotos, APIEndpoint: "https://museum.example.org"}
+	other := model.Account{Email: "other@example.org", UserID: 2, App: api.AppPhotos}
+	c := newAccountsTestCtrl(t, enteAccount, selfHosted, other)
+	ctx := context.Background()
+
+	tests := []struct {
+		ref     model.AccountRef
+		want    string
+		wantErr string
+	}{
+		{model.AccountRef{Email: "other@example.org"}, other.AccountKey(), ""},
+		{model.AccountRef{Email: "me@example.org", API: "https://api.ente.io"}, enteAccount.AccountKey(), ""},
+		{model.AccountRef{Email: "me@example.org", API: "museum.example.org"}, selfHosted.AccountKey(), ""},
+		{model.AccountRef{API: "museum.example.org"}, selfHosted.AccountKey(), ""},
+		{model.AccountRef{Email: "me@example.org"}, "", "several servers"},
+		{model.AccountRef{}, "", "multiple photos accounts"},
+		{model.AccountRef{Email: "other@example.org", API: "museum.example.org"}, "", "account not found"},
+	}
+	for _, test := range tests {
+		account, err := c.getAccount(ctx, test.ref, api.AppPhotos)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("getAccount(%+v): expected an error with %q, got %v", test.ref, test.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("getAccount(%+v): %v", test.ref, err)
+		} else if account.AccountKey() != test.want {
+			t.Errorf("getAccount(%+v) returned %s, expected %s", test.ref, account.AccountKey(), test.want)
+		}
+	}
+}
+
+func TestAccountSettingsOfSeveralServers(t *testing.T) {
+	enteAccount := model.Account{Email: "me@example.org", UserID: 1, App: api.AppPhotos}
+	selfHosted := model.Account{Email: "me@example.org", UserID: 1, App: api.AppPhotos, APIEndpoint: "https://museum.example.org"}
+	accounts := []model.Account{enteAccount, selfHosted}
+	c := &ClICtrl{AccountSettings: []model.AccountSettings{{Email: "me@example.org", App: api.AppPhotos, Skip: true}}}
+	if _, err := c.accountSettings(enteAccount, accounts); err == nil {
+		t.Error("expected an error for settings matching accounts on several servers")
+	}
+
+	c.AccountSettings[0].API = "museum.example.org"
+	if settings, err := c.accountSettings(selfHosted, accounts); err != nil || !settings.Skip {
+		t.Errorf("expected the settings of the self-hosted account, got %+v: %v", settings, err)
+	}
+	if settings, err := c.accountSettings(enteAccount, accounts); err != nil || settings.Skip {
+		t.Errorf("expected no settings for the ente.io account, got %+v: %v", settings, err)
+	}
+}
@@ -0,0 +1,274 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"github.com/ente-io/cli/internal/api"
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/pkg/mapper"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/utils/encoding"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// createRemoteAlbum creates a new album with the given name and stores it in the local RemoteAlbums store.
+func (c *ClICtrl) createRemoteAlbum(ctx context.Context, name string) (*model.RemoteAlbum, error) {
+	accSecretInfo := c.KeyHolder.GetAccountSecretInfo(ctx)
+	collectionKey := eCrypto.NewStreamKey()
+	encKey, keyNonce, err := eCrypto.SecretBoxSeal(collectionKey, accSecretInfo.MasterKey)
+	if err != nil {
+		return nil, err
+	}
+	encName, nameNonce, err := eCrypto.SecretBoxSeal([]byte(name), collectionKey)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := c.Client.CreateCollection(ctx, api.CreateCollectionRequest{
+		EncryptedKey:        encoding.EncodeBase64(encKey),
+		KeyDecryptionNonce:  encoding.EncodeBase64(keyNonce),
+		EncryptedName:       encoding.EncodeBase64(encName),
+		NameDecryptionNonce: encoding.EncodeBase64(nameNonce),
+		Type:                "album",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create album %s: %w", name, err)
+	}
+	album, err := mapper.MapCollectionToAlbum(ctx, *collection, c.KeyHolder)
+	if err != nil {
+		return nil, err
+	}
+	err = c.PutValue(ctx, model.RemoteAlbums, []byte(strconv.FormatInt(album.ID, 10)), encoding.MustMarshalJSON(album))
+	if err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// loadPhotosAccount loads the photos account selected by ref and returns the request context for it.
+func (c *ClICtrl) loadPhotosAccount(ctx context.Context, ref model.AccountRef) (context.Context, *model.Account, error) {
+	account, err := c.getAccount(ctx, ref, api.AppPhotos)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, err = c.loadAccount(ctx, *account)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, account, nil
+}
+
+// findAlbum returns the album matching the given reference, which can either be the album ID or its name.
+func (c *ClICtrl) findAlbum(ctx context.Context, albumRef string) (*model.RemoteAlbum, error) {
+	albums, err := c.getRemoteAlbums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id, parseErr := strconv.ParseInt(albumRef, 10, 64); parseErr == nil {
+		for i := range albums {
+			if albums[i].ID == id && !albums[i].IsDeleted {
+				return &albums[i], nil
+			}
+		}
+	}
+	var matches []*model.RemoteAlbum
+	for i := range albums {
+		if !albums[i].IsDeleted && strings.EqualFold(albums[i].AlbumName, albumRef) {
+			matches = append(matches, &albums[i])
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("album %s not found, use `album list` to list albums", albumRef)
+	}
+	if len(matches) > 1 {
+		ids := make([]string, 0, len(matches))
+		for _, match := range matches {
+			ids = append(ids, strconv.FormatInt(match.ID, 10))
+		}
+		return nil, fmt.Errorf("multiple albums named %s found (%s), use the album ID instead", albumRef, strings.Join(ids, ", "))
+	}
+	return matches[0], nil
+}
+
+// getAlbumFileCounts returns the number of non-deleted files in each album.
+func (c *ClICtrl) getAlbumFileCounts(ctx context.Context) (map[int64]int, error) {
+	entries, err := c.getRemoteAlbumEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[int64]int)
+	for _, entry := range entries {
+		if !entry.IsDeleted {
+			counts[entry.AlbumID]++
+		}
+	}
+	return counts, nil
+}
+
+// albumSummary returns the view of the album printed by the album commands
+func (c *ClICtrl) albumSummary(ctx context.Context, album *model.RemoteAlbum, files int) model.AlbumSummary {
+	summary := model.AlbumSummary{
+		ID:        album.ID,
+		Name:      album.AlbumName,
+		OwnerID:   album.OwnerID,
+		Owner:     albumOwner(ctx, *album),
+		Shared:    album.HasShares(),
+		Files:     files,
+		UpdatedAt: time.UnixMicro(album.LastUpdatedAt),
+	}
+	for _, sharee := range album.Sharees {
+		summary.Sharees = append(summary.Sharees, model.AlbumSharee{Email: sharee.Email, Role: strings.ToLower(sharee.Role)})
+	}
+	for _, publicURL := range album.PublicURLs {
+		summary.Links = append(summary.Links, publicLinkSummary(album, publicURL, c.KeyHolder.DeviceKey))
+	}
+	return summary
+}
+
+// loadAlbumSummary returns the summary of the album with the given ID, from the local store
+func (c *ClICtrl) loadAlbumSummary(ctx context.Context, albumID int64) (*model.AlbumSummary, error) {
+	album, err := c.findAlbum(ctx, strconv.FormatInt(albumID, 10))
+	if err != nil {
+		return nil, err
+	}
+	counts, err := c.getAlbumFileCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	summary := c.albumSummary(ctx, album, counts[album.ID])
+	return &summary, nil
+}
+
+// ListAlbums returns the albums of the account which aren't deleted, sorted by name
+func (c *ClICtrl) ListAlbums(ctx context.Context, ref model.AccountRef, refresh bool) ([]model.AlbumSummary, error) {
+	ctx, _, err := c.loadPhotosAccount(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if refresh {
+		if err = c.fetchRemoteCollections(ctx); err != nil {
+			return nil, err
+		}
+		if err = c.fetchRemoteFiles(ctx); err != nil {
+			return nil, err
+		}
+	}
+	albums, err := c.getRemoteAlbums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := c.getAlbumFileCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	activeAlbums := make([]model.RemoteAlbum, 0, len(albums))
+	for _, album := range albums {
+		if !album.IsDeleted {
+			activeAlbums = append(activeAlbums, album)
+		}
+	}
+	sort.Slice(activeAlbums, func(i, j int) bool {
+		return strings.ToLower(activeAlbums[i].AlbumName) < strings.ToLower(activeAlbums[j].AlbumName)
+	})
+	result := make([]model.AlbumSummary, 0, len(activeAlbums))
+	for i := range activeAlbums {
+		result = append(result, c.albumSummary(ctx, &activeAlbums[i], counts[activeAlbums[i].ID]))
+	}
+	return result, nil
+}
+
+func albumOwner(ctx context.Context, album model.RemoteAlbum) string {
+	if album.OwnerID == ctx.Value("user_id").(int64) {
+		return "you"
+	}
+	if album.OwnerEmail != "" {
+		return album.OwnerEmail
+	}
+	return strconv.FormatInt(album.OwnerID, 10)
+}
+
+func (c *ClICtrl) ShowAlbum(ctx context.Context, ref model.AccountRef, albumRef string) (*model.AlbumSummary, error) {
+	ctx, _, err := c.loadPhotosAccount(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	album, err := c.findAlbum(ctx, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	return c.loadAlbumSummary(ctx, album.ID)
+}
+
+func (c *ClICtrl) CreateAlbum(ctx context.Context, ref model.AccountRef, name string) (*model.AlbumSummary, error) {
+	ctx, _, err := c.loadPhotosAccount(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.fetchRemoteCollections(ctx); err != nil {
+		return nil, err
+	}
+	album, err := c.createRemoteAlbum(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	summary := c.albumSummary(ctx, album, 0)
+	return &summary, nil
+}
+
+// RenameAlbum renames the album and returns it with its new name
+func (c *ClICtrl) RenameAlbum(ctx context.Context, ref model.AccountRef, albumRef string, newName string) (*model.AlbumSummary, error) {
+	ctx, _, err := c.loadPhotosAccount(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.fetchRemoteCollections(ctx); err != nil {
+		return nil, err
+	}
+	album, err := c.findAlbum(ctx, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	if album.IsShared {
+		return nil, fmt.Errorf("album %s is owned by another user and can not be renamed", album.AlbumName)
+	}
+	encName, nameNonce, err := eCrypto.SecretBoxSeal([]byte(newName), album.AlbumKey.MustDecrypt(c.KeyHolder.DeviceKey))
+	if err != nil {
+		return nil, err
+	}
+	err = c.Client.RenameCollection(ctx, album.ID, encoding.EncodeBase64(encName), encoding.EncodeBase64(nameNonce))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename album %s: %w", album.AlbumName, err)
+	}
+	if err = c.fetchRemoteCollections(ctx); err != nil {
+		return nil, err
+	}
+	return c.loadAlbumSummary(ctx, album.ID)
+}
+
+// DeleteAlbum moves the album to the trash and returns it as it was before
+func (c *ClICtrl) DeleteAlbum(ctx context.Context, ref model.AccountRef, albumRef string, keepFiles bool) (*model.AlbumSummary, error) {
+	ctx, _, err := c.loadPhotosAccount(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.fetchRemoteCollections(ctx); err != nil {
+		return nil, err
+	}
+	album, err := c.findAlbum(ctx, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	if album.IsShared {
+		return nil, fmt.Errorf("album %s is owned by another user and can not be deleted", album.AlbumName)
+	}
+	summary, err := c.loadAlbumSummary(ctx, album.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.Client.TrashCollection(ctx, album.ID, keepFiles); err != nil {
+		return nil, fmt.Errorf("failed to delete album %s: %w", album.AlbumName, err)
+	}
+	return summary, c.fetchRemoteCollections(ctx)
+}
@@ -0,0 +1,246 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"github.com/ente-io/cli/internal/api"
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/utils/encoding"
+	"strings"
+	"time"
+)
+
+const (
+	// publicLinkMemLimit and publicLinkOpsLimit match the interactive limits used by ente clients
+	// for hashing the public link password
+	publicLinkMemLimit = 64 * 1024 * 1024
+	publicLinkOpsLimit = 2
+)
+
+// loadOwnedAlbum syncs the collections of the account and returns the album matching the given reference,
+// failing if the album is not owned by the account.
+func (c *ClICtrl) loadOwnedAlbum(ctx context.Context, ref model.AccountRef, albumRef string) (context.Context, *model.RemoteAlbum, error) {
+	ctx, _, err := c.loadPhotosAccount(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = c.fetchRemoteCollections(ctx); err != nil {
+		return nil, nil, err
+	}
+	album, err := c.findAlbum(ctx, albumRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	if album.IsShared {
+		return nil, nil, fmt.Errorf("album %s is owned by another user", album.AlbumName)
+	}
+	return ctx, album, nil
+}
+
+func parseRole(role string) (string, error) {
+	switch strings.ToUpper(role) {
+	case api.RoleViewer:
+		return api.RoleViewer, nil
+	case api.RoleCollaborator:
+		return api.RoleCollaborator, nil
+	}
+	return "", fmt.Errorf("invalid role %s, accepted values are 'viewer' and 'collaborator'", role)
+}
+
+// ShareAlbum shares the album with another ente user. The collection key is sealed with the
+// public key of the user, so that only they can decrypt it. Sharing an album with an existing
+// sharee updates their role. It returns the album with its updated sharees.
+func (c *ClICtrl) ShareAlbum(ctx context.Context, ref model.AccountRef, albumRef string, shareeEmail string, role string) (*model.AlbumSummary, error) {
+	role, err := parseRole(role)
+	if err != nil {
+		return nil, err
+	}
+	ctx, album, err := c.loadOwnedAlbum(ctx, ref, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	return c.shareAlbum(ctx, album, shareeEmail, role)
+}
+
+func (c *ClICtrl) shareAlbum(ctx context.Context, album *model.RemoteAlbum, shareeEmail string, role string) (*model.AlbumSummary, error) {
+	_, publicKey, err := c.Client.GetPublicKey(ctx, shareeEmail)
+	if err != nil {
+		if apiErr, ok := err.(*api.ApiError); ok && apiErr.StatusCode == 404 {
+			return nil, fmt.Errorf("%s is not an ente user", shareeEmail)
+		}
+		return nil, err
+	}
+	encKey, err := eCrypto.SealedBoxSeal(album.AlbumKey.MustDecrypt(c.KeyHolder.DeviceKey), encoding.DecodeBase64(publicKey))
+	if err != nil {
+		return nil, err
+	}
+	err = c.Client.ShareCollection(ctx, album.ID, shareeEmail, encoding.EncodeBase64(encKey), role)
+	if err != nil {
+		return nil, fmt.Errorf("failed to share album %s: %w", album.AlbumName, err)
+	}
+	return c.refreshAlbumSummary(ctx, album.ID)
+}
+
+// refreshAlbumSummary syncs the collections after a change of the album, and returns its summary
+func (c *ClICtrl) refreshAlbumSummary(ctx context.Context, albumID int64) (*model.AlbumSummary, error) {
+	if err := c.fetchRemoteCollections(ctx); err != nil {
+		return nil, err
+	}
+	return c.loadAlbumSummary(ctx, albumID)
+}
+
+// SetShareeRole changes the role of an existing sharee of the album.
+func (c *ClICtrl) SetShareeRole(ctx context.Context, ref model.AccountRef, albumRef string, shareeEmail string, role string) (*model.AlbumSummary, error) {
+	role, err := parseRole(role)
+	if err != nil {
+		return nil, err
+	}
+	ctx, album, err := c.loadOwnedAlbum(ctx, ref, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	if !isSharee(album, shareeEmail) {
+		return nil, fmt.Errorf("album %s is not shared with %s", album.AlbumName, shareeEmail)
+	}
+	return c.shareAlbum(ctx, album, shareeEmail, role)
+}
+
+func (c *ClICtrl) UnshareAlbum(ctx context.Context, ref model.AccountRef, albumRef string, shareeEmail string) (*model.AlbumSummary, error) {
+	ctx, album, err := c.loadOwnedAlbum(ctx, ref, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	if !isSharee(album, shareeEmail) {
+		return nil, fmt.Errorf("album %s is not shared with %s", album.AlbumName, shareeEmail)
+	}
+	if err = c.Client.UnshareCollection(ctx, album.ID, shareeEmail); err != nil {
+		return nil, fmt.Errorf("failed to unshare album %s: %w", album.AlbumName, err)
+	}
+	return c.refreshAlbumSummary(ctx, album.ID)
+}
+
+func isSharee(album *model.RemoteAlbum, email string) bool {
+	for _, sharee := range album.Sharees {
+		if strings.EqualFold(sharee.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAlbumLink creates a public link for the album and returns it along with the key fragment.
+func (c *ClICtrl) CreateAlbumLink(ctx context.Context, ref model.AccountRef, albumRef string, params model.PublicLinkParams) (*model.PublicLink, error) {
+	ctx, album, err := c.loadOwnedAlbum(ctx, ref, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(album.PublicURLs) > 0 {
+		return nil, fmt.Errorf("album %s already has a public link, use `album link update` to change it", album.AlbumName)
+	}
+	var validTill int64
+	var deviceLimit int
+	if params.ValidTill != nil {
+		validTill = *params.ValidTill
+	}
+	if params.DeviceLimit != nil {
+		deviceLimit = *params.DeviceLimit
+	}
+	publicURL, err := c.Client.CreatePublicURL(ctx, album.ID, validTill, deviceLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create link for album %s: %w", album.AlbumName, err)
+	}
+	// the remaining options can only be set by updating the link
+	params.ValidTill, params.DeviceLimit = nil, nil
+	if params.EnableDownload != nil || params.Password != nil {
+		publicURL, err = c.updatePublicURL(ctx, album, params)
+		if err != nil {
+			return nil, err
+		}
+	}
+	link := publicLinkSummary(album, *publicURL, c.KeyHolder.DeviceKey)
+	return &link, c.fetchRemoteCollections(ctx)
+}
+
+func (c *ClICtrl) UpdateAlbumLink(ctx context.Context, ref model.AccountRef, albumRef string, params model.PublicLinkParams) (*model.PublicLink, error) {
+	ctx, album, err := c.loadOwnedAlbum(ctx, ref, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(album.PublicURLs) == 0 {
+		return nil, fmt.Errorf("album %s does not have a public link, use `album link create` to create one", album.AlbumName)
+	}
+	publicURL, err := c.updatePublicURL(ctx, album, params)
+	if err != nil {
+		return nil, err
+	}
+	link := publicLinkSummary(album, *publicURL, c.KeyHolder.DeviceKey)
+	return &link, c.fetchRemoteCollections(ctx)
+}
+
+func (c *ClICtrl) updatePublicURL(ctx context.Context, album *model.RemoteAlbum, params model.PublicLinkParams) (*api.PublicURL, error) {
+	req := api.UpdatePublicURLRequest{
+		CollectionID:   album.ID,
+		ValidTill:      params.ValidTill,
+		DeviceLimit:    params.DeviceLimit,
+		EnableDownload: params.EnableDownload,
+	}
+	if params.RemovePassword {
+		disablePassword := true
+		req.DisablePassword = &disablePassword
+	} else if params.Password != nil {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		nonce := encoding.EncodeBase64(salt)
+		passKey, err := eCrypto.DeriveArgonKey(*params.Password, nonce, publicLinkMemLimit, publicLinkOpsLimit)
+		if err != nil {
+			return nil, err
+		}
+		passHash := encoding.EncodeBase64(passKey)
+		memLimit, opsLimit := int64(publicLinkMemLimit), int64(publicLinkOpsLimit)
+		req.PassHash, req.Nonce, req.MemLimit, req.OpsLimit = &passHash, &nonce, &memLimit, &opsLimit
+	}
+	publicURL, err := c.Client.UpdatePublicURL(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update link for album %s: %w", album.AlbumName, err)
+	}
+	return publicURL, nil
+}
+
+// RevokeAlbumLink deletes the public link of the album and returns the album without it
+func (c *ClICtrl) RevokeAlbumLink(ctx context.Context, ref model.AccountRef, albumRef string) (*model.AlbumSummary, error) {
+	ctx, album, err := c.loadOwnedAlbum(ctx, ref, albumRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(album.PublicURLs) == 0 {
+		return nil, fmt.Errorf("album %s does not have a public link", album.AlbumName)
+	}
+	if err = c.Client.DeletePublicURL(ctx, album.ID); err != nil {
+		return nil, fmt.Errorf("failed to revoke link for album %s: %w", album.AlbumName, err)
+	}
+	return c.refreshAlbumSummary(ctx, album.ID)
+}
+
+// publicLink returns the public url along with the collection key as the url fragment,
+// which is never sent to the server.
+func publicLink(album *model.RemoteAlbum, publicURL api.PublicURL, deviceKey []byte) string {
+	return fmt.Sprintf("%s#%s", publicURL.URL, encoding.EncodeBase58(album.AlbumKey.MustDecrypt(deviceKey)))
+}
+
+func publicLinkSummary(album *model.RemoteAlbum, publicURL api.PublicURL, deviceKey []byte) model.PublicLink {
+	link := model.PublicLink{
+		URL:             publicLink(album, publicURL, deviceKey),
+		DeviceLimit:     publicURL.DeviceLimit,
+		EnableDownload:  publicURL.EnableDownload,
+		PasswordEnabled: publicURL.PasswordEnabled,
+	}
+	if publicURL.ValidTill > 0 {
+		expiresAt := time.UnixMicro(publicURL.ValidTill)
+		link.ExpiresAt = &expiresAt
+	}
+	return link
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"github.com/ente-io/cli/internal/api"
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/utils/encoding"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// decodeBase58 reverses encoding.EncodeBase58
+func decodeBase58(t *testing.T, s string) []byte {
+	const alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	num := new(big.Int)
+	for _, ch := range s {
+		index := strings.IndexRune(alphabet, ch)
+		if index < 0 {
+			t.Fatalf("invalid base58 character %q in %s", ch, s)
+		}
+		num.Mul(num, big.NewInt(58))
+		num.Add(num, big.NewInt(int64(index)))
+	}
+	leadingZeros := len(s) - len(strings.TrimLeft(s, "1"))
+	return append(make([]byte, leadingZeros), num.Bytes()...)
+}
+
+func TestShareAlbum(t *testing.T) {
+	c, m := newMuseumTestCtrl(t)
+	key := m.addCollection(1, testMuseumUserID, "Trip")
+	m.addCollection(2, 8, "Their trip")
+	shareePublicKey, shareeSecretKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.publicKeys["you@example.org"] = shareePublicKey[:]
+	ctx := context.Background()
+	ref := model.AccountRef{Email: "me@example.org"}
+
+	if _, err = c.ShareAlbum(ctx, ref, "Trip", "nobody@example.org", "viewer"); err == nil || !strings.Contains(err.Error(), "not an ente user") {
+		t.Errorf("expected an error for an unknown user, got %v", err)
+	}
+	if _, err = c.ShareAlbum(ctx, ref, "Their trip", "you@example.org", "viewer"); err == nil {
+		t.Error("expected an error for an album shared with the account")
+	}
+	if _, err = c.ShareAlbum(ctx, ref, "Trip", "you@example.org", "owner"); err == nil {
+		t.Error("expected an error for an invalid role")
+	}
+	if changes := m.changes(); len(changes) != 0 {
+		t.Fatalf("expected no change after the failures, got %+v", changes)
+	}
+
+	if _, err = c.ShareAlbum(ctx, ref, "Trip", "you@example.org", "viewer"); err != nil {
+		t.Fatal(err)
+	}
+	changes := m.changes()
+	if len(changes) != 1 || changes[0].Method != http.MethodPost || changes[0].Path != "/collections/share" {
+		t.Fatalf("expected a share request, got %+v", changes)
+	}
+	body := changes[0].Body
+	if body["collectionID"] != float64(1) || body["email"] != "you@example.org" || body["role"] != api.RoleViewer {
+		t.Errorf("unexpected share request %v", body)
+	}
+	encKey, _ := body["encryptedKey"].(string)
+	sharedKey, err := eCrypto.SealedBoxOpen(encoding.DecodeBase64(encKey), shareePublicKey[:], shareeSecretKey[:])
+	if err != nil || !bytes.Equal(sharedKey, key) {
+		t.Errorf("expected the album key sealed for the sharee: %v", err)
+	}
+}
+
+func TestSetShareeRole(t *testing.T) {
+	c, m := newMuseumTestCtrl(t)
+	m.addCollection(1, testMuseumUserID, "Trip")
+	m.collections[0].Sharees = []api.CollectionUser{{ID: 99, Email: "you@example.org", Role: api.RoleViewer}}
+	shareePublicKey, _, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.publicKeys["you@example.org"] = shareePublicKey[:]
+	m.publicKeys["other@example.org"] = shareePublicKey[:]
+	ctx := context.Background()
+	ref := model.AccountRef{Email: "me@example.org"}
+
+	if _, err = c.SetShareeRole(ctx, ref, "Trip", "other@example.org", "collaborator"); err == nil || !strings.Contains(err.Error(), "not shared with") {
+		t.Errorf("expected an error for a user who isn't a sharee, got %v", err)
+	}
+	summary, err := c.SetShareeRole(ctx, ref, "Trip", "you@example.org", "collaborator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := m.changes()
+	if len(changes) != 1 || changes[0].Path != "/collections/share" || changes[0].Body["role"] != api.RoleCollaborator {
+		t.Fatalf("expected a share request with the new role, got %+v", changes)
+	}
+	if !summary.Shared || len(summary.Sharees) != 1 {
+		t.Errorf("expected the summary with the sharee, got %+v", summary)
+	}
+}
+
+func TestCreateAlbumLinkWithPassword(t *testing.T) {
+	c, m := newMuseumTestCtrl(t)
+	key := m.addCollection(1, testMuseumUserID, "Trip")
+	m.publicURL = api.PublicURL{URL: "https://albums.ente.io/?t=abc", PasswordEnabled: true}
+	ctx := context.Background()
+	ref := model.AccountRef{Email: "me@example.org"}
+
+	deviceLimit, password := 5, "secret"
+	link, err := c.CreateAlbumLink(ctx, ref, "Trip", model.PublicLinkParams{DeviceLimit: &deviceLimit, Password: &password})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := m.changes()
+	if len(changes) != 2 {
+		t.Fatalf("expected the link to be created and updated, got %+v", changes)
+	}
+	create, update := changes[0], changes[1]
+	if create.Method != http.MethodPost || create.Path != "/collections/share-url" ||
+		create.Body["collectionID"] != float64(1) || create.Body["deviceLimit"] != float64(5) {
+		t.Errorf("unexpected create request %+v", create)
+	}
+	if update.Method != http.MethodPut || update.Path != "/collections/share-url" {
+		t.Fatalf("unexpected update request %+v", update)
+	}
+	if update.Body["memLimit"] != float64(64*1024*1024) || update.Body["opsLimit"] != float64(2) {
+		t.Errorf("expected the interactive limits of the ente clients, got %v", update.Body)
+	}
+	if _, ok := update.Body["deviceLimit"]; ok {
+		t.Error("expected the device limit to be only sent with the creation of the link")
+	}
+	nonce, _ := update.Body["nonce"].(string)
+	passHash, _ := update.Body["passHash"].(string)
+	if len(encoding.DecodeBase64(nonce)) != 16 {
+		t.Errorf("expected a 16 bytes salt, got %q", nonce)
+	}
+	passKey, err := eCrypto.DeriveArgonKey(password, nonce, 64*1024*1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if passHash != encoding.EncodeBase64(passKey) {
+		t.Error("expected the hash of the password derived with the nonce")
+	}
+
+	url, fragment, found := strings.Cut(link.URL, "#")
+	if !found || url != m.publicURL.URL {
+		t.Fatalf("expected the url of the server with the key as its fragment, got %s", link.URL)
+	}
+	if !bytes.Equal(decodeBase58(t, fragment), key) {
+		t.Error("expected the fragment to decode to the album key")
+	}
+	if !link.PasswordEnabled {
+		t.Errorf("expected the link returned by the server, got %+v", link)
+	}
+}
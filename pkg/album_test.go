@@ -0,0 +1,225 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/ente-io/cli/internal/api"
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/secrets"
+	"github.com/ente-io/cli/utils/encoding"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+const testMuseumUserID = 7
+
+// museumRequest is a request which changes the state of the fake museum
+type museumRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   map[string]interface{}
+}
+
+// testMuseum is a fake museum server, which serves the collections of the test account
+// and records the requests changing them
+type testMuseum struct {
+	t         *testing.T
+	masterKey []byte
+	publicKey []byte
+	mu        sync.Mutex
+	// collections are returned by every collections sync
+	collections []api.Collection
+	// publicKeys of the other users by email
+	publicKeys map[string][]byte
+	// publicURL is returned when a public link is created or updated
+	publicURL api.PublicURL
+	requests  []museumRequest
+}
+
+// newMuseumTestCtrl returns a controller with a photos account of a fake museum server
+func newMuseumTestCtrl(t *testing.T) (*ClICtrl, *testMuseum) {
+	deviceKey := bytes.Repeat([]byte{1}, 32)
+	publicKey, secretKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &testMuseum{t: t, masterKey: eCrypto.NewStreamKey(), publicKey: publicKey[:], publicKeys: make(map[string][]byte)}
+	server := httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	t.Cleanup(server.Close)
+	account := model.Account{
+		Email:       "me@example.org",
+		UserID:      testMuseumUserID,
+		App:         api.AppPhotos,
+		MasterKey:   *model.MakeEncString(m.masterKey, deviceKey),
+		SecretKey:   *model.MakeEncString(secretKey[:], deviceKey),
+		PublicKey:   encoding.EncodeBase64(publicKey[:]),
+		Token:       *model.MakeEncString([]byte("token"), deviceKey),
+		APIEndpoint: server.URL,
+	}
+	c := newAccountsTestCtrl(t, account)
+	c.Client = api.NewClient(api.Params{})
+	c.KeyHolder = secrets.NewKeyHolder(deviceKey)
+	return c, m
+}
+
+// addCollection adds an album to the server and returns its key. Albums of other owners are shared with the
+// test account, their key is sealed with its public key.
+func (m *testMuseum) addCollection(id, ownerID int64, name string) []byte {
+	key := eCrypto.NewStreamKey()
+	collection := api.Collection{ID: id, Owner: api.CollectionUser{ID: ownerID}, Type: "album", UpdationTime: 100}
+	if ownerID == testMuseumUserID {
+		collection.EncryptedKey, collection.KeyDecryptionNonce = secretBoxSeal(m.t, key, m.masterKey)
+	} else {
+		sealed, err := eCrypto.SealedBoxSeal(key, m.publicKey)
+		if err != nil {
+			m.t.Fatal(err)
+		}
+		collection.EncryptedKey = encoding.EncodeBase64(sealed)
+	}
+	collection.EncryptedName, collection.NameDecryptionNonce = secretBoxSeal(m.t, []byte(name), key)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collections = append(m.collections, collection)
+	return key
+}
+
+// changes returns the requests which changed the state of the server
+func (m *testMuseum) changes() []museumRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]museumRequest(nil), m.requests...)
+}
+
+func (m *testMuseum) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r.Header.Get("X-Auth-Token") == "" {
+		m.t.Errorf("%s %s without the token of the account", r.Method, r.URL.Path)
+	}
+	var response interface{} = map[string]interface{}{}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/collections/v2":
+		response = map[string]interface{}{"collections": m.collections}
+	case r.Method == http.MethodGet && r.URL.Path == "/collections/v2/diff":
+		response = map[string]interface{}{"diff": []api.File{}, "hasMore": false}
+	case r.Method == http.MethodGet && r.URL.Path == "/users/public-key":
+		publicKey, ok := m.publicKeys[r.URL.Query().Get("email")]
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"code":"NOT_FOUND"}`)
+			return
+		}
+		response = map[string]interface{}{"userID": 99, "publicKey": encoding.EncodeBase64(publicKey)}
+	case r.Method == http.MethodGet:
+		m.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	default:
+		request := museumRequest{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query()}
+		if body, _ := io.ReadAll(r.Body); len(body) > 0 {
+			if err := json.Unmarshal(body, &request.Body); err != nil {
+				m.t.Errorf("invalid body of %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		}
+		m.requests = append(m.requests, request)
+		switch r.URL.Path {
+		case "/collections/rename":
+			for i := range m.collections {
+				if float64(m.collections[i].ID) == request.Body["collectionID"] {
+					m.collections[i].EncryptedName, _ = request.Body["encryptedName"].(string)
+					m.collections[i].NameDecryptionNonce, _ = request.Body["nameDecryptionNonce"].(string)
+					m.collections[i].UpdationTime++
+				}
+			}
+		case "/collections/share-url":
+			response = map[string]interface{}{"result": m.publicURL}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+func TestDeleteAlbum(t *testing.T) {
+	c, m := newMuseumTestCtrl(t)
+	m.addCollection(1, testMuseumUserID, "Trip")
+	m.addCollection(2, 8, "Their trip")
+	ctx := context.Background()
+	ref := model.AccountRef{Email: "me@example.org"}
+
+	if _, err := c.DeleteAlbum(ctx, ref, "Their trip", true); err == nil || !strings.Contains(err.Error(), "owned by another user") {
+		t.Errorf("expected an error for an album shared with the account, got %v", err)
+	}
+	if changes := m.changes(); len(changes) != 0 {
+		t.Fatalf("expected no request for an album shared with the account, got %+v", changes)
+	}
+
+	summary, err := c.DeleteAlbum(ctx, ref, "Trip", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.ID != 1 || summary.Name != "Trip" {
+		t.Errorf("expected the summary of the deleted album, got %+v", summary)
+	}
+	if _, err = c.DeleteAlbum(ctx, ref, "1", true); err != nil {
+		t.Fatal(err)
+	}
+	changes := m.changes()
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 requests, got %+v", changes)
+	}
+	for i, keepFiles := range []string{"false", "true"} {
+		if changes[i].Method != http.MethodDelete || changes[i].Path != "/collections/v3/1" || changes[i].Query.Get("keepFiles") != keepFiles {
+			t.Errorf("expected the album to be deleted with keepFiles=%s, got %+v", keepFiles, changes[i])
+		}
+	}
+}
+
+func TestRenameAlbum(t *testing.T) {
+	c, m := newMuseumTestCtrl(t)
+	key := m.addCollection(1, testMuseumUserID, "Trip")
+	m.addCollection(2, 8, "Their trip")
+	ctx := context.Background()
+	ref := model.AccountRef{Email: "me@example.org"}
+
+	if _, err := c.RenameAlbum(ctx, ref, "2", "Mine"); err == nil || !strings.Contains(err.Error(), "owned by another user") {
+		t.Errorf("expected an error for an album shared with the account, got %v", err)
+	}
+	if changes := m.changes(); len(changes) != 0 {
+		t.Fatalf("expected no request for an album shared with the account, got %+v", changes)
+	}
+
+	summary, err := c.RenameAlbum(ctx, ref, "Trip", "Holidays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := m.changes()
+	if len(changes) != 1 || changes[0].Method != http.MethodPost || changes[0].Path != "/collections/rename" {
+		t.Fatalf("expected a rename request, got %+v", changes)
+	}
+	body := changes[0].Body
+	if body["collectionID"] != float64(1) {
+		t.Errorf("expected the ID of the album, got %v", body["collectionID"])
+	}
+	encName, _ := body["encryptedName"].(string)
+	nonce, _ := body["nameDecryptionNonce"].(string)
+	if strings.Contains(encName, "Holidays") {
+		t.Error("expected the new name to be encrypted")
+	}
+	name, err := eCrypto.SecretBoxOpenBase64(encName, nonce, key)
+	if err != nil || string(name) != "Holidays" {
+		t.Errorf("expected the name encrypted with the album key, got %q: %v", name, err)
+	}
+	if summary.ID != 1 || summary.Name != "Holidays" {
+		t.Errorf("expected the summary of the renamed album, got %+v", summary)
+	}
+}
@@ -2,9 +2,12 @@ package pkg
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/ente-io/cli/pkg/model"
 	"github.com/ente-io/cli/utils/encoding"
+
+	bolt "go.etcd.io/bbolt"
 )
 
 func boltAEKey(entry *model.AlbumFileEntry) []byte {
@@ -18,3 +21,44 @@ func (c *ClICtrl) DeleteAlbumEntry(ctx context.Context, entry *model.AlbumFileEn
 func (c *ClICtrl) UpsertAlbumEntry(ctx context.Context, entry *model.AlbumFileEntry) error {
 	return c.PutValue(ctx, model.RemoteAlbumEntries, boltAEKey(entry), encoding.MustMarshalJSON(entry))
 }
+
+// resetExportState marks all the album entries of the account as not synced locally and clears the
+// export index, so that the next export writes all the files to the new export target.
+func (c *ClICtrl) resetExportState(ctx context.Context, account model.Account) error {
+	err := createDataBuckets(c.DB, account)
+	if err != nil {
+		return err
+	}
+	return c.DB.Update(func(tx *bolt.Tx) error {
+		entriesStore, err := getAccountStore(ctx, tx, model.RemoteAlbumEntries)
+		if err != nil {
+			return err
+		}
+		updates := make(map[string][]byte)
+		err = entriesStore.ForEach(func(k, v []byte) error {
+			var entry model.AlbumFileEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if entry.SyncedLocally {
+				entry.SyncedLocally = false
+				updates[string(k)] = encoding.MustMarshalJSON(entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, v := range updates {
+			if err = entriesStore.Put([]byte(k), v); err != nil {
+				return err
+			}
+		}
+		accountBucket := tx.Bucket([]byte(account.AccountKey()))
+		if err = accountBucket.DeleteBucket([]byte(model.ExportIndex)); err != nil {
+			return err
+		}
+		_, err = accountBucket.CreateBucket([]byte(model.ExportIndex))
+		return err
+	})
+}
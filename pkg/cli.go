@@ -1,21 +1,67 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
 	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/pkg/model"
 	"github.com/ente-io/cli/pkg/secrets"
 	bolt "go.etcd.io/bbolt"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
 )
 
 type ClICtrl struct {
-	Client     *api.Client
-	DB         *bolt.DB
-	KeyHolder  *secrets.KeyHolder
+	Client *api.Client
+	DB     *bolt.DB
+	// KeyHolder is set by the commands which use the secrets of the accounts, once the device key is loaded
+	KeyHolder *secrets.KeyHolder
+	// DataDir holds the cli database
+	DataDir string
+	// Logger defaults to slog.Default(), account operations log through the logger of their context
+	Logger *slog.Logger
+	// AccountSettings are the settings of the accounts in the config file
+	AccountSettings []model.AccountSettings
+	// Transport connects to the servers other than ente, like the S3 export buckets. The default one is used when it's nil.
+	Transport  http.RoundTripper
 	tempFolder string
 }
 
+// SetLogger sets the logger of the controller and of its api client
+func (c *ClICtrl) SetLogger(logger *slog.Logger) {
+	c.Logger = logger
+	c.Client.SetLogger(logger)
+}
+
+// accountSettings returns the settings of the account in the config file, the zero value if it has none.
+// It fails when the settings don't select a server and the email of the account is also used on another
+// server, as it's unclear which of the accounts they are meant for.
+func (c *ClICtrl) accountSettings(account model.Account, accounts []model.Account) (model.AccountSettings, error) {
+	for _, settings := range c.AccountSettings {
+		if !settings.Matches(account) {
+			continue
+		}
+		if settings.API == "" {
+			for _, other := range accounts {
+				if other.AccountKey() != account.AccountKey() && settings.Matches(other) {
+					return model.AccountSettings{}, fmt.Errorf("the config file settings of %s (%s) match accounts on several servers, "+
+						"set their api-endpoint", account.Email, account.App)
+				}
+			}
+		}
+		return settings, nil
+	}
+	return model.AccountSettings{}, nil
+}
+
+// log returns the logger of the context, tagged with the account being processed, or the controller's logger
+func (c *ClICtrl) log(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, c.Logger)
+}
+
 func (c *ClICtrl) Init() error {
 	tempPath := filepath.Join(os.TempDir(), "ente-download")
 	// create temp folder if not exists
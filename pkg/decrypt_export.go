@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/pkg/storage"
+	"path"
+	"strings"
+)
+
+// DecryptExport writes the plain files of the encrypted export in srcDir to destDir,
+// keeping the same layout. It returns the number of decrypted files.
+func DecryptExport(ctx context.Context, srcDir, destDir, passphrase string) (int, error) {
+	if _, err := internal.ValidateDirForWrite(destDir); err != nil {
+		return 0, fmt.Errorf("error: %v while validating %s", err, destDir)
+	}
+	source := storage.NewLocal(srcDir)
+	params, err := storage.ReadEncryptionParams(ctx, source)
+	if err != nil {
+		return 0, err
+	}
+	if params == nil {
+		return 0, fmt.Errorf("%s is not an encrypted export", srcDir)
+	}
+	key, err := params.DeriveKey(passphrase)
+	if err != nil {
+		return 0, err
+	}
+	encrypted, err := storage.NewEncrypted(ctx, source, key)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = decryptDir(ctx, encrypted, storage.NewLocal(destDir), "", &count)
+	return count, err
+}
+
+func decryptDir(ctx context.Context, src storage.Backend, dest storage.Backend, dir string, count *int) error {
+	entries, err := src.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if err = dest.MkdirAll(ctx, dir); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		entryPath := path.Join(dir, entry.Name)
+		if entry.IsDir {
+			if err = decryptDir(ctx, src, dest, entryPath, count); err != nil {
+				return err
+			}
+			continue
+		}
+		if entryPath == storage.EncryptionParamsFile {
+			continue
+		}
+		if path.Base(dir) == albumMetaFolder && strings.HasSuffix(entry.Name, ".json") {
+			var sidecar json.RawMessage
+			if err = src.ReadSidecar(ctx, entryPath, &sidecar); err == nil {
+				err = dest.WriteSidecar(ctx, entryPath, sidecar)
+			}
+		} else {
+			err = decryptFile(ctx, src, dest, entryPath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", entryPath, err)
+		}
+		*count++
+		logging.FromContext(ctx, nil).Info("decrypted file", "path", entryPath)
+	}
+	return nil
+}
+
+func decryptFile(ctx context.Context, src storage.Backend, dest storage.Backend, p string) error {
+	r, err := src.Open(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return dest.Put(ctx, p, r)
+}
@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"github.com/ente-io/cli/pkg/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecryptExportRestoresNames(t *testing.T) {
+	ctx := context.Background()
+	srcDir, destDir := t.TempDir(), t.TempDir()
+	source := storage.NewLocal(srcDir)
+	key, err := storage.SetupEncryption(ctx, source, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := storage.NewEncrypted(ctx, source, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = encrypted.MkdirAll(ctx, "Trip/"+albumMetaFolder); err != nil {
+		t.Fatal(err)
+	}
+	if err = encrypted.Put(ctx, "Trip/beach.jpg", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+	if err = encrypted.WriteSidecar(ctx, "Trip/"+albumMetaFolder+"/beach.jpg.json", map[string]string{"title": "beach.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.WalkDir(srcDir, func(p string, _ os.DirEntry, err error) error {
+		if strings.Contains(p, "Trip") || strings.Contains(p, "beach") {
+			t.Errorf("%s has names in plain text", p)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = DecryptExport(ctx, srcDir, destDir, "wrong"); !errors.Is(err, storage.ErrWrongPassphrase) {
+		t.Errorf("expected an error for a wrong passphrase, got %v", err)
+	}
+	count, err := DecryptExport(ctx, srcDir, destDir, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 decrypted files, got %d", count)
+	}
+	content, err := os.ReadFile(filepath.Join(destDir, "Trip", "beach.jpg"))
+	if err != nil || string(content) != "content" {
+		t.Errorf("unexpected content %q: %v", content, err)
+	}
+	sidecar, err := os.ReadFile(filepath.Join(destDir, "Trip", albumMetaFolder, "beach.jpg.json"))
+	if err != nil || !strings.Contains(string(sidecar), `"beach.jpg"`) {
+		t.Errorf("unexpected sidecar %q: %v", sidecar, err)
+	}
+}
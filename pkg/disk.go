@@ -1,13 +1,11 @@
 package pkg
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/ente-io/cli/pkg/model"
 	"github.com/ente-io/cli/pkg/model/export"
-	"io"
-	"os"
+	"github.com/ente-io/cli/pkg/storage"
 	"strings"
 )
 
@@ -17,8 +15,8 @@ const (
 )
 
 type albumDiskInfo struct {
-	ExportRoot string
-	AlbumMeta  *export.AlbumMetadata
+	Target    storage.Backend
+	AlbumMeta *export.AlbumMetadata
 	// FileNames contain the name of the files at root level of the album folder
 	FileNames                 *map[string]bool
 	MetaFileNameToDiskFileMap *map[string]*export.DiskFileMetadata
@@ -113,64 +111,3 @@ func (a *albumDiskInfo) GetDiskFileMetadata(file model.RemoteFile) *export.DiskF
 	}
 	return diskFile
 }
-
-func writeJSONToFile(filePath string, data interface{}) error {
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
-}
-
-func readJSONFromFile(filePath string, data interface{}) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	decoder := json.NewDecoder(file)
-	return decoder.Decode(data)
-}
-
-func Move(source, destination string) error {
-	err := os.Rename(source, destination)
-	if err != nil {
-		return moveCrossDevice(source, destination)
-	}
-	return err
-}
-
-func moveCrossDevice(source, destination string) error {
-	src, err := os.Open(source)
-	if err != nil {
-		return err
-	}
-	dst, err := os.Create(destination)
-	if err != nil {
-		src.Close()
-		return err
-	}
-	_, err = io.Copy(dst, src)
-	src.Close()
-	dst.Close()
-	if err != nil {
-		return err
-	}
-	fi, err := os.Stat(source)
-	if err != nil {
-		os.Remove(destination)
-		return err
-	}
-	err = os.Chmod(destination, fi.Mode())
-	if err != nil {
-		os.Remove(destination)
-		return err
-	}
-	os.Remove(source)
-	return nil
-}
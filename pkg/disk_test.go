@@ -1,6 +1,13 @@
 package pkg
 
 import (
+	"archive/zip"
+	"context"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/model/export"
+	"github.com/ente-io/cli/pkg/storage"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -30,3 +37,102 @@ func TestGenerateUniqueFileName(t *testing.T) {
 		}
 	}
 }
+
+func TestReadExportMetadata(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewMemory()
+	if err := backend.MkdirAll(ctx, path.Join("Trip", albumMetaFolder)); err != nil {
+		t.Fatalf("Failed to create album folder: %v", err)
+	}
+	albumMeta := export.AlbumMetadata{ID: 1, AlbumName: "Trip", FolderName: "Trip"}
+	if err := backend.WriteSidecar(ctx, path.Join("Trip", albumMetaFolder, albumMetaFile), albumMeta); err != nil {
+		t.Fatalf("Failed to write album metadata: %v", err)
+	}
+	fileMeta := export.DiskFileMetadata{Title: "a.jpg", Info: &export.Info{ID: 10, FileNames: []string{"a.jpg"}}}
+	if err := backend.WriteSidecar(ctx, path.Join("Trip", albumMetaFolder, "a.jpg.json"), fileMeta); err != nil {
+		t.Fatalf("Failed to write file metadata: %v", err)
+	}
+	if err := backend.Put(ctx, "Trip/a.jpg", strings.NewReader("content")); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	_, albumIDToMeta, err := readFolderMetadata(ctx, backend)
+	if err != nil {
+		t.Fatalf("Failed to read folder metadata: %v", err)
+	}
+	if albumIDToMeta[1] == nil || albumIDToMeta[1].FolderName != "Trip" {
+		t.Fatalf("Album metadata not found")
+	}
+	diskInfo, err := readFilesMetadata(ctx, backend, albumIDToMeta[1])
+	if err != nil {
+		t.Fatalf("Failed to read files metadata: %v", err)
+	}
+	if diskInfo.GetDiskFileMetadata(model.RemoteFile{ID: 10}) == nil || !diskInfo.IsFileNamePresent("A.JPG") {
+		t.Fatalf("File metadata not found")
+	}
+}
+
+// writeLiveZip creates a live photo zip with the given parts, named like UnpackLive expects them
+func writeLiveZip(t *testing.T, zipPath string, parts map[string]string) {
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zipFile.Close()
+	writer := zip.NewWriter(zipFile)
+	for name, content := range parts {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteDecryptedLivePhoto(t *testing.T) {
+	ctx := context.Background()
+	backend := storage.NewMemory()
+	if err := backend.MkdirAll(ctx, path.Join("Trip", albumMetaFolder)); err != nil {
+		t.Fatal(err)
+	}
+	albumMeta := &export.AlbumMetadata{ID: 1, AlbumName: "Trip", FolderName: "Trip"}
+	diskInfo, err := readFilesMetadata(ctx, backend, albumMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newLivePhoto := func(id int64, title string) model.RemoteFile {
+		return model.RemoteFile{ID: id, Metadata: map[string]interface{}{
+			"title": title, "fileType": float64(model.LivePhoto), "creationTime": float64(1), "modificationTime": float64(1),
+		}}
+	}
+
+	zipPath := filepath.Join(t.TempDir(), "live.zip")
+	writeLiveZip(t, zipPath, map[string]string{"image.heic": "image", "video.mov": "video"})
+	if err = writeDecryptedFile(ctx, diskInfo, newLivePhoto(10, "IMG_1.HEIC"), zipPath); err != nil {
+		t.Fatalf("Failed to write live photo: %v", err)
+	}
+	diskFile := diskInfo.GetDiskFileMetadata(model.RemoteFile{ID: 10})
+	if diskFile == nil || len(diskFile.Info.FileNames) != 2 {
+		t.Fatalf("expected the image and the video in the metadata, got %+v", diskFile)
+	}
+	for _, name := range []string{"IMG_1.heic", "IMG_1.mov"} {
+		if _, err = backend.Stat(ctx, path.Join("Trip", name)); err != nil {
+			t.Errorf("expected %s in the export: %v", name, err)
+		}
+	}
+
+	// live photos which lost their video keep the image
+	zipPath = filepath.Join(t.TempDir(), "live.zip")
+	writeLiveZip(t, zipPath, map[string]string{"image.jpg": "image"})
+	if err = writeDecryptedFile(ctx, diskInfo, newLivePhoto(11, "IMG_2.JPG"), zipPath); err != nil {
+		t.Fatalf("Failed to write live photo without video: %v", err)
+	}
+	if diskFile = diskInfo.GetDiskFileMetadata(model.RemoteFile{ID: 11}); diskFile == nil || len(diskFile.Info.FileNames) != 1 {
+		t.Fatalf("expected only the image in the metadata, got %+v", diskFile)
+	}
+}
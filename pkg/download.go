@@ -4,12 +4,14 @@ import (
 	"archive/zip"
 	"context"
 	"fmt"
+	"github.com/ente-io/cli/internal/api"
 	"github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/internal/metrics"
+	"github.com/ente-io/cli/internal/progress"
 	"github.com/ente-io/cli/pkg/model"
 	"github.com/ente-io/cli/utils"
 	"github.com/ente-io/cli/utils/encoding"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -24,10 +26,10 @@ func (c *ClICtrl) downloadAndDecrypt(
 	downloadPath := fmt.Sprintf("%s/%d", dir, file.ID)
 	// check if file exists
 	if stat, err := os.Stat(downloadPath); err == nil && stat.Size() == file.Info.FileSize {
-		log.Printf("File already exists %s (%s)", file.GetTitle(), utils.ByteCountDecimal(file.Info.FileSize))
+		c.log(ctx).Debug("file already downloaded", "file", file.GetTitle(), "size", utils.ByteCountDecimal(file.Info.FileSize))
 	} else {
-		log.Printf("Downloading %s (%s)", file.GetTitle(), utils.ByteCountDecimal(file.Info.FileSize))
-		err := c.Client.DownloadFile(ctx, file.ID, downloadPath)
+		c.log(ctx).Debug("downloading file", "file", file.GetTitle(), "size", utils.ByteCountDecimal(file.Info.FileSize))
+		err := c.downloadFile(ctx, file.ID, downloadPath)
 		if err != nil {
 			return nil, fmt.Errorf("error downloading file %d: %w", file.ID, err)
 		}
@@ -35,7 +37,8 @@ func (c *ClICtrl) downloadAndDecrypt(
 	decryptedPath := fmt.Sprintf("%s/%d.decrypted", dir, file.ID)
 	err := crypto.DecryptFile(downloadPath, decryptedPath, file.Key.MustDecrypt(deviceKey), encoding.DecodeBase64(file.FileNonce))
 	if err != nil {
-		log.Printf("Error decrypting file %d: %s", file.ID, err)
+		c.log(ctx).Error("failed to decrypt file", "fileID", file.ID, "error", err)
+		_ = os.Remove(decryptedPath)
 		return nil, model.ErrDecryption
 	} else {
 		_ = os.Remove(downloadPath)
@@ -43,6 +46,34 @@ func (c *ClICtrl) downloadAndDecrypt(
 	return &decryptedPath, nil
 }
 
+// downloadFile writes the encrypted file to downloadPath, counting the bytes in the progress of the context
+// and in the metrics. The download starts over when the connection breaks while the file is read, failed
+// requests are already retried by the client.
+func (c *ClICtrl) downloadFile(ctx context.Context, fileID int64, downloadPath string) error {
+	tracker := progress.FromContext(ctx)
+	return api.DefaultRetryPolicy.Do(ctx, "file", func() error {
+		stream, err := c.Client.DownloadFileStream(ctx, fileID)
+		if err != nil {
+			return api.Permanent(err)
+		}
+		defer stream.Close()
+		out, err := os.Create(downloadPath)
+		if err != nil {
+			return api.Permanent(err)
+		}
+		tracker.RestartFile()
+		reader := metrics.NewReader(progress.NewReader(stream, tracker), accountLabel(ctx))
+		_, err = io.Copy(out, reader)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(downloadPath)
+		}
+		return err
+	})
+}
+
 func UnpackLive(src string) (imagePath, videoPath string, retErr error) {
 	var filenames []string
 	reader, err := zip.OpenReader(src)
@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/storage"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// getExportBackend returns the storage backend configured for the account
+func (c *ClICtrl) getExportBackend(ctx context.Context, account model.Account) (storage.Backend, error) {
+	backend, err := c.getDestinationBackend(ctx, account)
+	if err != nil || account.ExportKey == nil {
+		return backend, err
+	}
+	return storage.NewEncrypted(ctx, backend, account.ExportKey.MustDecrypt(c.KeyHolder.DeviceKey))
+}
+
+// getDestinationBackend returns the backend for the account's export destination, without encryption
+func (c *ClICtrl) getDestinationBackend(ctx context.Context, account model.Account) (storage.Backend, error) {
+	if account.S3 != nil {
+		return storage.NewS3(ctx, c.s3Options(*account.S3), &exportIndex{c: c})
+	}
+	if account.ExportDir == "" {
+		return nil, errors.New("no export directory configured")
+	}
+	if _, err := internal.ValidateDirForWrite(account.ExportDir); err != nil {
+		return nil, fmt.Errorf("error: %v while validating exportDir %s", err, account.ExportDir)
+	}
+	return storage.NewLocal(account.ExportDir), nil
+}
+
+func (c *ClICtrl) s3Options(config model.S3Config) storage.S3Options {
+	return storage.S3Options{
+		Endpoint:  config.Endpoint,
+		Region:    config.Region,
+		Bucket:    config.Bucket,
+		Prefix:    config.Prefix,
+		AccessKey: config.AccessKey,
+		SecretKey: string(config.SecretKey.MustDecrypt(c.KeyHolder.DeviceKey)),
+		Insecure:  config.Insecure,
+		Transport: c.Transport,
+	}
+}
+
+// exportIndex keeps the index of the S3 backend in the account's ExportIndex store
+type exportIndex struct {
+	c *ClICtrl
+}
+
+func (e *exportIndex) Get(ctx context.Context, key string) ([]byte, error) {
+	return e.c.GetValue(ctx, model.ExportIndex, []byte(key))
+}
+
+func (e *exportIndex) Put(ctx context.Context, key string, value []byte) error {
+	return e.c.PutValue(ctx, model.ExportIndex, []byte(key), value)
+}
+
+func (e *exportIndex) Delete(ctx context.Context, key string) error {
+	return e.c.DeleteValue(ctx, model.ExportIndex, []byte(key))
+}
+
+func (e *exportIndex) Scan(ctx context.Context, prefix string, fn func(key string, value []byte) error) error {
+	err := e.c.DB.View(func(tx *bolt.Tx) error {
+		store, err := getAccountStore(ctx, tx, model.ExportIndex)
+		if err != nil {
+			return err
+		}
+		cursor := store.Cursor()
+		for k, v := cursor.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = cursor.Next() {
+			if err = fn(string(k), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, storage.ErrStopScan) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,239 @@
+// Package gallery serves a read-only HTML gallery of albums, for browsing a backup from a web browser.
+package gallery
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/pkg/model/export"
+)
+
+// ErrNotFound is returned by a Source for unknown albums and files
+var ErrNotFound = errors.New("not found")
+
+// Source provides the albums shown in the gallery. Albums and files are identified by their name.
+type Source interface {
+	Albums(ctx context.Context) ([]Album, error)
+	// Files returns the files of the album, Info.FileNames lists the parts of each file, starting with the main one
+	Files(ctx context.Context, album string) ([]*export.DiskFileMetadata, error)
+	// Open returns the content of a part of a file in the album
+	Open(ctx context.Context, album, name string) (Content, error)
+	// Thumbnail returns a jpeg preview of the file with the given main part
+	Thumbnail(ctx context.Context, album, name string) ([]byte, error)
+}
+
+type Album struct {
+	Name  string
+	Count int
+}
+
+type Content struct {
+	io.ReadSeekCloser
+	ModTime time.Time
+}
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"path": pathEscape,
+	"date": formatDate,
+	"isImage": func(name string) bool {
+		return imageExtensions[strings.ToLower(path.Ext(name))]
+	},
+	"isVideo": func(name string) bool {
+		return videoExtensions[strings.ToLower(path.Ext(name))]
+	},
+}).ParseFS(templateFS, "templates/*.html"))
+
+// extensions which browsers can display inline
+var (
+	imageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".avif": true}
+	videoExtensions = map[string]bool{".mp4": true, ".webm": true, ".m4v": true, ".mov": true}
+)
+
+// maxCachedThumbnails bounds the memory used by the thumbnail cache, thumbnails are usually below 50KB
+const maxCachedThumbnails = 2000
+
+type handler struct {
+	source Source
+	title  string
+
+	mu         sync.Mutex
+	thumbnails map[string][]byte
+	order      []string
+}
+
+// NewHandler returns the http handler for the gallery
+func NewHandler(source Source, title string) http.Handler {
+	h := &handler{source: source, title: title, thumbnails: make(map[string][]byte)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", h.serveAlbums)
+	mux.HandleFunc("/albums/", h.serveAlbum)
+	mux.HandleFunc("/details/", h.serveDetails)
+	mux.HandleFunc("/thumbnails/", h.serveThumbnail)
+	mux.HandleFunc("/files/", h.serveFile)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// splitPath returns the album and the optional file name following the prefix
+func splitPath(r *http.Request, prefix string) (string, string) {
+	album, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	return album, name
+}
+
+func pathEscape(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	return strings.Join(escaped, "/")
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format("2 Jan 2006 15:04")
+}
+
+func (h *handler) fail(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	logging.FromContext(r.Context(), nil).Error("gallery request failed", "path", r.URL.Path, "error", err)
+	http.Error(w, "internal error", http.StatusInternalServerError)
+}
+
+func (h *handler) render(w http.ResponseWriter, name string, data map[string]interface{}) {
+	data["Title"] = h.title
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates.ExecuteTemplate(w, name, data); err != nil {
+		slog.Error("failed to render template", "template", name, "error", err)
+	}
+}
+
+func (h *handler) serveAlbums(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	albums, err := h.source.Albums(r.Context())
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	sort.Slice(albums, func(i, j int) bool { return strings.ToLower(albums[i].Name) < strings.ToLower(albums[j].Name) })
+	h.render(w, "albums.html", map[string]interface{}{"Albums": albums})
+}
+
+// sortedFiles returns the files of the album, newest first
+func (h *handler) sortedFiles(ctx context.Context, album string) ([]*export.DiskFileMetadata, error) {
+	files, err := h.source.Files(ctx, album)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].CreationTime.After(files[j].CreationTime) })
+	return files, nil
+}
+
+func (h *handler) serveAlbum(w http.ResponseWriter, r *http.Request) {
+	album, _ := splitPath(r, "/albums/")
+	files, err := h.sortedFiles(r.Context(), album)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	h.render(w, "album.html", map[string]interface{}{"Album": album, "Files": files})
+}
+
+func (h *handler) serveDetails(w http.ResponseWriter, r *http.Request) {
+	album, name := splitPath(r, "/details/")
+	files, err := h.sortedFiles(r.Context(), album)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	for i, file := range files {
+		if len(file.Info.FileNames) == 0 || file.Info.FileNames[0] != name {
+			continue
+		}
+		data := map[string]interface{}{"Album": album, "File": file, "Name": name}
+		if i > 0 {
+			data["Previous"] = files[i-1].Info.FileNames[0]
+		}
+		if i+1 < len(files) && len(files[i+1].Info.FileNames) > 0 {
+			data["Next"] = files[i+1].Info.FileNames[0]
+		}
+		h.render(w, "file.html", data)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (h *handler) serveThumbnail(w http.ResponseWriter, r *http.Request) {
+	album, name := splitPath(r, "/thumbnails/")
+	key := album + "/" + name
+	h.mu.Lock()
+	thumbnail, ok := h.thumbnails[key]
+	h.mu.Unlock()
+	if !ok {
+		var err error
+		if thumbnail, err = h.source.Thumbnail(r.Context(), album, name); err != nil {
+			h.fail(w, r, err)
+			return
+		}
+		h.cacheThumbnail(key, thumbnail)
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	_, _ = w.Write(thumbnail)
+}
+
+func (h *handler) cacheThumbnail(key string, thumbnail []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.thumbnails[key]; ok {
+		return
+	}
+	if len(h.order) >= maxCachedThumbnails {
+		delete(h.thumbnails, h.order[0])
+		h.order = h.order[1:]
+	}
+	h.thumbnails[key] = thumbnail
+	h.order = append(h.order, key)
+}
+
+func (h *handler) serveFile(w http.ResponseWriter, r *http.Request) {
+	album, name := splitPath(r, "/files/")
+	content, err := h.source.Open(r.Context(), album, name)
+	if err != nil {
+		h.fail(w, r, err)
+		return
+	}
+	defer content.Close()
+	if r.URL.Query().Has("download") {
+		w.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(name))
+	}
+	http.ServeContent(w, r, name, content.ModTime, content)
+}
@@ -0,0 +1,86 @@
+package gallery
+
+import (
+	"context"
+	"github.com/ente-io/cli/pkg/library"
+	"github.com/ente-io/cli/pkg/mapper"
+	"github.com/ente-io/cli/pkg/model/export"
+	"io"
+)
+
+// librarySource shows the albums of the local store, downloading the files on demand
+type librarySource struct {
+	lib *library.Library
+}
+
+// NewLibrarySource returns a source for the albums of the library. Thumbnails are fetched from the server.
+func NewLibrarySource(lib *library.Library) Source {
+	return &librarySource{lib: lib}
+}
+
+func (s *librarySource) Albums(ctx context.Context) ([]Album, error) {
+	var albums []Album
+	for _, album := range s.lib.Albums() {
+		albums = append(albums, Album{Name: album.Name, Count: len(album.Files())})
+	}
+	return albums, nil
+}
+
+func (s *librarySource) Files(ctx context.Context, albumName string) ([]*export.DiskFileMetadata, error) {
+	album, ok := s.lib.Album(albumName)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	var files []*export.DiskFileMetadata
+	for _, file := range album.Files() {
+		metadata := mapper.MapRemoteFileToDiskMetadata(file.Remote())
+		metadata.Info.FileNames = []string{file.Name}
+		files = append(files, metadata)
+	}
+	return files, nil
+}
+
+func (s *librarySource) lookup(albumName, name string) (*library.File, error) {
+	album, ok := s.lib.Album(albumName)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	file, ok := album.File(name)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return file, nil
+}
+
+func (s *librarySource) Open(ctx context.Context, albumName, name string) (Content, error) {
+	file, err := s.lookup(albumName, name)
+	if err != nil {
+		return Content{}, err
+	}
+	reader, err := s.lib.Open(file)
+	if err != nil {
+		return Content{}, err
+	}
+	size, err := reader.Size()
+	if err != nil {
+		_ = reader.Close()
+		return Content{}, err
+	}
+	return Content{
+		ReadSeekCloser: sectionReadCloser{io.NewSectionReader(reader, 0, size), reader},
+		ModTime:        file.CreationTime,
+	}, nil
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+	io.Closer
+}
+
+func (s *librarySource) Thumbnail(ctx context.Context, albumName, name string) ([]byte, error) {
+	file, err := s.lookup(albumName, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.lib.Thumbnail(file)
+}
@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/pkg/gallery"
+	"github.com/ente-io/cli/pkg/model/export"
+	"github.com/ente-io/cli/pkg/storage"
+	"github.com/ente-io/cli/pkg/thumbnail"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	galleryThumbnailDimension = 360
+	// galleryRefreshInterval is how long the metadata of an album is reused, so that a running export shows up
+	galleryRefreshInterval = time.Minute
+)
+
+// exportGallery shows the albums of a plain export directory, using the metadata written by the export
+type exportGallery struct {
+	dir    *storage.Local
+	mu     sync.Mutex
+	albums map[string]*exportGalleryAlbum
+}
+
+type exportGalleryAlbum struct {
+	loadedAt time.Time
+	files    []*export.DiskFileMetadata
+	// parts maps the name of every file of the album to its metadata
+	parts map[string]*export.DiskFileMetadata
+}
+
+// NewExportGallery returns a gallery source for the export in dir
+func NewExportGallery(ctx context.Context, dir string) (gallery.Source, error) {
+	local := storage.NewLocal(dir)
+	params, err := storage.ReadEncryptionParams(ctx, local)
+	if err != nil {
+		return nil, err
+	}
+	if params != nil {
+		return nil, fmt.Errorf("%s is an encrypted export, decrypt it first with decrypt-export", dir)
+	}
+	return &exportGallery{dir: local, albums: make(map[string]*exportGalleryAlbum)}, nil
+}
+
+func (e *exportGallery) Albums(ctx context.Context) ([]gallery.Album, error) {
+	folders, _, err := readFolderMetadata(ctx, e.dir)
+	if err != nil {
+		return nil, err
+	}
+	var albums []gallery.Album
+	for folder, meta := range folders {
+		if meta == nil {
+			continue
+		}
+		album, err := e.album(ctx, folder)
+		if err != nil {
+			return nil, err
+		}
+		albums = append(albums, gallery.Album{Name: folder, Count: len(album.files)})
+	}
+	return albums, nil
+}
+
+func (e *exportGallery) album(ctx context.Context, folder string) (*exportGalleryAlbum, error) {
+	if folder == "" || strings.HasPrefix(folder, ".") || strings.ContainsAny(folder, `/\`) {
+		return nil, gallery.ErrNotFound
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if album, ok := e.albums[folder]; ok && time.Since(album.loadedAt) < galleryRefreshInterval {
+		return album, nil
+	}
+	diskInfo, err := readFilesMetadata(ctx, e.dir, &export.AlbumMetadata{FolderName: folder})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, gallery.ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	album := &exportGalleryAlbum{loadedAt: time.Now(), parts: make(map[string]*export.DiskFileMetadata)}
+	for _, file := range *diskInfo.MetaFileNameToDiskFileMap {
+		if file == nil || file.Info == nil || len(file.Info.FileNames) == 0 {
+			continue
+		}
+		album.files = append(album.files, file)
+		for _, name := range file.Info.FileNames {
+			album.parts[name] = file
+		}
+	}
+	e.albums[folder] = album
+	return album, nil
+}
+
+func (e *exportGallery) Files(ctx context.Context, folder string) ([]*export.DiskFileMetadata, error) {
+	album, err := e.album(ctx, folder)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*export.DiskFileMetadata(nil), album.files...), nil
+}
+
+// filePath returns the path of a file listed in the album's metadata
+func (e *exportGallery) filePath(ctx context.Context, folder, name string) (string, *export.DiskFileMetadata, error) {
+	album, err := e.album(ctx, folder)
+	if err != nil {
+		return "", nil, err
+	}
+	file, ok := album.parts[name]
+	if !ok {
+		return "", nil, gallery.ErrNotFound
+	}
+	return e.dir.Path(folder + "/" + name), file, nil
+}
+
+func (e *exportGallery) Open(ctx context.Context, folder, name string) (gallery.Content, error) {
+	filePath, file, err := e.filePath(ctx, folder, name)
+	if err != nil {
+		return gallery.Content{}, err
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return gallery.Content{}, err
+	}
+	return gallery.Content{ReadSeekCloser: f, ModTime: file.ModificationTime}, nil
+}
+
+func (e *exportGallery) Thumbnail(ctx context.Context, folder, name string) ([]byte, error) {
+	filePath, _, err := e.filePath(ctx, folder, name)
+	if err != nil {
+		return nil, err
+	}
+	return thumbnail.Generate(ctx, filePath, galleryThumbnailDimension)
+}
@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"context"
+	"github.com/ente-io/cli/pkg/gallery"
+	"github.com/ente-io/cli/pkg/model/export"
+	"github.com/ente-io/cli/pkg/storage"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestExportGallery(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	backend := storage.NewLocal(dir)
+	if err := backend.MkdirAll(ctx, path.Join("Trip", albumMetaFolder)); err != nil {
+		t.Fatalf("Failed to create album folder: %v", err)
+	}
+	albumMeta := export.AlbumMetadata{ID: 1, AlbumName: "Trip", FolderName: "Trip"}
+	if err := backend.WriteSidecar(ctx, path.Join("Trip", albumMetaFolder, albumMetaFile), albumMeta); err != nil {
+		t.Fatalf("Failed to write album metadata: %v", err)
+	}
+	caption := "Sunset at the beach"
+	fileMeta := export.DiskFileMetadata{
+		Title:       "a b.jpg",
+		Description: &caption,
+		Location:    &export.Location{Latitude: 12.5, Longitude: 77.25},
+		Info:        &export.Info{ID: 10, FileNames: []string{"a b.jpg"}},
+	}
+	if err := backend.WriteSidecar(ctx, path.Join("Trip", albumMetaFolder, "a b.jpg.json"), fileMeta); err != nil {
+		t.Fatalf("Failed to write file metadata: %v", err)
+	}
+	if err := backend.Put(ctx, "Trip/a b.jpg", strings.NewReader("not really a jpeg")); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := backend.Put(ctx, "Trip/other.txt", strings.NewReader("not exported")); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	source, err := NewExportGallery(ctx, dir)
+	if err != nil {
+		t.Fatalf("Failed to open gallery: %v", err)
+	}
+	server := httptest.NewServer(gallery.NewHandler(source, "Family"))
+	defer server.Close()
+	get := func(p string) (int, string) {
+		resp, err := http.Get(server.URL + p)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", p, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	if status, body := get("/"); status != http.StatusOK || !strings.Contains(body, `href="/albums/Trip/"`) {
+		t.Fatalf("Unexpected album list %d: %s", status, body)
+	}
+	if status, body := get("/albums/Trip/"); status != http.StatusOK || !strings.Contains(body, `src="/thumbnails/Trip/a%20b.jpg"`) {
+		t.Fatalf("Unexpected album page %d: %s", status, body)
+	}
+	status, body := get("/details/Trip/a%20b.jpg")
+	if status != http.StatusOK || !strings.Contains(body, caption) || !strings.Contains(body, "12.50000, 77.25000") {
+		t.Fatalf("Unexpected details page %d: %s", status, body)
+	}
+	if status, body = get("/files/Trip/a%20b.jpg"); status != http.StatusOK || body != "not really a jpeg" {
+		t.Fatalf("Unexpected file %d: %s", status, body)
+	}
+	if status, _ = get("/thumbnails/Trip/a%20b.jpg"); status != http.StatusOK {
+		t.Fatalf("Unexpected thumbnail status %d", status)
+	}
+	for _, p := range []string{"/files/Trip/other.txt", "/files/../Trip/a%20b.jpg", "/albums/Missing/", "/details/Trip/missing.jpg"} {
+		if status, _ = get(p); status != http.StatusNotFound {
+			t.Fatalf("Expected 404 for %s, got %d", p, status)
+		}
+	}
+}
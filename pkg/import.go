@@ -0,0 +1,502 @@
+package pkg
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal/api"
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/model/export"
+	"github.com/ente-io/cli/pkg/storage"
+	"github.com/ente-io/cli/pkg/thumbnail"
+	"github.com/ente-io/cli/utils/encoding"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var videoExtensions = map[string]bool{
+	".mp4": true, ".mov": true, ".m4v": true, ".avi": true, ".mkv": true, ".webm": true,
+	".3gp": true, ".mts": true, ".m2ts": true, ".wmv": true, ".mpg": true, ".mpeg": true,
+}
+
+// importIndex keeps track of the remote state of the account that is relevant while importing
+type importIndex struct {
+	// albumsByName contains the non-deleted albums owned by the user
+	albumsByName map[string]*model.RemoteAlbum
+	// hashToFile contains the files owned by the user which are present in at least one album
+	hashToFile map[string]*model.RemoteFile
+	// albumHashes contains the hashes of the files present in each album
+	albumHashes map[int64]map[string]bool
+}
+
+func (i *importIndex) addFile(albumID int64, hash string, file *model.RemoteFile) {
+	if _, ok := i.albumHashes[albumID]; !ok {
+		i.albumHashes[albumID] = make(map[string]bool)
+	}
+	i.albumHashes[albumID][hash] = true
+	if file != nil {
+		i.hashToFile[hash] = file
+	}
+}
+
+// ImportExport uploads the content of an export directory to the given account.
+// Albums are matched by their name and created if missing. Files whose hash is already
+// present remotely are either skipped or only added to the target album.
+func (c *ClICtrl) ImportExport(ctx context.Context, params model.ImportParams) (*model.ImportResult, error) {
+	account, err := c.getAccount(ctx, model.AccountRef{Email: params.Email, API: params.API}, params.App)
+	if err != nil {
+		return nil, err
+	}
+	if account.App != api.AppPhotos {
+		return nil, fmt.Errorf("import is only supported for photos accounts")
+	}
+	source := storage.NewLocal(params.ExportDir)
+	if encParams, err := storage.ReadEncryptionParams(ctx, source); err != nil {
+		return nil, err
+	} else if encParams != nil {
+		return nil, fmt.Errorf("%s is an encrypted export, restore it with decrypt-export first", params.ExportDir)
+	}
+	folderToMetaMap, _, err := readFolderMetadata(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = c.loadAccount(ctx, *account)
+	if err != nil {
+		return nil, err
+	}
+	c.log(ctx).Info("syncing remote state before import")
+	if err = c.fetchRemoteCollections(ctx); err != nil {
+		return nil, err
+	}
+	if err = c.fetchRemoteFiles(ctx); err != nil {
+		return nil, err
+	}
+	index, err := c.buildImportIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	folderNames := make([]string, 0, len(folderToMetaMap))
+	for folderName := range folderToMetaMap {
+		folderNames = append(folderNames, folderName)
+	}
+	sort.Strings(folderNames)
+	result := &model.ImportResult{}
+	for _, folderName := range folderNames {
+		albumMeta := folderToMetaMap[folderName]
+		if albumMeta == nil {
+			c.log(ctx).Warn("skipping folder without album metadata", "folder", folderName)
+			continue
+		}
+		if albumMeta.IsDeleted {
+			continue
+		}
+		err = c.importAlbum(ctx, params, source, index, albumMeta, result)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (c *ClICtrl) buildImportIndex(ctx context.Context) (*importIndex, error) {
+	userID := ctx.Value("user_id").(int64)
+	index := &importIndex{
+		albumsByName: make(map[string]*model.RemoteAlbum),
+		hashToFile:   make(map[string]*model.RemoteFile),
+		albumHashes:  make(map[int64]map[string]bool),
+	}
+	albums, err := c.getRemoteAlbums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range albums {
+		album := albums[i]
+		if album.IsDeleted || album.OwnerID != userID {
+			continue
+		}
+		if _, ok := index.albumsByName[album.AlbumName]; !ok {
+			index.albumsByName[album.AlbumName] = &album
+		}
+	}
+	files, err := c.getRemoteFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fileIDToFile := make(map[int64]*model.RemoteFile)
+	for i := range files {
+		fileIDToFile[files[i].ID] = &files[i]
+	}
+	entries, err := c.getRemoteAlbumEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDeleted {
+			continue
+		}
+		file, ok := fileIDToFile[entry.FileID]
+		if !ok {
+			continue
+		}
+		hash := file.GetFileHash()
+		if hash == nil {
+			continue
+		}
+		if file.OwnerID == userID {
+			index.addFile(entry.AlbumID, *hash, file)
+		} else {
+			index.addFile(entry.AlbumID, *hash, nil)
+		}
+	}
+	return index, nil
+}
+
+func (c *ClICtrl) importAlbum(
+	ctx context.Context,
+	params model.ImportParams,
+	source storage.Backend,
+	index *importIndex,
+	albumMeta *export.AlbumMetadata,
+	result *model.ImportResult,
+) error {
+	diskInfo, err := readFilesMetadata(ctx, source, albumMeta)
+	if err != nil {
+		c.log(ctx).Error("skipping album, failed to read files metadata", "album", albumMeta.AlbumName, "error", err)
+		return nil
+	}
+	album, ok := index.albumsByName[albumMeta.AlbumName]
+	if !ok {
+		if params.DryRun {
+			c.log(ctx).Info("create album", "album", albumMeta.AlbumName, "dryRun", true)
+			// use a negative placeholder ID to keep the albums apart in the index
+			album = &model.RemoteAlbum{ID: -int64(len(index.albumsByName) + 1), AlbumName: albumMeta.AlbumName}
+		} else {
+			c.log(ctx).Info("create album", "album", albumMeta.AlbumName)
+			album, err = c.createRemoteAlbum(ctx, albumMeta.AlbumName)
+			if err != nil {
+				return err
+			}
+		}
+		index.albumsByName[album.AlbumName] = album
+		result.AlbumsCreated++
+	}
+	metaFileNames := make([]string, 0, len(*diskInfo.MetaFileNameToDiskFileMap))
+	for metaFileName, diskFile := range *diskInfo.MetaFileNameToDiskFileMap {
+		if diskFile != nil {
+			metaFileNames = append(metaFileNames, metaFileName)
+		}
+	}
+	sort.Strings(metaFileNames)
+	for _, metaFileName := range metaFileNames {
+		diskFile := (*diskInfo.MetaFileNameToDiskFileMap)[metaFileName]
+		importErr := c.importFile(ctx, params, index, album, albumMeta, diskFile, result)
+		if importErr != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.log(ctx).Error("failed to import file", "file", diskFile.Title, "album", album.AlbumName, "error", importErr)
+			result.Failed++
+		}
+	}
+	return nil
+}
+
+func (c *ClICtrl) importFile(
+	ctx context.Context,
+	params model.ImportParams,
+	index *importIndex,
+	album *model.RemoteAlbum,
+	albumMeta *export.AlbumMetadata,
+	diskFile *export.DiskFileMetadata,
+	result *model.ImportResult,
+) error {
+	if len(diskFile.Info.FileNames) == 0 {
+		return fmt.Errorf("no files found for %s", diskFile.MetaFileName)
+	}
+	filePaths := make([]string, 0, len(diskFile.Info.FileNames))
+	for _, fileName := range diskFile.Info.FileNames {
+		filePath := filepath.Join(params.ExportDir, albumMeta.FolderName, fileName)
+		if _, err := os.Stat(filePath); err != nil {
+			return err
+		}
+		filePaths = append(filePaths, filePath)
+	}
+	hash, err := getImportFileHash(diskFile, filePaths)
+	if err != nil {
+		return err
+	}
+	if index.albumHashes[album.ID][hash] {
+		result.Skipped++
+		return nil
+	}
+	if existing, ok := index.hashToFile[hash]; ok {
+		if params.DryRun {
+			c.log(ctx).Info("add existing file to album", "file", diskFile.Title, "album", album.AlbumName, "dryRun", true)
+		} else {
+			c.log(ctx).Info("add existing file to album", "file", diskFile.Title, "album", album.AlbumName)
+			if err = c.addFileToAlbum(ctx, album, existing); err != nil {
+				return err
+			}
+		}
+		index.addFile(album.ID, hash, existing)
+		result.AddedToAlbum++
+		return nil
+	}
+	if params.DryRun {
+		c.log(ctx).Info("upload file", "file", diskFile.Title, "album", album.AlbumName, "dryRun", true)
+		index.addFile(album.ID, hash, nil)
+		result.Uploaded++
+		return nil
+	}
+	c.log(ctx).Info("upload file", "file", diskFile.Title, "album", album.AlbumName)
+	remoteFile, err := c.uploadFile(ctx, album, diskFile, filePaths, hash)
+	if err != nil {
+		return err
+	}
+	index.addFile(album.ID, hash, remoteFile)
+	result.Uploaded++
+	return nil
+}
+
+// getImportFileHash returns the hash recorded in the export metadata, or computes it if missing.
+// For live photos, the hash is the combination of the image and the video hash.
+func getImportFileHash(diskFile *export.DiskFileMetadata, filePaths []string) (string, error) {
+	if diskFile.Info.Hash != nil && *diskFile.Info.Hash != "" {
+		return *diskFile.Info.Hash, nil
+	}
+	if len(filePaths) == 1 {
+		return eCrypto.ComputeFileHash(filePaths[0])
+	}
+	imagePath, videoPath := splitLivePhotoParts(filePaths)
+	imageHash, err := eCrypto.ComputeFileHash(imagePath)
+	if err != nil {
+		return "", err
+	}
+	videoHash, err := eCrypto.ComputeFileHash(videoPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s", imageHash, videoHash), nil
+}
+
+func splitLivePhotoParts(filePaths []string) (imagePath, videoPath string) {
+	for _, filePath := range filePaths {
+		if isVideo(filePath) {
+			videoPath = filePath
+		} else {
+			imagePath = filePath
+		}
+	}
+	return
+}
+
+func isVideo(filePath string) bool {
+	return videoExtensions[strings.ToLower(filepath.Ext(filePath))]
+}
+
+func (c *ClICtrl) addFileToAlbum(ctx context.Context, album *model.RemoteAlbum, file *model.RemoteFile) error {
+	collectionKey := album.AlbumKey.MustDecrypt(c.KeyHolder.DeviceKey)
+	encKey, nonce, err := eCrypto.SecretBoxSeal(file.Key.MustDecrypt(c.KeyHolder.DeviceKey), collectionKey)
+	if err != nil {
+		return err
+	}
+	return c.Client.AddFilesToCollection(ctx, album.ID, []api.CollectionFileItem{{
+		ID:                 file.ID,
+		EncryptedKey:       encoding.EncodeBase64(encKey),
+		KeyDecryptionNonce: encoding.EncodeBase64(nonce),
+	}})
+}
+
+func (c *ClICtrl) uploadFile(
+	ctx context.Context,
+	album *model.RemoteAlbum,
+	diskFile *export.DiskFileMetadata,
+	filePaths []string,
+	hash string,
+) (*model.RemoteFile, error) {
+	metadata := map[string]interface{}{
+		"title":            diskFile.Title,
+		"creationTime":     diskFile.CreationTime.UnixMicro(),
+		"modificationTime": diskFile.ModificationTime.UnixMicro(),
+	}
+	if diskFile.Location != nil {
+		metadata["latitude"] = diskFile.Location.Latitude
+		metadata["longitude"] = diskFile.Location.Longitude
+	}
+	sourcePath := filePaths[0]
+	thumbnailSource := filePaths[0]
+	if len(filePaths) > 1 {
+		imagePath, videoPath := splitLivePhotoParts(filePaths)
+		zipPath, err := c.packLivePhoto(imagePath, videoPath)
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(zipPath)
+		sourcePath = zipPath
+		thumbnailSource = imagePath
+		metadata["fileType"] = model.LivePhoto
+		if hashes := strings.SplitN(hash, ":", 2); len(hashes) == 2 {
+			metadata["imageHash"] = hashes[0]
+			metadata["videoHash"] = hashes[1]
+		}
+	} else {
+		if isVideo(sourcePath) {
+			metadata["fileType"] = model.Video
+		} else {
+			metadata["fileType"] = model.Image
+		}
+		metadata["hash"] = hash
+	}
+	pubMetadata := make(map[string]interface{})
+	if diskFile.Description != nil && *diskFile.Description != "" {
+		pubMetadata["caption"] = *diskFile.Description
+	}
+	if diskFile.Location != nil {
+		pubMetadata["lat"] = diskFile.Location.Latitude
+		pubMetadata["long"] = diskFile.Location.Longitude
+	}
+	if !diskFile.CreationTime.IsZero() {
+		pubMetadata["editedTime"] = diskFile.CreationTime.UnixMicro()
+	}
+
+	fileKey := eCrypto.NewStreamKey()
+	encFilePath := filepath.Join(c.tempFolder, fmt.Sprintf("upload-%s", uuid.New().String()))
+	defer os.Remove(encFilePath)
+	fileHeader, err := eCrypto.EncryptFile(sourcePath, encFilePath, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	thumbnailData, err := thumbnail.FromFile(ctx, thumbnailSource, thumbnail.MaxDimension)
+	if errors.Is(err, thumbnail.ErrUnsupported) {
+		c.log(ctx).Warn("uploading the file with a placeholder thumbnail", "file", thumbnailSource, "error", err)
+		thumbnailData, err = thumbnail.Placeholder()
+	}
+	if err != nil {
+		return nil, err
+	}
+	encThumbnail, thumbnailHeader, err := eCrypto.EncryptChaCha20poly1305(thumbnailData, fileKey)
+	if err != nil {
+		return nil, err
+	}
+	encThumbnailPath := encFilePath + "-thumb"
+	defer os.Remove(encThumbnailPath)
+	if err = os.WriteFile(encThumbnailPath, encThumbnail, 0600); err != nil {
+		return nil, err
+	}
+	encMetadata, metadataHeader, err := eCrypto.EncryptChaCha20poly1305(encoding.MustMarshalJSON(metadata), fileKey)
+	if err != nil {
+		return nil, err
+	}
+	var pubMagicMetadata *api.MagicMetadata
+	if len(pubMetadata) > 0 {
+		encPubMetadata, pubMetadataHeader, err := eCrypto.EncryptChaCha20poly1305(encoding.MustMarshalJSON(pubMetadata), fileKey)
+		if err != nil {
+			return nil, err
+		}
+		pubMagicMetadata = &api.MagicMetadata{
+			Version: 1,
+			Count:   len(pubMetadata),
+			Data:    encoding.EncodeBase64(encPubMetadata),
+			Header:  encoding.EncodeBase64(pubMetadataHeader),
+		}
+	}
+	collectionKey := album.AlbumKey.MustDecrypt(c.KeyHolder.DeviceKey)
+	encKey, keyNonce, err := eCrypto.SecretBoxSeal(fileKey, collectionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadURLs, err := c.Client.GetUploadURLs(ctx, 2)
+	if err != nil {
+		return nil, err
+	}
+	if len(uploadURLs) < 2 {
+		return nil, fmt.Errorf("expected 2 upload urls, got %d", len(uploadURLs))
+	}
+	fileSize, err := c.Client.UploadObject(ctx, uploadURLs[0].URL, encFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+	thumbnailSize, err := c.Client.UploadObject(ctx, uploadURLs[1].URL, encThumbnailPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload thumbnail: %w", err)
+	}
+	file, err := c.Client.CreateFile(ctx, api.CreateFileRequest{
+		CollectionID:       album.ID,
+		EncryptedKey:       encoding.EncodeBase64(encKey),
+		KeyDecryptionNonce: encoding.EncodeBase64(keyNonce),
+		File: api.FileAttributes{
+			ObjectKey:        uploadURLs[0].ObjectKey,
+			DecryptionHeader: encoding.EncodeBase64(fileHeader),
+			Size:             fileSize,
+		},
+		Thumbnail: api.FileAttributes{
+			ObjectKey:        uploadURLs[1].ObjectKey,
+			DecryptionHeader: encoding.EncodeBase64(thumbnailHeader),
+			Size:             thumbnailSize,
+		},
+		Metadata: api.FileAttributes{
+			EncryptedData:    encoding.EncodeBase64(encMetadata),
+			DecryptionHeader: encoding.EncodeBase64(metadataHeader),
+		},
+		PubicMagicMetadata: pubMagicMetadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.RemoteFile{
+		ID:      file.ID,
+		OwnerID: file.OwnerID,
+		Key:     *model.MakeEncString(fileKey, c.KeyHolder.DeviceKey),
+	}, nil
+}
+
+// packLivePhoto creates a zip containing the image and the video part of a live photo,
+// in the same layout as expected by UnpackLive
+func (c *ClICtrl) packLivePhoto(imagePath, videoPath string) (string, error) {
+	if imagePath == "" || videoPath == "" {
+		return "", model.ErrLiveZip
+	}
+	zipPath := filepath.Join(c.tempFolder, fmt.Sprintf("live-%s.zip", uuid.New().String()))
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		return "", err
+	}
+	defer zipFile.Close()
+	writer := zip.NewWriter(zipFile)
+	for name, srcPath := range map[string]string{
+		"image" + filepath.Ext(imagePath): imagePath,
+		"video" + filepath.Ext(videoPath): videoPath,
+	} {
+		if err = addToZip(writer, name, srcPath); err != nil {
+			_ = os.Remove(zipPath)
+			return "", err
+		}
+	}
+	if err = writer.Close(); err != nil {
+		_ = os.Remove(zipPath)
+		return "", err
+	}
+	return zipPath, nil
+}
+
+func addToZip(writer *zip.Writer, name, srcPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := writer.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,25 @@
+package pkg
+
+import "context"
+
+type abortKey struct{}
+
+// WithAbort returns a copy of ctx which carries the abort context of the export. Cancelling ctx stops the
+// export once the file being synced is written, cancelling abort stops it right away and rolls the file back.
+func WithAbort(ctx context.Context, abort context.Context) context.Context {
+	return context.WithValue(ctx, abortKey{}, abort)
+}
+
+// fileContext returns the context for syncing a single file. It isn't cancelled with ctx, so that the file
+// is completed, but only when the abort context of ctx is cancelled.
+func fileContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	fileCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	if abort, ok := ctx.Value(abortKey{}).(context.Context); ok {
+		stop := context.AfterFunc(abort, cancel)
+		return fileCtx, func() {
+			stop()
+			cancel()
+		}
+	}
+	return fileCtx, cancel
+}
@@ -0,0 +1,36 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFileContext(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	abort, cancelAbort := context.WithCancel(context.Background())
+	ctx, cancel := fileContext(WithAbort(parent, abort))
+	defer cancel()
+
+	cancelParent()
+	if ctx.Err() != nil {
+		t.Fatalf("file context cancelled with the export context")
+	}
+	cancelAbort()
+	<-ctx.Done()
+	if ctx.Err() != context.Canceled {
+		t.Fatalf("expected the file context to be cancelled on abort, got %v", ctx.Err())
+	}
+}
+
+func TestFileContextWithoutAbort(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, cancel := fileContext(parent)
+	cancelParent()
+	if ctx.Err() != nil {
+		t.Fatalf("file context cancelled with the export context")
+	}
+	cancel()
+	if ctx.Err() == nil {
+		t.Fatalf("file context not cancelled by its cancel func")
+	}
+}
@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/pkg/library"
+	"github.com/ente-io/cli/pkg/model"
+)
+
+// OpenLibrary returns a read-only view of the albums and files of the photos account, built from the
+// local stores. If refresh is set, the stores are first updated from the server. The returned
+// library downloads files using ctx, which should stay alive as long as the library is in use.
+func (c *ClICtrl) OpenLibrary(ctx context.Context, ref model.AccountRef, refresh bool, opts library.Options) (*library.Library, error) {
+	account, err := c.getAccount(ctx, ref, api.AppPhotos)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = c.loadAccount(ctx, *account)
+	if err != nil {
+		return nil, err
+	}
+	if refresh {
+		c.log(ctx).Info("fetching albums and files")
+		if err = c.fetchRemoteCollections(ctx); err != nil {
+			return nil, fmt.Errorf("error fetching albums: %w", err)
+		}
+		if err = c.fetchRemoteFiles(ctx); err != nil {
+			return nil, fmt.Errorf("error fetching files: %w", err)
+		}
+	}
+	albums, err := c.getRemoteAlbums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files, err := c.getRemoteFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := c.getRemoteAlbumEntries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return library.New(ctx, albums, files, entries, c.KeyHolder.DeviceKey, c.Client, opts)
+}
@@ -0,0 +1,299 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/utils/encoding"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// doneSuffix marks the files in the cache which are completely downloaded. A marker is used instead of
+// renaming the file, as the file is already open for reading while it's being downloaded.
+const doneSuffix = ".done"
+
+// cache keeps the decrypted content of the files in CacheDir. The entries are named by file ID and stream header,
+// so that a file whose content was replaced is downloaded again.
+type cache struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	dir        string
+	maxSize    int64
+	downloader Downloader
+
+	mu        sync.Mutex
+	cond      *sync.Cond
+	downloads map[string]*download
+	readers   map[string]int
+}
+
+type download struct {
+	// available is the number of decrypted bytes written to the cache file so far
+	available int64
+	done      bool
+	err       error
+}
+
+func newCache(ctx context.Context, opts Options, downloader Downloader) (*cache, error) {
+	if opts.CacheDir == "" {
+		return nil, errors.New("cache dir is required")
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+	c := &cache{
+		dir:        opts.CacheDir,
+		maxSize:    opts.CacheSize,
+		downloader: downloader,
+		downloads:  make(map[string]*download),
+		readers:    make(map[string]int),
+	}
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	c.cond = sync.NewCond(&c.mu)
+	// remove the partial downloads of a previous run
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), doneSuffix) {
+			continue
+		}
+		if _, err = os.Stat(c.dataPath(entry.Name()) + doneSuffix); errors.Is(err, os.ErrNotExist) {
+			_ = os.Remove(c.dataPath(entry.Name()))
+		}
+	}
+	return c, nil
+}
+
+func (c *cache) dataPath(name string) string {
+	return filepath.Join(c.dir, name)
+}
+
+func cacheName(f *File) string {
+	header := encoding.DecodeBase64(f.remote.FileNonce)
+	if len(header) > 8 {
+		header = header[:8]
+	}
+	return fmt.Sprintf("%d-%x", f.ID, header)
+}
+
+func (c *cache) open(f *File, deviceKey []byte) (*Reader, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	name := cacheName(f)
+	dataPath := c.dataPath(name)
+	dl, downloading := c.downloads[name]
+	if !downloading {
+		if _, err := os.Stat(dataPath + doneSuffix); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			out, err := os.OpenFile(dataPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+			if err != nil {
+				return nil, err
+			}
+			dl = &download{}
+			c.downloads[name] = dl
+			go c.fetch(f, name, deviceKey, out, dl)
+		}
+	}
+	in, err := os.Open(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	_ = os.Chtimes(dataPath, now, now)
+	c.readers[name]++
+	return &Reader{c: c, file: f, name: name, in: in, dl: dl}, nil
+}
+
+// fetch downloads and decrypts the file into out, waking up the readers as the content becomes available
+func (c *cache) fetch(f *File, name string, deviceKey []byte, out *os.File, dl *download) {
+	err := c.decryptTo(f, deviceKey, out, dl)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.WriteFile(c.dataPath(name)+doneSuffix, nil, 0600)
+	}
+	c.mu.Lock()
+	delete(c.downloads, name)
+	if err != nil {
+		logging.FromContext(c.ctx, nil).Error("failed to download file", "fileID", f.ID, "error", err)
+		dl.err = err
+		_ = os.Remove(c.dataPath(name))
+	} else {
+		dl.done = true
+	}
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	if err == nil {
+		c.gc()
+	}
+}
+
+func (c *cache) decryptTo(f *File, deviceKey []byte, out io.Writer, dl *download) error {
+	key, err := fileKey(f, deviceKey)
+	if err != nil {
+		return err
+	}
+	stream, err := c.downloader.DownloadFileStream(c.ctx, f.ID)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	header := encoding.DecodeBase64(f.remote.FileNonce)
+	plain, err := crypto.NewStreamReader(io.MultiReader(bytes.NewReader(header), stream), key)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 256*1024)
+	for {
+		n, readErr := plain.Read(buf)
+		if n > 0 {
+			if _, err = out.Write(buf[:n]); err != nil {
+				return err
+			}
+			c.mu.Lock()
+			dl.available += int64(n)
+			c.cond.Broadcast()
+			c.mu.Unlock()
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		} else if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// gc removes the least recently used files once the cache grows beyond its maximum size.
+// Files which are being downloaded or read are kept.
+func (c *cache) gc() {
+	if c.maxSize <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		logging.FromContext(c.ctx, nil).Error("failed to read cache dir", "error", err)
+		return
+	}
+	type cached struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var total int64
+	var candidates []cached
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, doneSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		if _, downloading := c.downloads[name]; downloading || c.readers[name] > 0 {
+			continue
+		}
+		candidates = append(candidates, cached{name: name, size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].modTime.Before(candidates[j].modTime) })
+	for _, candidate := range candidates {
+		if total <= c.maxSize {
+			break
+		}
+		dataPath := c.dataPath(candidate.name)
+		_ = os.Remove(dataPath + doneSuffix)
+		if err = os.Remove(dataPath); err != nil {
+			logging.FromContext(c.ctx, nil).Warn("failed to remove file from cache", "path", dataPath, "error", err)
+			continue
+		}
+		total -= candidate.size
+	}
+}
+
+func (c *cache) close() {
+	c.cancel()
+	c.mu.Lock()
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
+
+// Reader reads the decrypted content of a file from the cache. It's safe for concurrent use.
+type Reader struct {
+	c    *cache
+	file *File
+	name string
+	in   *os.File
+	// dl is nil if the file was already in the cache when it was opened
+	dl *download
+}
+
+// wait blocks until at least end bytes are available, or the download has finished
+func (r *Reader) wait(end int64) error {
+	if r.dl == nil {
+		return nil
+	}
+	r.c.mu.Lock()
+	defer r.c.mu.Unlock()
+	for !r.dl.done && r.dl.err == nil && r.dl.available < end {
+		if err := r.c.ctx.Err(); err != nil {
+			return err
+		}
+		r.c.cond.Wait()
+	}
+	return r.dl.err
+}
+
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if err := r.wait(off + int64(len(p))); err != nil {
+		return 0, err
+	}
+	return r.in.ReadAt(p, off)
+}
+
+// Size returns the size of the decrypted content. If it isn't known in advance, it waits for the download to finish.
+func (r *Reader) Size() (int64, error) {
+	if r.file.Size >= 0 {
+		return r.file.Size, nil
+	}
+	if err := r.wait(math.MaxInt64); err != nil {
+		return 0, err
+	}
+	info, err := r.in.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (r *Reader) Close() error {
+	err := r.in.Close()
+	r.c.mu.Lock()
+	r.c.readers[r.name]--
+	if r.c.readers[r.name] <= 0 {
+		delete(r.c.readers, r.name)
+	}
+	r.c.mu.Unlock()
+	r.c.gc()
+	return err
+}
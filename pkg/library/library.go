@@ -0,0 +1,241 @@
+// Package library provides a read-only, decrypted view of an account's albums and files,
+// built from the local copy of the remote metadata. File content is downloaded lazily and
+// kept decrypted in a local cache.
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/utils/encoding"
+	"io"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Lookup when the path doesn't match any album or file
+var ErrNotFound = errors.New("not found")
+
+// Downloader returns the encrypted content and thumbnail of a file
+type Downloader interface {
+	DownloadFileStream(ctx context.Context, fileID int64) (io.ReadCloser, error)
+	DownloadThumbnail(ctx context.Context, fileID int64) ([]byte, error)
+}
+
+type Options struct {
+	// CacheDir stores the decrypted files. It should be private to the user.
+	CacheDir string
+	// CacheSize is the size in bytes above which the least recently used files are evicted
+	CacheSize int64
+}
+
+type Library struct {
+	albums      []*Album
+	albumByName map[string]*Album
+	deviceKey   []byte
+	cache       *cache
+}
+
+type Album struct {
+	ID        int64
+	Name      string
+	files     []*File
+	fileNames map[string]*File
+}
+
+type File struct {
+	ID   int64
+	Name string
+	// Size of the decrypted content, -1 if it's not known before downloading the file
+	Size         int64
+	CreationTime time.Time
+	remote       model.RemoteFile
+}
+
+// New builds the library from the local stores of an account. The context is used for all the downloads
+// and must carry the account's request values. Cancelling it stops the pending downloads.
+func New(
+	ctx context.Context,
+	albums []model.RemoteAlbum,
+	files []model.RemoteFile,
+	entries []*model.AlbumFileEntry,
+	deviceKey []byte,
+	downloader Downloader,
+	opts Options,
+) (*Library, error) {
+	c, err := newCache(ctx, opts, downloader)
+	if err != nil {
+		return nil, err
+	}
+	lib := &Library{
+		albumByName: make(map[string]*Album),
+		deviceKey:   deviceKey,
+		cache:       c,
+	}
+	sort.Slice(albums, func(i, j int) bool { return albums[i].ID < albums[j].ID })
+	albumByID := make(map[int64]*Album)
+	for _, a := range albums {
+		if a.IsDeleted {
+			continue
+		}
+		album := &Album{ID: a.ID, Name: uniqueName(sanitizeName(a.AlbumName), "", lib.albumByName), fileNames: make(map[string]*File)}
+		lib.albums = append(lib.albums, album)
+		lib.albumByName[strings.ToLower(album.Name)] = album
+		albumByID[a.ID] = album
+	}
+	fileByID := make(map[int64]model.RemoteFile, len(files))
+	for _, f := range files {
+		fileByID[f.ID] = f
+	}
+	for _, entry := range entries {
+		album, ok := albumByID[entry.AlbumID]
+		if !ok || entry.IsDeleted {
+			continue
+		}
+		if remote, ok := fileByID[entry.FileID]; ok {
+			album.files = append(album.files, newFile(remote))
+		}
+	}
+	for _, album := range lib.albums {
+		// assign the names in a stable order, so that duplicates keep their suffix across mounts
+		sort.Slice(album.files, func(i, j int) bool {
+			if !album.files[i].CreationTime.Equal(album.files[j].CreationTime) {
+				return album.files[i].CreationTime.Before(album.files[j].CreationTime)
+			}
+			return album.files[i].ID < album.files[j].ID
+		})
+		for _, file := range album.files {
+			title := sanitizeName(file.remote.GetTitle())
+			ext := path.Ext(title)
+			if file.remote.IsLivePhoto() {
+				// live photos are served as the zip of the image and the video
+				ext = ".zip"
+			}
+			file.Name = uniqueName(strings.TrimSuffix(title, path.Ext(title)), ext, album.fileNames)
+			album.fileNames[strings.ToLower(file.Name)] = file
+		}
+	}
+	return lib, nil
+}
+
+func newFile(remote model.RemoteFile) *File {
+	file := &File{ID: remote.ID, Size: -1, CreationTime: remote.GetCreationTime(), remote: remote}
+	if remote.Info.FileSize > 0 {
+		file.Size = crypto.DecryptedSize(remote.Info.FileSize)
+	}
+	return file
+}
+
+func sanitizeName(name string) string {
+	name = strings.ReplaceAll(name, "/", "_")
+	name = strings.ReplaceAll(name, ":", "_")
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "untitled"
+	}
+	return name
+}
+
+// uniqueName returns base+ext, or base_<n>+ext if the name is already taken. Names are compared case-insensitively.
+func uniqueName[T any](base, ext string, taken map[string]T) string {
+	name := base + ext
+	for i := 1; ; i++ {
+		if _, ok := taken[strings.ToLower(name)]; !ok {
+			return name
+		}
+		name = fmt.Sprintf("%s_%d%s", base, i, ext)
+	}
+}
+
+func (l *Library) Albums() []*Album {
+	return l.albums
+}
+
+// Album returns the album with the given name, ignoring case
+func (l *Library) Album(name string) (*Album, bool) {
+	album, ok := l.albumByName[strings.ToLower(name)]
+	return album, ok
+}
+
+func (a *Album) Files() []*File {
+	return a.files
+}
+
+// File returns the file with the given name, ignoring case
+func (a *Album) File(name string) (*File, bool) {
+	file, ok := a.fileNames[strings.ToLower(name)]
+	return file, ok
+}
+
+// Lookup resolves a slash separated path of the form "album" or "album/file". Both are nil for the root.
+func (l *Library) Lookup(p string) (*Album, *File, error) {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil, nil, nil
+	}
+	albumName, fileName, hasFile := strings.Cut(p, "/")
+	album, ok := l.Album(albumName)
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	if !hasFile {
+		return album, nil, nil
+	}
+	file, ok := album.File(fileName)
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	return album, file, nil
+}
+
+// Remote returns the remote metadata of the file
+func (f *File) Remote() model.RemoteFile {
+	return f.remote
+}
+
+// Open returns a reader for the decrypted content of the file. The content is downloaded in the background
+// and reads block until the requested range is available.
+func (l *Library) Open(f *File) (*Reader, error) {
+	return l.cache.open(f, l.deviceKey)
+}
+
+// Thumbnail downloads and decrypts the thumbnail of the file
+func (l *Library) Thumbnail(f *File) ([]byte, error) {
+	key, err := fileKey(f, l.deviceKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := l.cache.downloader.DownloadThumbnail(l.cache.ctx, f.ID)
+	if err != nil {
+		return nil, err
+	}
+	_, thumbnail, err := crypto.DecryptChaChaBase64(encoding.EncodeBase64(data), key, f.remote.ThumbnailNonce)
+	return thumbnail, err
+}
+
+// fileKey decrypts the key of the file, which is stored encrypted with the device key
+func fileKey(f *File, deviceKey []byte) (key []byte, err error) {
+	defer func() {
+		// don't let a corrupt key take down the process
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to decrypt key of file %d: %v", f.ID, r)
+		}
+	}()
+	return f.remote.Key.MustDecrypt(deviceKey), nil
+}
+
+// Close stops the pending downloads
+func (l *Library) Close() {
+	l.cache.close()
+}
+
+// log returns the logger of the context the library was built with
+func (l *Library) log() *slog.Logger {
+	return logging.FromContext(l.cache.ctx, nil)
+}
@@ -0,0 +1,191 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/utils/encoding"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// fakeDownloader serves the encrypted content of the files, the same way the download endpoint does
+type fakeDownloader struct {
+	mu         sync.Mutex
+	files      map[int64][]byte
+	thumbnails map[int64][]byte
+	downloads  int
+}
+
+func (d *fakeDownloader) DownloadFileStream(ctx context.Context, fileID int64) (io.ReadCloser, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.downloads++
+	return io.NopCloser(bytes.NewReader(d.files[fileID])), nil
+}
+
+func (d *fakeDownloader) DownloadThumbnail(ctx context.Context, fileID int64) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.thumbnails[fileID], nil
+}
+
+func (d *fakeDownloader) addFile(t *testing.T, deviceKey []byte, id int64, title string, content []byte) model.RemoteFile {
+	fileKey := crypto.NewStreamKey()
+	var buf bytes.Buffer
+	w, err := crypto.NewStreamWriter(&buf, fileKey)
+	if err != nil {
+		t.Fatalf("Failed to create stream writer: %v", err)
+	}
+	_, _ = w.Write(content)
+	if err = w.Close(); err != nil {
+		t.Fatalf("Failed to encrypt file: %v", err)
+	}
+	header := buf.Next(crypto.StreamHeaderBytes)
+	d.files[id] = buf.Bytes()
+	thumbnail, thumbnailHeader, err := crypto.EncryptChaCha20poly1305([]byte("thumbnail of "+title), fileKey)
+	if err != nil {
+		t.Fatalf("Failed to encrypt thumbnail: %v", err)
+	}
+	d.thumbnails[id] = thumbnail
+	return model.RemoteFile{
+		ID:             id,
+		Key:            *model.MakeEncString(fileKey, deviceKey),
+		FileNonce:      encoding.EncodeBase64(header),
+		ThumbnailNonce: encoding.EncodeBase64(thumbnailHeader),
+		Metadata:       map[string]interface{}{"title": title, "fileType": float64(0), "creationTime": float64(id)},
+		Info:           model.Info{FileSize: int64(len(d.files[id]))},
+	}
+}
+
+func newTestLibrary(t *testing.T, cacheDir string, cacheSize int64) (*Library, *fakeDownloader, map[int64][]byte) {
+	deviceKey := make([]byte, 32)
+	_, _ = rand.Read(deviceKey)
+	downloader := &fakeDownloader{files: make(map[int64][]byte), thumbnails: make(map[int64][]byte)}
+	contents := map[int64][]byte{
+		1: []byte("first"),
+		2: bytes.Repeat([]byte("0123456789"), 1024*1024),
+		3: []byte("third"),
+	}
+	files := []model.RemoteFile{
+		downloader.addFile(t, deviceKey, 1, "a.jpg", contents[1]),
+		downloader.addFile(t, deviceKey, 2, "a.jpg", contents[2]),
+		downloader.addFile(t, deviceKey, 3, "b/c.jpg", contents[3]),
+	}
+	albums := []model.RemoteAlbum{
+		{ID: 10, AlbumName: "Trip"},
+		{ID: 11, AlbumName: "trip"},
+		{ID: 12, AlbumName: "Deleted", IsDeleted: true},
+	}
+	entries := []*model.AlbumFileEntry{
+		{FileID: 1, AlbumID: 10},
+		{FileID: 2, AlbumID: 10},
+		{FileID: 3, AlbumID: 11},
+		{FileID: 1, AlbumID: 11, IsDeleted: true},
+		{FileID: 1, AlbumID: 12},
+	}
+	lib, err := New(context.Background(), albums, files, entries, deviceKey, downloader, Options{CacheDir: cacheDir, CacheSize: cacheSize})
+	if err != nil {
+		t.Fatalf("Failed to create library: %v", err)
+	}
+	t.Cleanup(lib.Close)
+	return lib, downloader, contents
+}
+
+func readAll(t *testing.T, lib *Library, f *File) []byte {
+	r, err := lib.Open(f)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", f.Name, err)
+	}
+	defer r.Close()
+	size, err := r.Size()
+	if err != nil {
+		t.Fatalf("Failed to get size of %s: %v", f.Name, err)
+	}
+	data := make([]byte, size)
+	if _, err = r.ReadAt(data, 0); err != nil && err != io.EOF {
+		t.Fatalf("Failed to read %s: %v", f.Name, err)
+	}
+	return data
+}
+
+func TestLibraryNames(t *testing.T) {
+	lib, _, _ := newTestLibrary(t, t.TempDir(), 0)
+	if len(lib.Albums()) != 2 {
+		t.Fatalf("Expected 2 albums, got %d", len(lib.Albums()))
+	}
+	for p, expectedID := range map[string]int64{"Trip/a.jpg": 1, "trip/A_1.JPG": 2, "trip_1/b_c.jpg": 3} {
+		_, f, err := lib.Lookup(p)
+		if err != nil || f.ID != expectedID {
+			t.Fatalf("Expected %s to be file %d, got %+v, err %v", p, expectedID, f, err)
+		}
+	}
+	if _, _, err := lib.Lookup("Deleted"); err != ErrNotFound {
+		t.Fatalf("Expected deleted album to be hidden, err %v", err)
+	}
+	if album, _ := lib.Album("trip_1"); len(album.Files()) != 1 {
+		t.Fatalf("Expected deleted entry to be hidden, got %d files", len(album.Files()))
+	}
+	_, f, _ := lib.Lookup("trip_1/b_c.jpg")
+	if thumbnail, err := lib.Thumbnail(f); err != nil || string(thumbnail) != "thumbnail of b/c.jpg" {
+		t.Fatalf("Unexpected thumbnail %q, err %v", thumbnail, err)
+	}
+}
+
+func TestLibraryRead(t *testing.T) {
+	cacheDir := t.TempDir()
+	lib, downloader, contents := newTestLibrary(t, cacheDir, 0)
+	for _, album := range lib.Albums() {
+		for _, f := range album.Files() {
+			if f.Size != int64(len(contents[f.ID])) {
+				t.Fatalf("Expected size %d for %s, got %d", len(contents[f.ID]), f.Name, f.Size)
+			}
+			if data := readAll(t, lib, f); !bytes.Equal(data, contents[f.ID]) {
+				t.Fatalf("Unexpected content for %s", f.Name)
+			}
+		}
+	}
+	// reading a range of a cached file doesn't download it again
+	_, f, _ := lib.Lookup("Trip/a_1.jpg")
+	r, err := lib.Open(f)
+	if err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	part := make([]byte, 10)
+	if n, err := r.ReadAt(part, 5*1024*1024+3); err != nil || n != 10 || string(part) != "3456789012" {
+		t.Fatalf("Unexpected range read %q, n %d, err %v", part, n, err)
+	}
+	_ = r.Close()
+	if downloader.downloads != 3 {
+		t.Fatalf("Expected 3 downloads, got %d", downloader.downloads)
+	}
+	// complete downloads are kept across libraries
+	lib.Close()
+	if _, err = newCache(context.Background(), Options{CacheDir: cacheDir}, downloader); err != nil {
+		t.Fatalf("Failed to open cache: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(cacheDir, cacheName(f)+doneSuffix)); err != nil {
+		t.Fatalf("Expected cached file to be kept: %v", err)
+	}
+}
+
+func TestLibraryCacheEviction(t *testing.T) {
+	cacheDir := t.TempDir()
+	lib, _, _ := newTestLibrary(t, cacheDir, 1024)
+	_, small, _ := lib.Lookup("Trip/a.jpg")
+	_, large, _ := lib.Lookup("Trip/a_1.jpg")
+	readAll(t, lib, large)
+	readAll(t, lib, small)
+	// the large file exceeds the cache size and is evicted once it's closed
+	if _, err := os.Stat(filepath.Join(cacheDir, cacheName(large))); !os.IsNotExist(err) {
+		t.Fatalf("Expected large file to be evicted, err %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, cacheName(small))); err != nil {
+		t.Fatalf("Expected small file to be kept, err %v", err)
+	}
+}
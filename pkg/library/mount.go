@@ -0,0 +1,136 @@
+//go:build linux || darwin
+
+package library
+
+import (
+	"context"
+	"errors"
+	"github.com/ente-io/cli/internal/logging"
+	"io"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// Mount serves the library as a read-only filesystem at mountpoint, with a directory per album.
+// It blocks until the context is cancelled or the filesystem is unmounted externally.
+func Mount(ctx context.Context, lib *Library, mountpoint string) error {
+	server, err := fs.Mount(mountpoint, &rootNode{lib: lib}, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			FsName:  "ente",
+			Name:    "ente",
+			Options: []string{"ro"},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	unmounted := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			if err := server.Unmount(); err != nil {
+				lib.log().Error("failed to unmount", "mountpoint", mountpoint, "error", err)
+			}
+		case <-unmounted:
+		}
+	}()
+	server.Wait()
+	close(unmounted)
+	return nil
+}
+
+type rootNode struct {
+	fs.Inode
+	lib *Library
+}
+
+var _ = (fs.NodeOnAdder)((*rootNode)(nil))
+var _ = (fs.NodeGetattrer)((*rootNode)(nil))
+
+// OnAdd builds the whole tree upfront, as the library is a fixed snapshot
+func (r *rootNode) OnAdd(ctx context.Context) {
+	for _, album := range r.lib.Albums() {
+		dir := r.NewPersistentInode(ctx, &albumNode{}, fs.StableAttr{Mode: syscall.S_IFDIR})
+		r.AddChild(album.Name, dir, false)
+		for _, file := range album.Files() {
+			node := dir.NewPersistentInode(ctx, &fileNode{lib: r.lib, file: file}, fs.StableAttr{Mode: syscall.S_IFREG})
+			dir.AddChild(file.Name, node, false)
+		}
+	}
+}
+
+func (r *rootNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	return 0
+}
+
+type albumNode struct {
+	fs.Inode
+}
+
+var _ = (fs.NodeGetattrer)((*albumNode)(nil))
+
+func (a *albumNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0555
+	return 0
+}
+
+type fileNode struct {
+	fs.Inode
+	lib  *Library
+	file *File
+}
+
+var _ = (fs.NodeGetattrer)((*fileNode)(nil))
+var _ = (fs.NodeOpener)((*fileNode)(nil))
+
+func (n *fileNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = 0444
+	if n.file.Size > 0 {
+		out.Size = uint64(n.file.Size)
+	}
+	out.SetTimes(nil, &n.file.CreationTime, &n.file.CreationTime)
+	return 0
+}
+
+func (n *fileNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	reader, err := n.lib.Open(n.file)
+	if err != nil {
+		n.lib.log().Error("failed to open file", "file", n.file.Name, "error", err)
+		return nil, 0, syscall.EIO
+	}
+	fuseFlags := uint32(fuse.FOPEN_KEEP_CACHE)
+	if n.file.Size < 0 {
+		// the reported size is a placeholder, let reads go past it
+		fuseFlags = fuse.FOPEN_DIRECT_IO
+	}
+	return &fileHandle{reader: reader}, fuseFlags, 0
+}
+
+type fileHandle struct {
+	reader *Reader
+}
+
+var _ = (fs.FileReader)((*fileHandle)(nil))
+var _ = (fs.FileReleaser)((*fileHandle)(nil))
+
+func (h *fileHandle) Read(ctx context.Context, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	n, err := h.reader.ReadAt(dest, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		logging.FromContext(h.reader.c.ctx, nil).Error("failed to read file", "file", h.reader.file.Name, "error", err)
+		return nil, syscall.EIO
+	}
+	return fuse.ReadResultData(dest[:n]), 0
+}
+
+func (h *fileHandle) Release(ctx context.Context) syscall.Errno {
+	if err := h.reader.Close(); err != nil {
+		return syscall.EIO
+	}
+	return 0
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package library
+
+import (
+	"context"
+	"errors"
+)
+
+// Mount isn't supported on this platform, as it needs FUSE
+func Mount(ctx context.Context, lib *Library, mountpoint string) error {
+	return errors.New("mount is only supported on linux and macOS")
+}
@@ -0,0 +1,252 @@
+package library
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// NewWebDAVHandler serves the library read-only over WebDAV, with a collection per album.
+// GET requests support ranges. As files are decrypted sequentially, a range is served once the download
+// of the file has reached it.
+func NewWebDAVHandler(lib *Library) http.Handler {
+	handler := &webdav.Handler{
+		FileSystem: &webdavFS{lib: lib},
+		LockSystem: webdav.NewMemLS(),
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut, http.MethodDelete, "MKCOL", "COPY", "MOVE", "PROPPATCH":
+			http.Error(w, "read-only", http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// BasicAuth requires the given credentials for all the requests. It's a no-op if user is empty.
+func BasicAuth(handler http.Handler, user, password string) http.Handler {
+	if user == "" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(u), []byte(user)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ente", charset="UTF-8"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type webdavFS struct {
+	lib *Library
+}
+
+func (w *webdavFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	return os.ErrPermission
+}
+
+func (w *webdavFS) RemoveAll(ctx context.Context, name string) error {
+	return os.ErrPermission
+}
+
+func (w *webdavFS) Rename(ctx context.Context, oldName, newName string) error {
+	return os.ErrPermission
+}
+
+func (w *webdavFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+	}
+	album, file, err := w.lib.Lookup(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if file != nil {
+		return &webdavFile{lib: w.lib, file: file}, nil
+	}
+	var entries []fs.FileInfo
+	if album == nil {
+		for _, a := range w.lib.Albums() {
+			entries = append(entries, albumInfo{a})
+		}
+		return &webdavDir{info: rootInfo{}, entries: entries}, nil
+	}
+	for _, f := range album.Files() {
+		entries = append(entries, fileInfo{f})
+	}
+	return &webdavDir{info: albumInfo{album}, entries: entries}, nil
+}
+
+func (w *webdavFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	album, file, err := w.lib.Lookup(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	if file != nil {
+		return fileInfo{file}, nil
+	} else if album != nil {
+		return albumInfo{album}, nil
+	}
+	return rootInfo{}, nil
+}
+
+type rootInfo struct{}
+
+func (rootInfo) Name() string       { return "/" }
+func (rootInfo) Size() int64        { return 0 }
+func (rootInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (rootInfo) ModTime() time.Time { return time.Time{} }
+func (rootInfo) IsDir() bool        { return true }
+func (rootInfo) Sys() interface{}   { return nil }
+
+type albumInfo struct {
+	album *Album
+}
+
+func (a albumInfo) Name() string       { return a.album.Name }
+func (a albumInfo) Size() int64        { return 0 }
+func (a albumInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (a albumInfo) ModTime() time.Time { return time.Time{} }
+func (a albumInfo) IsDir() bool        { return true }
+func (a albumInfo) Sys() interface{}   { return nil }
+
+type fileInfo struct {
+	file *File
+}
+
+func (f fileInfo) Name() string { return f.file.Name }
+
+// Size is 0 if the size isn't known yet, so that listing an album doesn't download its files
+func (f fileInfo) Size() int64 {
+	if f.file.Size < 0 {
+		return 0
+	}
+	return f.file.Size
+}
+func (f fileInfo) Mode() fs.FileMode  { return 0444 }
+func (f fileInfo) ModTime() time.Time { return f.file.CreationTime }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() interface{}   { return nil }
+
+type webdavDir struct {
+	info    fs.FileInfo
+	entries []fs.FileInfo
+	pos     int
+}
+
+func (d *webdavDir) Close() error                   { return nil }
+func (d *webdavDir) Read([]byte) (int, error)       { return 0, errors.New("is a directory") }
+func (d *webdavDir) Write([]byte) (int, error)      { return 0, os.ErrPermission }
+func (d *webdavDir) Seek(int64, int) (int64, error) { return 0, errors.New("is a directory") }
+func (d *webdavDir) Stat() (fs.FileInfo, error)     { return d.info, nil }
+
+func (d *webdavDir) Readdir(count int) ([]fs.FileInfo, error) {
+	remaining := d.entries[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.pos += count
+	return remaining[:count], nil
+}
+
+// webdavFile opens the file in the library on the first read, as the WebDAV handler also opens files
+// just to look up their properties
+type webdavFile struct {
+	lib    *Library
+	file   *File
+	reader *Reader
+	offset int64
+}
+
+var _ webdav.ContentTyper = (*webdavFile)(nil)
+
+// ContentType avoids sniffing the content, which would download the file
+func (f *webdavFile) ContentType(ctx context.Context) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(f.file.Name)); contentType != "" {
+		return contentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+func (f *webdavFile) open() error {
+	if f.reader != nil {
+		return nil
+	}
+	reader, err := f.lib.Open(f.file)
+	if err != nil {
+		return err
+	}
+	f.reader = reader
+	return nil
+}
+
+func (f *webdavFile) Read(p []byte) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	n, err := f.reader.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *webdavFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		size := f.file.Size
+		if size < 0 {
+			if err := f.open(); err != nil {
+				return 0, err
+			}
+			var err error
+			if size, err = f.reader.Size(); err != nil {
+				return 0, err
+			}
+		}
+		offset += size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *webdavFile) Readdir(int) ([]fs.FileInfo, error) { return nil, errors.New("not a directory") }
+func (f *webdavFile) Write([]byte) (int, error)          { return 0, os.ErrPermission }
+func (f *webdavFile) Stat() (fs.FileInfo, error)         { return fileInfo{f.file}, nil }
+
+func (f *webdavFile) Close() error {
+	if f.reader == nil {
+		return nil
+	}
+	return f.reader.Close()
+}
@@ -0,0 +1,54 @@
+package library
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebDAVHandler(t *testing.T) {
+	lib, _, contents := newTestLibrary(t, t.TempDir(), 0)
+	server := httptest.NewServer(BasicAuth(NewWebDAVHandler(lib), "user", "secret"))
+	defer server.Close()
+
+	do := func(method, path string, header map[string]string, auth bool) (*http.Response, string) {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		if auth {
+			req.SetBasicAuth("user", "secret")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	if resp, _ := do(http.MethodGet, "/Trip/a.jpg", nil, false); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 without credentials, got %d", resp.StatusCode)
+	}
+	resp, body := do("PROPFIND", "/Trip/", map[string]string{"Depth": "1"}, true)
+	if resp.StatusCode != http.StatusMultiStatus || !strings.Contains(body, "a_1.jpg") {
+		t.Fatalf("Unexpected PROPFIND response %d: %s", resp.StatusCode, body)
+	}
+	resp, body = do(http.MethodGet, "/trip_1/b_c.jpg", nil, true)
+	if resp.StatusCode != http.StatusOK || body != string(contents[3]) {
+		t.Fatalf("Unexpected GET response %d: %q", resp.StatusCode, body)
+	}
+	resp, body = do(http.MethodGet, "/Trip/a_1.jpg", map[string]string{"Range": "bytes=13-16"}, true)
+	if resp.StatusCode != http.StatusPartialContent || body != "3456" {
+		t.Fatalf("Unexpected range response %d: %q", resp.StatusCode, body)
+	}
+	if resp, _ = do(http.MethodPut, "/Trip/new.jpg", nil, true); resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("Expected PUT to be rejected, got %d", resp.StatusCode)
+	}
+	if resp, _ = do(http.MethodGet, "/Missing/a.jpg", nil, true); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected 404 for a missing file, got %d", resp.StatusCode)
+	}
+}
@@ -4,23 +4,26 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/ente-io/cli/internal/api"
 	eCrypto "github.com/ente-io/cli/internal/crypto"
 	"github.com/ente-io/cli/pkg/model"
 	"github.com/ente-io/cli/pkg/model/export"
 	"github.com/ente-io/cli/pkg/secrets"
 	"github.com/ente-io/cli/utils/encoding"
-	"log"
 )
 
 func MapCollectionToAlbum(ctx context.Context, collection api.Collection, holder *secrets.KeyHolder) (*model.RemoteAlbum, error) {
 	var album model.RemoteAlbum
 	userID := ctx.Value("user_id").(int64)
 	album.OwnerID = collection.Owner.ID
+	album.OwnerEmail = collection.Owner.Email
 	album.ID = collection.ID
 	album.IsShared = collection.Owner.ID != userID
 	album.LastUpdatedAt = collection.UpdationTime
 	album.IsDeleted = collection.IsDeleted
+	album.Sharees = collection.Sharees
+	album.PublicURLs = collection.PublicURLs
 	collectionKey, err := holder.GetCollectionKey(ctx, collection)
 	if err != nil {
 		return nil, err
@@ -30,7 +33,7 @@ func MapCollectionToAlbum(ctx context.Context, collection api.Collection, holder
 	if collection.EncryptedName != "" {
 		decrName, err := eCrypto.SecretBoxOpenBase64(collection.EncryptedName, collection.NameDecryptionNonce, collectionKey)
 		if err != nil {
-			log.Fatalf("failed to decrypt collection name: %v", err)
+			return nil, fmt.Errorf("failed to decrypt collection name: %w", err)
 		}
 		name = string(decrName)
 	} else {
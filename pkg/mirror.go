@@ -0,0 +1,262 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/model/export"
+	"github.com/ente-io/cli/pkg/storage"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Layout of a raw encrypted mirror:
+//
+//	mirror.json                          export.MirrorMetadata
+//	key_attributes.json                  key attributes of the user
+//	collections/<id>/collection.json     collection record
+//	collections/<id>/files/<id>.json     file records of the collection
+//	blobs/<id>                           encrypted file
+const (
+	mirrorVersion      = 1
+	mirrorMetaFile     = "mirror.json"
+	keyAttributesFile  = "key_attributes.json"
+	collectionsFolder  = "collections"
+	collectionMetaFile = "collection.json"
+	filesFolder        = "files"
+	blobsFolder        = "blobs"
+)
+
+// MirrorAccount keeps a bit-for-bit copy of the account's encrypted data in the mirror directory.
+// Blobs are stored as served by the file download endpoint, and records as returned by the API.
+func (c *ClICtrl) MirrorAccount(ctx context.Context, params model.MirrorParams) (*model.MirrorResult, error) {
+	account, err := c.getAccount(ctx, model.AccountRef{Email: params.Email, API: params.API}, params.App)
+	if err != nil {
+		return nil, err
+	}
+	if account.App != api.AppPhotos {
+		return nil, fmt.Errorf("mirror is only supported for photos accounts")
+	}
+	if _, err = internal.ValidateDirForWrite(params.MirrorDir); err != nil {
+		return nil, fmt.Errorf("error: %v while validating %s", err, params.MirrorDir)
+	}
+	ctx, err = c.loadAccount(ctx, *account)
+	if err != nil {
+		return nil, err
+	}
+	mirror := storage.NewLocal(params.MirrorDir)
+	meta, err := readMirrorMetadata(ctx, mirror, *account)
+	if err != nil {
+		return nil, err
+	}
+	keyAttributes, err := c.Client.GetRawKeyAttributes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key attributes: %w", err)
+	}
+	if err = mirror.WriteSidecar(ctx, keyAttributesFile, keyAttributes); err != nil {
+		return nil, err
+	}
+	if err = mirror.MkdirAll(ctx, blobsFolder); err != nil {
+		return nil, err
+	}
+	result := &model.MirrorResult{}
+	if err = c.mirrorCollections(ctx, mirror, meta, result); err != nil {
+		return result, err
+	}
+	err = pruneMirrorBlobs(ctx, mirror, result)
+	return result, err
+}
+
+func readMirrorMetadata(ctx context.Context, mirror storage.Backend, account model.Account) (*export.MirrorMetadata, error) {
+	var meta export.MirrorMetadata
+	err := mirror.ReadSidecar(ctx, mirrorMetaFile, &meta)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &export.MirrorMetadata{
+			Version:    mirrorVersion,
+			UserID:     account.UserID,
+			Email:      account.Email,
+			App:        account.App,
+			FilesSince: make(map[int64]int64),
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if meta.UserID != account.UserID {
+		return nil, fmt.Errorf("mirror belongs to %s, not %s", meta.Email, account.Email)
+	}
+	if meta.FilesSince == nil {
+		meta.FilesSince = make(map[int64]int64)
+	}
+	return &meta, nil
+}
+
+func collectionPath(collectionID int64) string {
+	return path.Join(collectionsFolder, strconv.FormatInt(collectionID, 10))
+}
+
+func (c *ClICtrl) mirrorCollections(ctx context.Context, mirror storage.Backend, meta *export.MirrorMetadata, result *model.MirrorResult) error {
+	rawCollections, err := c.Client.GetRawCollections(ctx, meta.CollectionsSince)
+	if err != nil {
+		return fmt.Errorf("failed to get collections: %w", err)
+	}
+	for _, raw := range rawCollections {
+		var collection api.Collection
+		if err = json.Unmarshal(raw, &collection); err != nil {
+			return err
+		}
+		dir := collectionPath(collection.ID)
+		if collection.IsDeleted {
+			if err = mirror.RemoveAll(ctx, dir); err != nil {
+				return err
+			}
+			delete(meta.FilesSince, collection.ID)
+		} else {
+			if err = mirror.MkdirAll(ctx, path.Join(dir, filesFolder)); err != nil {
+				return err
+			}
+			if err = mirror.WriteSidecar(ctx, path.Join(dir, collectionMetaFile), raw); err != nil {
+				return err
+			}
+			result.Collections++
+		}
+		if collection.UpdationTime > meta.CollectionsSince {
+			meta.CollectionsSince = collection.UpdationTime
+		}
+	}
+	if err = mirror.WriteSidecar(ctx, mirrorMetaFile, meta); err != nil {
+		return err
+	}
+	entries, err := mirror.List(ctx, collectionsFolder)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir {
+			continue
+		}
+		var collection api.Collection
+		if err = mirror.ReadSidecar(ctx, path.Join(collectionsFolder, entry.Name, collectionMetaFile), &collection); err != nil {
+			return err
+		}
+		if err = c.mirrorFiles(ctx, mirror, meta, collection, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ClICtrl) mirrorFiles(ctx context.Context, mirror storage.Backend, meta *export.MirrorMetadata, collection api.Collection, result *model.MirrorResult) error {
+	filesDir := path.Join(collectionPath(collection.ID), filesFolder)
+	lastSyncTime := meta.FilesSince[collection.ID]
+	for lastSyncTime != collection.UpdationTime {
+		files, hasMore, err := c.Client.GetRawFiles(ctx, collection.ID, lastSyncTime)
+		if err != nil {
+			return err
+		}
+		maxUpdated := lastSyncTime
+		for _, raw := range files {
+			var file api.File
+			if err = json.Unmarshal(raw, &file); err != nil {
+				return err
+			}
+			if file.UpdationTime > maxUpdated {
+				maxUpdated = file.UpdationTime
+			}
+			recordPath := path.Join(filesDir, fmt.Sprintf("%d.json", file.ID))
+			if file.IsDeleted {
+				if err = mirror.Remove(ctx, recordPath); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = c.mirrorBlob(ctx, mirror, recordPath, file, result); err != nil {
+				return err
+			}
+			if err = mirror.WriteSidecar(ctx, recordPath, raw); err != nil {
+				return err
+			}
+			result.FileRecords++
+		}
+		if !hasMore {
+			maxUpdated = collection.UpdationTime
+		}
+		lastSyncTime = maxUpdated
+		meta.FilesSince[collection.ID] = lastSyncTime
+		if err = mirror.WriteSidecar(ctx, mirrorMetaFile, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mirrorBlob downloads the encrypted file unless the mirror already has the same version of it
+func (c *ClICtrl) mirrorBlob(ctx context.Context, mirror storage.Backend, recordPath string, file api.File, result *model.MirrorResult) error {
+	blobPath := path.Join(blobsFolder, strconv.FormatInt(file.ID, 10))
+	if stat, err := mirror.Stat(ctx, blobPath); err == nil {
+		var previous api.File
+		readErr := mirror.ReadSidecar(ctx, recordPath, &previous)
+		isSameSize := file.Info == nil || file.Info.FileSize == stat.Size
+		isSameVersion := readErr != nil || previous.File.DecryptionHeader == file.File.DecryptionHeader
+		if isSameSize && isSameVersion {
+			return nil
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	downloadPath := filepath.Join(c.tempFolder, fmt.Sprintf("mirror-%d", file.ID))
+	c.log(ctx).Info("downloading encrypted file", "fileID", file.ID)
+	if err := c.Client.DownloadFile(ctx, file.ID, downloadPath); err != nil {
+		return fmt.Errorf("error downloading file %d: %w", file.ID, err)
+	}
+	if err := mirror.PutFile(ctx, downloadPath, blobPath); err != nil {
+		return err
+	}
+	result.BlobsDownloaded++
+	return nil
+}
+
+// pruneMirrorBlobs removes the blobs which are no longer referenced by any file record
+func pruneMirrorBlobs(ctx context.Context, mirror storage.Backend, result *model.MirrorResult) error {
+	referenced := make(map[string]bool)
+	collections, err := mirror.List(ctx, collectionsFolder)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, collection := range collections {
+		if !collection.IsDir {
+			continue
+		}
+		records, err := mirror.List(ctx, path.Join(collectionsFolder, collection.Name, filesFolder))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		for _, record := range records {
+			referenced[strings.TrimSuffix(record.Name, ".json")] = true
+		}
+	}
+	blobs, err := mirror.List(ctx, blobsFolder)
+	if err != nil {
+		return err
+	}
+	for _, blob := range blobs {
+		if blob.IsDir || referenced[blob.Name] {
+			continue
+		}
+		logging.FromContext(ctx, nil).Info("removing encrypted file", "blob", blob.Name)
+		if err = mirror.Remove(ctx, path.Join(blobsFolder, blob.Name)); err != nil {
+			return err
+		}
+		result.BlobsRemoved++
+	}
+	return nil
+}
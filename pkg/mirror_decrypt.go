@@ -0,0 +1,193 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal"
+	"github.com/ente-io/cli/internal/api"
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/pkg/mapper"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/model/export"
+	"github.com/ente-io/cli/pkg/secrets"
+	"github.com/ente-io/cli/pkg/storage"
+	"github.com/ente-io/cli/utils/encoding"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DecryptMirror decrypts a raw mirror created by MirrorAccount into a regular export in destDir,
+// using only the account password. It doesn't need the local database or any access to the server.
+// Files already present in destDir are skipped, so an interrupted run can be resumed.
+func DecryptMirror(ctx context.Context, mirrorDir, destDir, password string) (*model.DecryptMirrorResult, error) {
+	if _, err := internal.ValidateDirForWrite(destDir); err != nil {
+		return nil, fmt.Errorf("error: %v while validating %s", err, destDir)
+	}
+	mirror := storage.NewLocal(mirrorDir)
+	var meta export.MirrorMetadata
+	if err := mirror.ReadSidecar(ctx, mirrorMetaFile, &meta); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%s is not a mirror", mirrorDir)
+		}
+		return nil, err
+	}
+	if meta.Version != mirrorVersion {
+		return nil, fmt.Errorf("unsupported mirror version %d", meta.Version)
+	}
+	var keyAttributes api.KeyAttributes
+	if err := mirror.ReadSidecar(ctx, keyAttributesFile, &keyAttributes); err != nil {
+		return nil, err
+	}
+	account := model.Account{Email: meta.Email, UserID: meta.UserID, App: meta.App}
+	keyHolder, err := unlockMirrorKeys(account, keyAttributes, password)
+	if err != nil {
+		return nil, err
+	}
+	ctx = context.WithValue(ctx, "app", string(account.App))
+	ctx = context.WithValue(ctx, "account_key", account.AccountKey())
+	ctx = context.WithValue(ctx, "user_id", account.UserID)
+
+	tempDir, err := os.MkdirTemp("", "ente-mirror")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	dest := storage.NewLocal(destDir)
+	folderToMetaMap, albumIDToMetaMap, err := readFolderMetadata(ctx, dest)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.DecryptMirrorResult{}
+	collections, err := mirror.List(ctx, collectionsFolder)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range collections {
+		if !entry.IsDir {
+			continue
+		}
+		var collection api.Collection
+		if err = mirror.ReadSidecar(ctx, path.Join(collectionsFolder, entry.Name, collectionMetaFile), &collection); err != nil {
+			return result, err
+		}
+		album, err := mapper.MapCollectionToAlbum(ctx, collection, keyHolder)
+		if err != nil {
+			return result, fmt.Errorf("failed to decrypt collection %d: %w", collection.ID, err)
+		}
+		albumMeta, ok := albumIDToMetaMap[album.ID]
+		if !ok {
+			albumMeta = &export.AlbumMetadata{
+				ID:              album.ID,
+				OwnerID:         album.OwnerID,
+				AlbumName:       album.AlbumName,
+				AccountOwnerIDs: []int64{account.UserID},
+				FolderName:      uniqueAlbumFolderName(album.AlbumName, folderToMetaMap),
+			}
+			logging.FromContext(ctx, nil).Info("adding album folder", "album", album.AlbumName, "folder", albumMeta.FolderName)
+			if err = dest.MkdirAll(ctx, path.Join(albumMeta.FolderName, albumMetaFolder)); err != nil {
+				return result, err
+			}
+			if err = dest.WriteSidecar(ctx, path.Join(albumMeta.FolderName, albumMetaFolder, albumMetaFile), albumMeta); err != nil {
+				return result, err
+			}
+			folderToMetaMap[albumMeta.FolderName] = albumMeta
+			albumIDToMetaMap[album.ID] = albumMeta
+		}
+		result.Albums++
+		if err = decryptMirrorAlbum(ctx, mirror, dest, keyHolder, *album, albumMeta, tempDir, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// unlockMirrorKeys derives the master and secret keys of the account from the password and returns
+// an in-memory key holder for them.
+func unlockMirrorKeys(account model.Account, keyAttributes api.KeyAttributes, password string) (*secrets.KeyHolder, error) {
+	keyEncKey, err := eCrypto.DeriveArgonKey(password, keyAttributes.KEKSalt, keyAttributes.MemLimit, keyAttributes.OpsLimit)
+	if err != nil {
+		return nil, err
+	}
+	masterKey, err := eCrypto.SecretBoxOpen(
+		encoding.DecodeBase64(keyAttributes.EncryptedKey),
+		encoding.DecodeBase64(keyAttributes.KeyDecryptionNonce),
+		keyEncKey)
+	if err != nil {
+		return nil, errors.New("incorrect password")
+	}
+	secretKey, err := eCrypto.SecretBoxOpen(
+		encoding.DecodeBase64(keyAttributes.EncryptedSecretKey),
+		encoding.DecodeBase64(keyAttributes.SecretKeyDecryptionNonce),
+		masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting secret key: %w", err)
+	}
+	// the device key only protects the keys kept in memory while decrypting
+	keyHolder := secrets.NewKeyHolder(eCrypto.NewStreamKey())
+	keyHolder.AccountSecrets[account.AccountKey()] = &model.AccSecretInfo{
+		MasterKey: masterKey,
+		SecretKey: secretKey,
+		PublicKey: encoding.DecodeBase64(keyAttributes.PublicKey),
+	}
+	return keyHolder, nil
+}
+
+func decryptMirrorAlbum(
+	ctx context.Context,
+	mirror *storage.Local,
+	dest storage.Backend,
+	keyHolder *secrets.KeyHolder,
+	album model.RemoteAlbum,
+	albumMeta *export.AlbumMetadata,
+	tempDir string,
+	result *model.DecryptMirrorResult,
+) error {
+	diskInfo, err := readFilesMetadata(ctx, dest, albumMeta)
+	if err != nil {
+		return err
+	}
+	filesDir := path.Join(collectionPath(album.ID), filesFolder)
+	records, err := mirror.List(ctx, filesDir)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record.IsDir || !strings.HasSuffix(record.Name, ".json") {
+			continue
+		}
+		var file api.File
+		if err = mirror.ReadSidecar(ctx, path.Join(filesDir, record.Name), &file); err != nil {
+			return err
+		}
+		remoteFile, err := mapper.MapApiFileToPhotoFile(ctx, album, file, keyHolder)
+		if err != nil {
+			logging.FromContext(ctx, nil).Error("failed to decrypt file metadata", "fileID", file.ID, "error", err)
+			result.Failed++
+			continue
+		}
+		if diskInfo.IsFilePresent(*remoteFile) {
+			continue
+		}
+		logging.FromContext(ctx, nil).Info("decrypting file", "file", remoteFile.GetTitle(), "album", album.AlbumName)
+		blobPath := mirror.Path(path.Join(blobsFolder, strings.TrimSuffix(record.Name, ".json")))
+		decryptedPath := filepath.Join(tempDir, fmt.Sprintf("%d.decrypted", file.ID))
+		err = eCrypto.DecryptFile(blobPath, decryptedPath, remoteFile.Key.MustDecrypt(keyHolder.DeviceKey), encoding.DecodeBase64(remoteFile.FileNonce))
+		if err == nil {
+			err = writeDecryptedFile(ctx, diskInfo, *remoteFile, decryptedPath)
+		}
+		if err != nil {
+			logging.FromContext(ctx, nil).Error("failed to decrypt file", "fileID", file.ID, "error", err)
+			_ = os.Remove(decryptedPath)
+			result.Failed++
+			continue
+		}
+		result.Files++
+	}
+	return nil
+}
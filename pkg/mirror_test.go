@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"github.com/ente-io/cli/internal/api"
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+	"github.com/ente-io/cli/pkg/model/export"
+	"github.com/ente-io/cli/pkg/storage"
+	"github.com/ente-io/cli/utils/encoding"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+func secretBoxSeal(t *testing.T, m, key []byte) (string, string) {
+	cipher, nonce, err := eCrypto.SecretBoxSeal(m, key)
+	if err != nil {
+		t.Fatalf("Failed to seal: %v", err)
+	}
+	return encoding.EncodeBase64(cipher), encoding.EncodeBase64(nonce)
+}
+
+// writeTestMirror creates a mirror with one album containing one file, encrypted the same way as the ente apps do
+func writeTestMirror(t *testing.T, dir, password string, content []byte) {
+	ctx := context.Background()
+	mirror := storage.NewLocal(dir)
+	masterKey := eCrypto.NewStreamKey()
+	salt := make([]byte, 16)
+	_, _ = rand.Read(salt)
+	keyAttributes := api.KeyAttributes{KEKSalt: encoding.EncodeBase64(salt), MemLimit: 8 * 1024, OpsLimit: 1}
+	keyEncKey, err := eCrypto.DeriveArgonKey(password, keyAttributes.KEKSalt, keyAttributes.MemLimit, keyAttributes.OpsLimit)
+	if err != nil {
+		t.Fatalf("Failed to derive key: %v", err)
+	}
+	keyAttributes.EncryptedKey, keyAttributes.KeyDecryptionNonce = secretBoxSeal(t, masterKey, keyEncKey)
+	publicKey, secretKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	keyAttributes.PublicKey = encoding.EncodeBase64(publicKey[:])
+	keyAttributes.EncryptedSecretKey, keyAttributes.SecretKeyDecryptionNonce = secretBoxSeal(t, secretKey[:], masterKey)
+
+	collectionKey := eCrypto.NewStreamKey()
+	collection := api.Collection{ID: 1, Owner: api.CollectionUser{ID: 7}, UpdationTime: 100}
+	collection.EncryptedKey, collection.KeyDecryptionNonce = secretBoxSeal(t, collectionKey, masterKey)
+	collection.EncryptedName, collection.NameDecryptionNonce = secretBoxSeal(t, []byte("Trip"), collectionKey)
+
+	fileKey := eCrypto.NewStreamKey()
+	file := api.File{ID: 10, OwnerID: 7, CollectionID: 1, UpdationTime: 100}
+	file.EncryptedKey, file.KeyDecryptionNonce = secretBoxSeal(t, fileKey, collectionKey)
+	metadata, _ := json.Marshal(map[string]interface{}{
+		"title": "a.jpg", "fileType": 0, "creationTime": 1, "modificationTime": 1,
+	})
+	encMetadata, metadataHeader, err := eCrypto.EncryptChaCha20poly1305(metadata, fileKey)
+	if err != nil {
+		t.Fatalf("Failed to encrypt metadata: %v", err)
+	}
+	file.Metadata = api.FileAttributes{EncryptedData: encoding.EncodeBase64(encMetadata), DecryptionHeader: encoding.EncodeBase64(metadataHeader)}
+
+	plainPath := filepath.Join(t.TempDir(), "plain")
+	if err = os.WriteFile(plainPath, content, 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err = mirror.MkdirAll(ctx, blobsFolder); err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+	fileHeader, err := eCrypto.EncryptFile(plainPath, mirror.Path(path.Join(blobsFolder, "10")), fileKey)
+	if err != nil {
+		t.Fatalf("Failed to encrypt file: %v", err)
+	}
+	file.File = api.FileAttributes{DecryptionHeader: encoding.EncodeBase64(fileHeader)}
+
+	filesDir := path.Join(collectionPath(1), filesFolder)
+	if err = mirror.MkdirAll(ctx, filesDir); err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+	sidecars := map[string]interface{}{
+		mirrorMetaFile:    export.MirrorMetadata{Version: mirrorVersion, UserID: 7, Email: "a@b.c", App: api.AppPhotos},
+		keyAttributesFile: keyAttributes,
+		path.Join(collectionPath(1), collectionMetaFile): collection,
+		path.Join(filesDir, "10.json"):                   file,
+	}
+	for p, data := range sidecars {
+		if err = mirror.WriteSidecar(ctx, p, data); err != nil {
+			t.Fatalf("Failed to write %s: %v", p, err)
+		}
+	}
+}
+
+func TestDecryptMirror(t *testing.T) {
+	mirrorDir, destDir := t.TempDir(), t.TempDir()
+	writeTestMirror(t, mirrorDir, "password", []byte("image content"))
+
+	if _, err := DecryptMirror(context.Background(), mirrorDir, destDir, "wrong"); err == nil {
+		t.Fatalf("Expected an error for the wrong password")
+	}
+	result, err := DecryptMirror(context.Background(), mirrorDir, destDir, "password")
+	if err != nil {
+		t.Fatalf("Failed to decrypt mirror: %v", err)
+	}
+	if result.Albums != 1 || result.Files != 1 || result.Failed != 0 {
+		t.Fatalf("Unexpected result %+v", result)
+	}
+	content, err := os.ReadFile(filepath.Join(destDir, "Trip", "a.jpg"))
+	if err != nil || string(content) != "image content" {
+		t.Fatalf("Unexpected content %q, err %v", content, err)
+	}
+	// a second run resumes from the existing export
+	result, err = DecryptMirror(context.Background(), mirrorDir, destDir, "password")
+	if err != nil || result.Files != 0 {
+		t.Fatalf("Expected no files to be decrypted again, got %+v, err %v", result, err)
+	}
+}
@@ -3,6 +3,8 @@ package model
 import (
 	"fmt"
 	"github.com/ente-io/cli/internal/api"
+	"net/url"
+	"strings"
 )
 
 type Account struct {
@@ -15,20 +17,126 @@ type Account struct {
 	PublicKey string    `json:"publicKey" binding:"required"`
 	Token     EncString `json:"token" binding:"required"`
 	ExportDir string    `json:"exportDir"`
+	// APIEndpoint and FilesEndpoint are set for accounts of self-hosted servers, they are empty for ente.io
+	APIEndpoint   string `json:"apiEndpoint,omitempty"`
+	FilesEndpoint string `json:"filesEndpoint,omitempty"`
+	// S3 is set when the account is exported to an S3 compatible bucket instead of ExportDir
+	S3 *S3Config `json:"s3,omitempty"`
+	// ExportKey is set when the export is encrypted at rest. It's derived from the export passphrase.
+	ExportKey *EncString `json:"exportKey,omitempty"`
+	// Bandwidth overrides the --max-bandwidth of the export for the account
+	Bandwidth *BandwidthConfig `json:"bandwidth,omitempty"`
+}
+
+type BandwidthConfig struct {
+	// MaxBytesPerSec limits the download speed, 0 doesn't limit it
+	MaxBytesPerSec int64 `json:"maxBytesPerSec" yaml:"maxBytesPerSec"`
+	// FullSpeedWindows are the daily time ranges, like 00:00-06:00, during which the speed isn't limited
+	FullSpeedWindows []string `json:"fullSpeedWindows,omitempty" yaml:"fullSpeedWindows,omitempty"`
+}
+
+// AccountRef selects one of the configured accounts of an app
+type AccountRef struct {
+	// Email can be empty when a single account is configured
+	Email string
+	// API selects the server of the account, by its API endpoint or host, when the same email is used on several servers
+	API string
+}
+
+// AccountSettings are the settings of an account in the config file, they apply on top of the ones stored with it
+type AccountSettings struct {
+	Email string
+	App   api.App
+	// API restricts the settings to the account of that server, by its API endpoint or host
+	API string
+	// Skip leaves the account out of the exports
+	Skip bool
+	// Bandwidth replaces the bandwidth settings of the account when it's set
+	Bandwidth *BandwidthConfig
+}
+
+// Matches reports whether the settings are the ones of the account
+func (s AccountSettings) Matches(account Account) bool {
+	return strings.EqualFold(s.Email, account.Email) && s.App == account.App && account.IsOnServer(s.API)
 }
 
 type UpdateAccountParams struct {
-	Email     string
-	App       api.App
+	Email string
+	App   api.App
+	// API selects the server of the account, see AccountRef
+	API       string
 	ExportDir *string
+	S3        *S3Params
+	// DisableS3 switches the export target back to the local export directory
+	DisableS3 bool
+	// ExportPassphrase enables encryption of the export, or unlocks it after changing its destination
+	ExportPassphrase *string
+	// DisableEncryption exports plain files again, this requires a new export destination
+	DisableEncryption bool
+	// MaxBandwidth sets the download speed limit of the account in bytes per second, 0 doesn't limit it
+	MaxBandwidth *int64
+	// FullSpeedWindows replaces the time windows during which the speed of the account isn't limited
+	FullSpeedWindows []string
+	// ResetBandwidth removes the bandwidth settings of the account, so that the ones of the export apply
+	ResetBandwidth bool
 }
 
+// S3Params contains the plain text S3 settings provided while updating an account
+type S3Params struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Insecure  bool
+}
+
+// AccountKey identifies the account in the cli db. The key of an account of a self-hosted server includes
+// its host, as the user ids of different servers can be the same.
 func (a *Account) AccountKey() string {
+	if host := a.serverHost(); host != "" {
+		return fmt.Sprintf("%s-%s-%d", a.App, host, a.UserID)
+	}
 	return fmt.Sprintf("%s-%d", a.App, a.UserID)
 }
 
 func (a *Account) DataBucket() string {
-	return fmt.Sprintf("%s-%d-data", a.App, a.UserID)
+	return a.AccountKey() + "-data"
+}
+
+// Endpoints returns the endpoints of the server of the account
+func (a *Account) Endpoints() api.Endpoints {
+	return api.Endpoints{API: a.APIEndpoint, Files: a.FilesEndpoint}
+}
+
+// IsOnServer reports whether the account belongs to the server with the given API endpoint or host.
+// Every account matches an empty server.
+func (a *Account) IsOnServer(server string) bool {
+	if server == "" {
+		return true
+	}
+	endpoint := a.APIEndpoint
+	if endpoint == "" {
+		endpoint = api.EnteAPIEndpoint
+	}
+	server = strings.TrimSuffix(server, "/")
+	if strings.EqualFold(server, endpoint) {
+		return true
+	}
+	u, err := url.Parse(endpoint)
+	return err == nil && strings.EqualFold(server, u.Host)
+}
+
+// serverHost returns the host of the server of the account, or an empty string for ente.io
+func (a *Account) serverHost() string {
+	if a.Endpoints().IsDefault() {
+		return ""
+	}
+	if u, err := url.Parse(a.APIEndpoint); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return a.APIEndpoint
 }
 
 type AccSecretInfo struct {
@@ -37,3 +145,38 @@ type AccSecretInfo struct {
 	Token     []byte
 	PublicKey []byte
 }
+
+// AccountSummary is the stable, secret free view of an account printed by `account list`
+type AccountSummary struct {
+	Email     string  `json:"email" yaml:"email"`
+	UserID    int64   `json:"userID" yaml:"userID"`
+	App       api.App `json:"app" yaml:"app"`
+	ExportDir string  `json:"exportDir,omitempty" yaml:"exportDir,omitempty"`
+	// Server is the API endpoint of a self-hosted server, empty for ente.io
+	Server    string           `json:"server,omitempty" yaml:"server,omitempty"`
+	S3        *S3Summary       `json:"s3,omitempty" yaml:"s3,omitempty"`
+	Encrypted bool             `json:"encrypted" yaml:"encrypted"`
+	Bandwidth *BandwidthConfig `json:"bandwidth,omitempty" yaml:"bandwidth,omitempty"`
+}
+
+type S3Summary struct {
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	Bucket   string `json:"bucket" yaml:"bucket"`
+	Prefix   string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+}
+
+func (a *Account) Summary() AccountSummary {
+	summary := AccountSummary{
+		Email:     a.Email,
+		UserID:    a.UserID,
+		App:       a.App,
+		ExportDir: a.ExportDir,
+		Server:    a.APIEndpoint,
+		Encrypted: a.ExportKey != nil,
+		Bandwidth: a.Bandwidth,
+	}
+	if a.S3 != nil {
+		summary.S3 = &S3Summary{Endpoint: a.S3.Endpoint, Bucket: a.S3.Bucket, Prefix: a.S3.Prefix}
+	}
+	return summary
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"github.com/ente-io/cli/internal/api"
+	"testing"
+)
+
+func TestAccountKey(t *testing.T) {
+	enteAccount := Account{App: api.AppPhotos, UserID: 42}
+	if key := enteAccount.AccountKey(); key != "photos-42" {
+		t.Errorf("unexpected key %s of an ente.io account", key)
+	}
+	selfHosted := Account{App: api.AppPhotos, UserID: 42, APIEndpoint: "http://localhost:8080"}
+	if key := selfHosted.AccountKey(); key != "photos-localhost:8080-42" {
+		t.Errorf("unexpected key %s of a self-hosted account", key)
+	}
+	if bucket := selfHosted.DataBucket(); bucket != "photos-localhost:8080-42-data" {
+		t.Errorf("unexpected data bucket %s", bucket)
+	}
+}
+
+func TestAccountSettingsMatches(t *testing.T) {
+	settings := AccountSettings{Email: "Me@Example.org", App: api.AppPhotos}
+	if !settings.Matches(Account{Email: "me@example.org", App: api.AppPhotos}) {
+		t.Error("expected the settings to match the account regardless of the case of the email")
+	}
+	if settings.Matches(Account{Email: "me@example.org", App: api.AppAuth}) {
+		t.Error("expected the settings of the photos account not to match the auth one")
+	}
+	settings.API = "museum.example.org"
+	if !settings.Matches(Account{Email: "me@example.org", App: api.AppPhotos, APIEndpoint: "https://museum.example.org"}) {
+		t.Error("expected the settings to match the account of their server")
+	}
+	if settings.Matches(Account{Email: "me@example.org", App: api.AppPhotos}) {
+		t.Error("expected the settings of a self-hosted server not to match the ente.io account")
+	}
+}
+
+func TestAccountIsOnServer(t *testing.T) {
+	enteAccount := Account{}
+	selfHosted := Account{APIEndpoint: "http://localhost:8080"}
+	tests := []struct {
+		account *Account
+		server  string
+		want    bool
+	}{
+		{&enteAccount, "", true},
+		{&enteAccount, "https://api.ente.io/", true},
+		{&enteAccount, "api.ente.io", true},
+		{&enteAccount, "localhost:8080", false},
+		{&selfHosted, "", true},
+		{&selfHosted, "http://localhost:8080", true},
+		{&selfHosted, "localhost:8080", true},
+		{&selfHosted, "api.ente.io", false},
+	}
+	for _, test := range tests {
+		if got := test.account.IsOnServer(test.server); got != test.want {
+			t.Errorf("IsOnServer(%q) of %q = %t, expected %t", test.server, test.account.APIEndpoint, got, test.want)
+		}
+	}
+}
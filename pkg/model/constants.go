@@ -7,6 +7,8 @@ const (
 	RemoteAlbums       PhotosStore = "remoteAlbums"
 	RemoteFiles        PhotosStore = "remoteFiles"
 	RemoteAlbumEntries PhotosStore = "remoteAlbumEntries"
+	// ExportIndex keeps track of the objects written to a remote export target
+	ExportIndex PhotosStore = "exportIndex"
 )
 
 const (
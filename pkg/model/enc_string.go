@@ -1,9 +1,9 @@
 package model
 
 import (
+	"fmt"
 	"github.com/ente-io/cli/internal/crypto"
 	"github.com/ente-io/cli/utils/encoding"
-	"log"
 )
 
 type EncString struct {
@@ -14,7 +14,7 @@ type EncString struct {
 func MakeEncString(plainTextBytes []byte, key []byte) *EncString {
 	cipher, nonce, err := crypto.EncryptChaCha20poly1305(plainTextBytes, key)
 	if err != nil {
-		log.Fatalf("failed to encrypt %s", err)
+		panic(fmt.Errorf("failed to encrypt: %w", err))
 	}
 	return &EncString{
 		CipherText: encoding.EncodeBase64(cipher),
@@ -23,9 +23,25 @@ func MakeEncString(plainTextBytes []byte, key []byte) *EncString {
 }
 
 func (e *EncString) MustDecrypt(key []byte) []byte {
-	_, plainBytes, err := crypto.DecryptChaChaBase64(e.CipherText, key, e.Nonce)
+	plainBytes, err := e.Decrypt(key)
 	if err != nil {
 		panic(err)
 	}
 	return plainBytes
 }
+
+// Decrypt returns the plain text, or an error when the key doesn't match
+func (e *EncString) Decrypt(key []byte) ([]byte, error) {
+	_, plainBytes, err := crypto.DecryptChaChaBase64(e.CipherText, key, e.Nonce)
+	return plainBytes, err
+}
+
+// Rotate re-encrypts the value from the old key to the new one
+func (e *EncString) Rotate(oldKey, newKey []byte) error {
+	plainBytes, err := e.Decrypt(oldKey)
+	if err != nil {
+		return err
+	}
+	*e = *MakeEncString(plainBytes, newKey)
+	return nil
+}
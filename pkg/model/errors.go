@@ -2,13 +2,14 @@ package model
 
 import (
 	"errors"
-	"strings"
+	"github.com/ente-io/cli/internal/api"
 )
 
 var ErrDecryption = errors.New("error while decrypting the file")
 var ErrLiveZip = errors.New("error: no image or video file found in zip")
 
+// ShouldRetrySync reports whether the sync of an account which failed with err should be started again,
+// for network and server errors which outlasted the retries of the single requests.
 func ShouldRetrySync(err error) bool {
-	return strings.Contains(err.Error(), "read tcp") ||
-		strings.Contains(err.Error(), "dial tcp")
+	return api.IsRetryable(err)
 }
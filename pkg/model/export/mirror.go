@@ -0,0 +1,16 @@
+package export
+
+import "github.com/ente-io/cli/internal/api"
+
+// MirrorMetadata is stored at the root of a raw encrypted mirror. Together with the
+// key attributes, it's everything required to decrypt the mirror offline.
+type MirrorMetadata struct {
+	Version int     `json:"version"`
+	UserID  int64   `json:"userID"`
+	Email   string  `json:"email"`
+	App     api.App `json:"app"`
+	// CollectionsSince is the updation time of the latest collection in the mirror
+	CollectionsSince int64 `json:"collectionsSince"`
+	// FilesSince contains the updation time of the latest file record for each collection
+	FilesSince map[int64]int64 `json:"filesSince"`
+}
@@ -0,0 +1,76 @@
+package model
+
+import "github.com/ente-io/cli/internal/api"
+
+// Status of an account in ExportResult
+const (
+	ExportStatusSynced  = "synced"
+	ExportStatusSkipped = "skipped"
+	ExportStatusFailed  = "failed"
+	// the export was interrupted while it synced the account
+	ExportStatusInterrupted = "interrupted"
+)
+
+// DecryptExportResult is the outcome of `decrypt-export`
+type DecryptExportResult struct {
+	Files int `json:"files" yaml:"files"`
+}
+
+// ExportResult is the outcome of an `export` run
+type ExportResult struct {
+	Accounts []*AccountExportResult `json:"accounts" yaml:"accounts"`
+}
+
+type AccountExportResult struct {
+	Email  string  `json:"email" yaml:"email"`
+	App    api.App `json:"app" yaml:"app"`
+	Status string  `json:"status" yaml:"status"`
+	// Reason explains why the account was skipped or failed
+	Reason       string               `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Albums       []*AlbumExportResult `json:"albums" yaml:"albums"`
+	ExportCounts `yaml:",inline"`
+}
+
+type AlbumExportResult struct {
+	ID           int64  `json:"id" yaml:"id"`
+	Name         string `json:"name" yaml:"name"`
+	ExportCounts `yaml:",inline"`
+}
+
+type ExportCounts struct {
+	// Downloaded is the number of Created and Updated files
+	Downloaded int `json:"downloaded" yaml:"downloaded"`
+	Created    int `json:"created" yaml:"created"`
+	// Updated counts the files which were exported before and replaced by a newer version
+	Updated int `json:"updated" yaml:"updated"`
+	Removed int `json:"removed" yaml:"removed"`
+	Failed  int `json:"failed" yaml:"failed"`
+}
+
+func (c *ExportCounts) Add(other ExportCounts) {
+	c.Downloaded += other.Downloaded
+	c.Created += other.Created
+	c.Updated += other.Updated
+	c.Removed += other.Removed
+	c.Failed += other.Failed
+}
+
+// Album returns the result for the album, adding it if required
+func (r *AccountExportResult) Album(id int64, name string) *AlbumExportResult {
+	for _, album := range r.Albums {
+		if album.ID == id {
+			return album
+		}
+	}
+	album := &AlbumExportResult{ID: id, Name: name}
+	r.Albums = append(r.Albums, album)
+	return album
+}
+
+// UpdateTotals sets the counts of the account to the sum of its albums
+func (r *AccountExportResult) UpdateTotals() {
+	r.ExportCounts = ExportCounts{}
+	for _, album := range r.Albums {
+		r.ExportCounts.Add(album.ExportCounts)
+	}
+}
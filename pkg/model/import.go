@@ -0,0 +1,23 @@
+package model
+
+import "github.com/ente-io/cli/internal/api"
+
+type ImportParams struct {
+	Email string
+	App   api.App
+	// API selects the server of the account, see AccountRef
+	API       string
+	ExportDir string
+	// DryRun only reports what would be uploaded without making any changes
+	DryRun bool
+}
+
+type ImportResult struct {
+	AlbumsCreated int `json:"albumsCreated" yaml:"albumsCreated"`
+	Uploaded      int `json:"uploaded" yaml:"uploaded"`
+	// AddedToAlbum is the count of files which were already present remotely
+	// and were only added to the target album
+	AddedToAlbum int `json:"addedToAlbum" yaml:"addedToAlbum"`
+	Skipped      int `json:"skipped" yaml:"skipped"`
+	Failed       int `json:"failed" yaml:"failed"`
+}
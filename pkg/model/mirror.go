@@ -0,0 +1,24 @@
+package model
+
+import "github.com/ente-io/cli/internal/api"
+
+type MirrorParams struct {
+	Email string
+	App   api.App
+	// API selects the server of the account, see AccountRef
+	API       string
+	MirrorDir string
+}
+
+type MirrorResult struct {
+	Collections     int `json:"collections" yaml:"collections"`
+	FileRecords     int `json:"fileRecords" yaml:"fileRecords"`
+	BlobsDownloaded int `json:"blobsDownloaded" yaml:"blobsDownloaded"`
+	BlobsRemoved    int `json:"blobsRemoved" yaml:"blobsRemoved"`
+}
+
+type DecryptMirrorResult struct {
+	Albums int `json:"albums" yaml:"albums"`
+	Files  int `json:"files" yaml:"files"`
+	Failed int `json:"failed" yaml:"failed"`
+}
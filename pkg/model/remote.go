@@ -2,6 +2,7 @@ package model
 
 import (
 	"fmt"
+	"github.com/ente-io/cli/internal/api"
 	"github.com/ente-io/cli/pkg/model/export"
 	"sort"
 	"time"
@@ -37,6 +38,7 @@ type Info struct {
 type RemoteAlbum struct {
 	ID            int64                  `json:"id"`
 	OwnerID       int64                  `json:"ownerID"`
+	OwnerEmail    string                 `json:"ownerEmail,omitempty"`
 	IsShared      bool                   `json:"isShared"`
 	IsDeleted     bool                   `json:"isDeleted"`
 	AlbumName     string                 `json:"albumName"`
@@ -45,6 +47,28 @@ type RemoteAlbum struct {
 	PrivateMeta   map[string]interface{} `json:"privateMeta"`
 	SharedMeta    map[string]interface{} `json:"sharedMeta"`
 	LastUpdatedAt int64                  `json:"lastUpdatedAt"`
+	Sharees       []api.CollectionUser   `json:"sharees,omitempty"`
+	PublicURLs    []api.PublicURL        `json:"publicURLs,omitempty"`
+}
+
+// AlbumSummary is the view of an album printed by the album commands
+type AlbumSummary struct {
+	ID      int64  `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	OwnerID int64  `json:"ownerID" yaml:"ownerID"`
+	// Owner is "you" for the albums of the account, the email of the owner otherwise
+	Owner     string        `json:"owner" yaml:"owner"`
+	Shared    bool          `json:"shared" yaml:"shared"`
+	Files     int           `json:"files" yaml:"files"`
+	UpdatedAt time.Time     `json:"updatedAt" yaml:"updatedAt"`
+	Sharees   []AlbumSharee `json:"sharees,omitempty" yaml:"sharees,omitempty"`
+	Links     []PublicLink  `json:"links,omitempty" yaml:"links,omitempty"`
+}
+
+// HasShares reports whether the album is shared with other users or through a public link.
+// Unlike IsShared, which is set for the albums owned by someone else, it's about the albums of the account.
+func (a *RemoteAlbum) HasShares() bool {
+	return len(a.Sharees) > 0 || len(a.PublicURLs) > 0
 }
 
 type AlbumFileEntry struct {
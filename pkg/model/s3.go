@@ -0,0 +1,14 @@
+package model
+
+// S3Config contains the settings for exporting an account to an S3 compatible bucket
+type S3Config struct {
+	// Endpoint is the host (and optional port) of the S3 API, e.g. s3.amazonaws.com or localhost:9000
+	Endpoint  string    `json:"endpoint"`
+	Region    string    `json:"region,omitempty"`
+	Bucket    string    `json:"bucket"`
+	Prefix    string    `json:"prefix,omitempty"`
+	AccessKey string    `json:"accessKey"`
+	SecretKey EncString `json:"secretKey"`
+	// Insecure disables TLS, useful while testing against a local MinIO server
+	Insecure bool `json:"insecure,omitempty"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// PublicLinkParams contains the options for creating or updating a public link of an album.
+// Options which are nil are left unchanged while updating a link.
+type PublicLinkParams struct {
+	// ValidTill is the expiry time of the link in microseconds, 0 disables the expiry
+	ValidTill *int64
+	// DeviceLimit is the max number of devices that can access the link, 0 disables the limit
+	DeviceLimit    *int
+	EnableDownload *bool
+	Password       *string
+	RemovePassword bool
+}
+
+// PublicLink is the view of a public link printed by the album link commands
+type PublicLink struct {
+	// URL contains the album key as its fragment, which is never sent to the server
+	URL string `json:"url" yaml:"url"`
+	// ExpiresAt is nil for the links which don't expire
+	ExpiresAt       *time.Time `json:"expiresAt,omitempty" yaml:"expiresAt,omitempty"`
+	DeviceLimit     int        `json:"deviceLimit,omitempty" yaml:"deviceLimit,omitempty"`
+	EnableDownload  bool       `json:"enableDownload" yaml:"enableDownload"`
+	PasswordEnabled bool       `json:"passwordEnabled" yaml:"passwordEnabled"`
+}
+
+type AlbumSharee struct {
+	Email string `json:"email" yaml:"email"`
+	Role  string `json:"role" yaml:"role"`
+}
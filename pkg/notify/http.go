@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var httpClient = &http.Client{}
+
+// SetTransport sets the transport of the notifications sent over http, e.g. to go through the proxy of the http settings
+func SetTransport(transport http.RoundTripper) {
+	httpClient = &http.Client{Transport: transport}
+}
+
+// Ntfy publishes the message to a ntfy topic
+type Ntfy struct {
+	URL   string
+	Token string
+}
+
+func newNtfy(u *url.URL, transport string) (*Ntfy, error) {
+	topic := strings.Trim(u.Path, "/")
+	if topic == "" {
+		return nil, fmt.Errorf("ntfy notification url needs a topic")
+	}
+	return &Ntfy{URL: transport + "://" + u.Host + "/" + topic, Token: u.Query().Get("token")}, nil
+}
+
+func (n *Ntfy) Notify(ctx context.Context, msg Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", msg.Title)
+	if msg.Failed {
+		req.Header.Set("Priority", "high")
+		req.Header.Set("Tags", "warning")
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return send(req)
+}
+
+// Gotify pushes the message to a Gotify server, with the token of an application
+type Gotify struct {
+	URL   string
+	Token string
+}
+
+func newGotify(u *url.URL, transport string) (*Gotify, error) {
+	token := u.Query().Get("token")
+	if token == "" {
+		return nil, fmt.Errorf("gotify notification url needs the token parameter")
+	}
+	return &Gotify{URL: transport + "://" + u.Host + strings.TrimSuffix(u.Path, "/") + "/message", Token: token}, nil
+}
+
+func (g *Gotify) Notify(ctx context.Context, msg Message) error {
+	priority := 5
+	if msg.Failed {
+		priority = 8
+	}
+	return postJSON(ctx, g.URL, map[string]any{"title": msg.Title, "message": msg.Body, "priority": priority},
+		map[string]string{"X-Gotify-Key": g.Token})
+}
+
+// Webhook posts the message, including the full result of the export, as JSON
+type Webhook struct {
+	URL string
+}
+
+func (w *Webhook) Notify(ctx context.Context, msg Message) error {
+	return postJSON(ctx, w.URL, msg, nil)
+}
+
+func postJSON(ctx context.Context, url string, body any, headers map[string]string) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return send(req)
+}
+
+func send(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response %s from %s", resp.Status, req.URL.Redacted())
+	}
+	return nil
+}
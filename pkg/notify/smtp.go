@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SMTP sends the message by email. Plain connections are upgraded with STARTTLS when the server supports it,
+// ImplicitTLS connects with TLS right away as expected on port 465.
+type SMTP struct {
+	Addr        string
+	Username    string
+	Password    string
+	From        string
+	To          []string
+	ImplicitTLS bool
+}
+
+func newSMTP(u *url.URL) (*SMTP, error) {
+	s := &SMTP{Addr: u.Host, ImplicitTLS: u.Scheme == "smtps", From: u.Query().Get("from")}
+	if u.Port() == "" {
+		port := "587"
+		if s.ImplicitTLS {
+			port = "465"
+		}
+		s.Addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	if u.User != nil {
+		s.Username = u.User.Username()
+		s.Password, _ = u.User.Password()
+	}
+	for _, to := range strings.Split(u.Query().Get("to"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			s.To = append(s.To, to)
+		}
+	}
+	if s.From == "" || len(s.To) == 0 {
+		return nil, fmt.Errorf("smtp notification url needs the from and to parameters")
+	}
+	return s, nil
+}
+
+func (s *SMTP) Notify(ctx context.Context, msg Message) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	if s.ImplicitTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", s.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", s.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && !s.ImplicitTLS {
+		if err = client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", s.Username, s.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err = client.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err = client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(s.compose(msg)); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (s *SMTP) compose(msg Message) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", s.From)
+	fmt.Fprintf(&sb, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&sb, "Subject: %s\r\n", msg.Title)
+	fmt.Fprintf(&sb, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	sb.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	sb.WriteString("\r\n")
+	return []byte(sb.String())
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/ente-io/cli/pkg/mapper"
 	"github.com/ente-io/cli/pkg/model"
 	"github.com/ente-io/cli/utils/encoding"
-	"log"
 	"strconv"
 	"time"
 )
@@ -19,7 +18,7 @@ func (c *ClICtrl) fetchRemoteCollections(ctx context.Context) error {
 	}
 	collections, err := c.Client.GetCollections(ctx, lastSyncTime)
 	if err != nil {
-		return fmt.Errorf("failed to get collections: %s", err)
+		return fmt.Errorf("failed to get collections: %w", err)
 	}
 	maxUpdated := lastSyncTime
 	for _, collection := range collections {
@@ -54,6 +53,10 @@ func (c *ClICtrl) fetchRemoteFiles(ctx context.Context) error {
 		return err
 	}
 	for _, album := range albums {
+		// every page of files is stored with its sync time, stopping between albums keeps the store consistent
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		if album.IsDeleted {
 			continue
 		}
@@ -70,13 +73,9 @@ func (c *ClICtrl) fetchRemoteFiles(ctx context.Context) error {
 				break
 			}
 			if isFirstSync {
-				log.Printf("Sync files metadata for album %s\n", album.AlbumName)
+				c.log(ctx).Info("sync files metadata", "album", album.AlbumName)
 			} else {
-				log.Printf("Sync files metadata for album %s\n from %s", album.AlbumName, time.UnixMicro(lastSyncTime))
-			}
-			if !isFirstSync {
-				t := time.UnixMicro(lastSyncTime)
-				log.Printf("Fetching files metadata for album %s from %v\n", album.AlbumName, t)
+				c.log(ctx).Info("sync files metadata", "album", album.AlbumName, "since", time.UnixMicro(lastSyncTime))
 			}
 			files, hasMore, err := c.Client.GetFiles(ctx, album.ID, lastSyncTime)
 			if err != nil {
@@ -114,7 +113,7 @@ func (c *ClICtrl) fetchRemoteFiles(ctx context.Context) error {
 				maxUpdated = album.LastUpdatedAt
 			}
 			if (maxUpdated > lastSyncTime) || !hasMore {
-				log.Printf("Updating last sync time for album %s to %s\n", album.AlbumName, time.UnixMicro(maxUpdated))
+				c.log(ctx).Debug("updating last sync time", "album", album.AlbumName, "time", time.UnixMicro(maxUpdated))
 				err = c.PutConfigValue(ctx, fmt.Sprintf(model.CollectionsFileSyncKeyFmt, album.ID), []byte(strconv.FormatInt(maxUpdated, 10)))
 				if err != nil {
 					return fmt.Errorf("failed to update last sync time: %s", err)
@@ -2,25 +2,22 @@ package pkg
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/internal/hooks"
 	"github.com/ente-io/cli/pkg/model/export"
-	"log"
-	"os"
-	"strings"
-
+	"github.com/ente-io/cli/pkg/storage"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
-func (c *ClICtrl) createLocalFolderForRemoteAlbums(ctx context.Context, account model.Account) error {
-	path := account.ExportDir
+func (c *ClICtrl) createLocalFolderForRemoteAlbums(ctx context.Context, target storage.Backend) error {
 	albums, err := c.getRemoteAlbums(ctx)
 	if err != nil {
 		return err
 	}
 	userID := ctx.Value("user_id").(int64)
-	folderToMetaMap, albumIDToMetaMap, err := readFolderMetadata(path)
+	folderToMetaMap, albumIDToMetaMap, err := readFolderMetadata(ctx, target)
 	if err != nil {
 		return err
 	}
@@ -28,10 +25,11 @@ func (c *ClICtrl) createLocalFolderForRemoteAlbums(ctx context.Context, account
 	for _, album := range albums {
 		if album.IsDeleted {
 			if meta, ok := albumIDToMetaMap[album.ID]; ok {
-				log.Printf("Deleting album %s as it is deleted", meta.AlbumName)
-				if err = os.RemoveAll(filepath.Join(path, meta.FolderName)); err != nil {
+				c.log(ctx).Info("deleting album folder of deleted album", "album", meta.AlbumName, "folder", meta.FolderName)
+				if err = target.RemoveAll(ctx, meta.FolderName); err != nil {
 					return err
 				}
+				fireHook(ctx, hooks.Event{Type: hooks.AlbumDeleted, AlbumID: meta.ID, Album: meta.AlbumName, Folder: meta.FolderName})
 				delete(folderToMetaMap, meta.FolderName)
 				delete(albumIDToMetaMap, meta.ID)
 			}
@@ -46,45 +44,24 @@ func (c *ClICtrl) createLocalFolderForRemoteAlbums(ctx context.Context, account
 			}
 		}
 
-		albumFolderName := filepath.Clean(album.AlbumName)
-		// replace : with _
-		albumFolderName = strings.ReplaceAll(albumFolderName, ":", "_")
-		albumFolderName = strings.ReplaceAll(albumFolderName, "/", "_")
-		albumFolderName = strings.TrimSpace(albumFolderName)
-
+		albumFolderName := uniqueAlbumFolderName(album.AlbumName, folderToMetaMap)
 		albumID := album.ID
-
-		if _, ok := folderToMetaMap[albumFolderName]; ok {
-			for i := 1; ; i++ {
-				newAlbumName := fmt.Sprintf("%s_%d", albumFolderName, i)
-				if _, ok := folderToMetaMap[newAlbumName]; !ok {
-					albumFolderName = newAlbumName
-					break
-				}
-			}
-		}
 		// Create album and meta folders if they don't exist
-		albumPath := filepath.Clean(filepath.Join(path, albumFolderName))
-		metaPath := filepath.Join(albumPath, ".meta")
+		metaPath := path.Join(albumFolderName, albumMetaFolder)
 		if metaByID == nil {
-			log.Printf("Adding folder %s for album %s", albumFolderName, album.AlbumName)
-			for _, p := range []string{albumPath, metaPath} {
-				if _, err := os.Stat(p); os.IsNotExist(err) {
-					if err = os.Mkdir(p, 0755); err != nil {
-						return err
-					}
-				}
+			c.log(ctx).Info("adding album folder", "album", album.AlbumName, "folder", albumFolderName)
+			if err = target.MkdirAll(ctx, metaPath); err != nil {
+				return err
 			}
 		} else {
 			// rename meta.FolderName to albumFolderName
-			oldAlbumPath := filepath.Join(path, metaByID.FolderName)
-			log.Printf("Renaming path from %s to %s for album %s", oldAlbumPath, albumPath, album.AlbumName)
-			if err = os.Rename(oldAlbumPath, albumPath); err != nil {
+			c.log(ctx).Info("renaming album folder", "album", album.AlbumName, "from", metaByID.FolderName, "to", albumFolderName)
+			if err = target.Rename(ctx, metaByID.FolderName, albumFolderName); err != nil {
 				return err
 			}
 		}
 		// Handle meta file
-		metaFilePath := filepath.Join(path, albumFolderName, albumMetaFolder, albumMetaFile)
+		metaFilePath := path.Join(metaPath, albumMetaFile)
 		metaData := export.AlbumMetadata{
 			ID:              album.ID,
 			OwnerID:         album.OwnerID,
@@ -93,40 +70,58 @@ func (c *ClICtrl) createLocalFolderForRemoteAlbums(ctx context.Context, account
 			AccountOwnerIDs: []int64{userID},
 			FolderName:      albumFolderName,
 		}
-		if err = writeJSONToFile(metaFilePath, metaData); err != nil {
+		if err = target.WriteSidecar(ctx, metaFilePath, metaData); err != nil {
 			return err
 		}
 		folderToMetaMap[albumFolderName] = &metaData
 		albumIDToMetaMap[albumID] = &metaData
+		if metaByID == nil {
+			fireHook(ctx, hooks.Event{Type: hooks.AlbumCreated, AlbumID: albumID, Album: album.AlbumName, Folder: albumFolderName})
+		} else {
+			fireHook(ctx, hooks.Event{Type: hooks.AlbumRenamed, AlbumID: albumID, Album: album.AlbumName, Folder: albumFolderName, OldFolder: metaByID.FolderName})
+		}
 	}
 	return nil
 }
 
-// readFolderMetadata returns a map of folder name to album metadata for all folders in the given path
-// and a map of album ID to album metadata for all albums in the given path.
-func readFolderMetadata(path string) (map[string]*export.AlbumMetadata, map[int64]*export.AlbumMetadata, error) {
+// uniqueAlbumFolderName returns a folder name for the album which isn't used by any other album in the export
+func uniqueAlbumFolderName(albumName string, folderToMetaMap map[string]*export.AlbumMetadata) string {
+	albumFolderName := filepath.Clean(albumName)
+	// replace : with _
+	albumFolderName = strings.ReplaceAll(albumFolderName, ":", "_")
+	albumFolderName = strings.ReplaceAll(albumFolderName, "/", "_")
+	albumFolderName = strings.TrimSpace(albumFolderName)
+	if _, ok := folderToMetaMap[albumFolderName]; ok {
+		for i := 1; ; i++ {
+			newAlbumName := fmt.Sprintf("%s_%d", albumFolderName, i)
+			if _, ok := folderToMetaMap[newAlbumName]; !ok {
+				return newAlbumName
+			}
+		}
+	}
+	return albumFolderName
+}
+
+// readFolderMetadata returns a map of folder name to album metadata for all folders in the export target
+// and a map of album ID to album metadata for all albums in the export target.
+func readFolderMetadata(ctx context.Context, target storage.Backend) (map[string]*export.AlbumMetadata, map[int64]*export.AlbumMetadata, error) {
 	result := make(map[string]*export.AlbumMetadata)
 	albumIdToMetadataMap := make(map[int64]*export.AlbumMetadata)
-	// Read the top-level directories in the given path
-	entries, err := os.ReadDir(path)
+	// Read the top-level directories of the export
+	entries, err := target.List(ctx, "")
 	if err != nil {
 		return nil, nil, err
 	}
 	for _, entry := range entries {
-		if entry.IsDir() {
-			dirName := entry.Name()
-			metaFilePath := filepath.Join(path, dirName, albumMetaFolder, albumMetaFile)
+		if entry.IsDir {
+			dirName := entry.Name
+			metaFilePath := path.Join(dirName, albumMetaFolder, albumMetaFile)
 			// Initialize as nil, will remain nil if JSON file is not found or not readable
 			result[dirName] = nil
 			// Read the JSON file if it exists
-			if _, err := os.Stat(metaFilePath); err == nil {
+			if _, err := target.Stat(ctx, metaFilePath); err == nil {
 				var metaData export.AlbumMetadata
-				metaDataBytes, err := os.ReadFile(metaFilePath)
-				if err != nil {
-					continue // Skip this entry if reading fails
-				}
-
-				if err := json.Unmarshal(metaDataBytes, &metaData); err == nil {
+				if err := target.ReadSidecar(ctx, metaFilePath, &metaData); err == nil {
 					metaData.FolderName = dirName
 					result[dirName] = &metaData
 					albumIdToMetadataMap[metaData.ID] = &metaData
@@ -6,21 +6,25 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/ente-io/cli/internal/hooks"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/internal/metrics"
+	"github.com/ente-io/cli/internal/progress"
 	"github.com/ente-io/cli/pkg/mapper"
 	"github.com/ente-io/cli/pkg/model"
 	"github.com/ente-io/cli/pkg/model/export"
+	"github.com/ente-io/cli/pkg/storage"
 	"github.com/ente-io/cli/utils"
-	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
 )
 
-func (c *ClICtrl) syncFiles(ctx context.Context, account model.Account) error {
-	log.Printf("Starting file download")
-	exportRoot := account.ExportDir
-	_, albumIDToMetaMap, err := readFolderMetadata(exportRoot)
+func (c *ClICtrl) syncFiles(ctx context.Context, target storage.Backend, result *model.AccountExportResult) error {
+	c.log(ctx).Info("starting file download")
+	_, albumIDToMetaMap, err := readFolderMetadata(ctx, target)
 	if err != nil {
 		return err
 	}
@@ -28,17 +32,25 @@ func (c *ClICtrl) syncFiles(ctx context.Context, account model.Account) error {
 	if err != nil {
 		return err
 	}
-	log.Println("total entries", len(entries))
+	c.log(ctx).Info("loaded album entries", "count", len(entries))
 	model.SortAlbumFileEntry(entries)
+	if err = c.trackPendingFiles(ctx, entries, albumIDToMetaMap); err != nil {
+		return err
+	}
+	tracker := progress.FromContext(ctx)
 	defer utils.TimeTrack(time.Now(), "process_files")
 	var albumDiskInfo *albumDiskInfo
 	for i, albumFileEntry := range entries {
+		// stop between files, so that an interrupted export leaves no partially written file
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		if albumFileEntry.SyncedLocally {
 			continue
 		}
 		albumInfo, ok := albumIDToMetaMap[albumFileEntry.AlbumID]
 		if !ok {
-			log.Printf("Album %d not found in local metadata", albumFileEntry.AlbumID)
+			c.log(ctx).Warn("album not found in local metadata", "albumID", albumFileEntry.AlbumID)
 			continue
 		}
 		if albumInfo.IsDeleted {
@@ -50,30 +62,39 @@ func (c *ClICtrl) syncFiles(ctx context.Context, account model.Account) error {
 		}
 
 		if albumDiskInfo == nil || albumDiskInfo.AlbumMeta.ID != albumInfo.ID {
-			albumDiskInfo, err = readFilesMetadata(exportRoot, albumInfo)
+			albumDiskInfo, err = readFilesMetadata(ctx, target, albumInfo)
 			if err != nil {
 				return err
 			}
 		}
+		albumResult := result.Album(albumInfo.ID, albumInfo.AlbumName)
 		fileBytes, err := c.GetValue(ctx, model.RemoteFiles, []byte(fmt.Sprintf("%d", albumFileEntry.FileID)))
 		if err != nil {
 			return err
 		}
+		tracker.StartFile(albumInfo.AlbumName)
 		if fileBytes != nil {
 			var existingEntry *model.RemoteFile
 			err = json.Unmarshal(fileBytes, &existingEntry)
 			if err != nil {
 				return err
 			}
-			log.Printf("[%d/%d] Sync %s for album %s", i, len(entries), existingEntry.GetTitle(), albumInfo.AlbumName)
-			err = c.downloadEntry(ctx, albumDiskInfo, *existingEntry, albumFileEntry)
+			c.log(ctx).Debug("sync file", "index", i, "total", len(entries), "file", existingEntry.GetTitle(), "album", albumInfo.AlbumName)
+			err = c.downloadEntry(ctx, albumDiskInfo, *existingEntry, albumFileEntry, albumResult)
+			tracker.FinishFile(existingEntry.Info.FileSize)
 			if err != nil {
 				if errors.Is(err, model.ErrDecryption) {
+					albumResult.Failed++
+					metrics.DecryptionFailures.WithLabelValues(accountLabel(ctx)).Inc()
 					continue
 				} else if existingEntry.IsLivePhoto() && errors.Is(err, zip.ErrFormat) {
-					log.Printf(fmt.Sprintf("err processing live photo %s (%d), %s", existingEntry.GetTitle(), existingEntry.ID, err.Error()))
+					c.log(ctx).Error("failed to process live photo", "file", existingEntry.GetTitle(), "fileID", existingEntry.ID, "error", err)
+					albumResult.Failed++
+					metrics.LivePhotoErrors.WithLabelValues(accountLabel(ctx)).Inc()
 					continue
 				} else if existingEntry.IsLivePhoto() && errors.Is(err, model.ErrLiveZip) {
+					albumResult.Failed++
+					metrics.LivePhotoErrors.WithLabelValues(accountLabel(ctx)).Inc()
 					continue
 				} else {
 					return err
@@ -84,30 +105,62 @@ func (c *ClICtrl) syncFiles(ctx context.Context, account model.Account) error {
 			if albumFileEntry.IsDeleted {
 				delErr := c.DeleteAlbumEntry(ctx, albumFileEntry)
 				if delErr != nil {
-					log.Fatalf("Error deleting album entry %d (deleted: %v)  %v", albumFileEntry.FileID, albumFileEntry.IsDeleted, delErr)
+					return fmt.Errorf("delete album entry %d: %w", albumFileEntry.FileID, delErr)
 				}
 			} else {
-				log.Fatalf("Failed to find entry in db for file %d (deleted: %v)", albumFileEntry.FileID, albumFileEntry.IsDeleted)
+				c.log(ctx).Error("file missing in local db", "fileID", albumFileEntry.FileID, "albumID", albumFileEntry.AlbumID)
+				albumResult.Failed++
 			}
+			tracker.FinishFile(0)
 		}
 	}
 
 	return nil
 }
 
+// trackPendingFiles adds the entries which still have to be synced to the progress tracker of the context
+func (c *ClICtrl) trackPendingFiles(ctx context.Context, entries []*model.AlbumFileEntry, albums map[int64]*export.AlbumMetadata) error {
+	tracker := progress.FromContext(ctx)
+	if tracker == nil {
+		return nil
+	}
+	files, err := c.getRemoteFiles(ctx)
+	if err != nil {
+		return err
+	}
+	sizes := make(map[int64]int64, len(files))
+	for _, file := range files {
+		sizes[file.ID] = file.Info.FileSize
+	}
+	for _, entry := range entries {
+		albumInfo, ok := albums[entry.AlbumID]
+		if entry.SyncedLocally || !ok || albumInfo.IsDeleted {
+			continue
+		}
+		tracker.AddPending(albumInfo.AlbumName, sizes[entry.FileID])
+	}
+	return nil
+}
+
 func (c *ClICtrl) downloadEntry(ctx context.Context,
 	diskInfo *albumDiskInfo,
 	file model.RemoteFile,
 	albumEntry *model.AlbumFileEntry,
+	result *model.AlbumExportResult,
 ) error {
+	// finish the file even if the export is cancelled meanwhile, syncFiles stops before the next one
+	ctx, cancel := fileContext(ctx)
+	defer cancel()
 	if !diskInfo.AlbumMeta.IsDeleted && albumEntry.IsDeleted {
 		albumEntry.IsDeleted = true
 		diskFileMeta := diskInfo.GetDiskFileMetadata(file)
 		if diskFileMeta != nil {
-			removeErr := removeDiskFile(diskFileMeta, diskInfo)
+			removeErr := removeDiskFile(ctx, diskFileMeta, diskInfo)
 			if removeErr != nil {
 				return removeErr
 			}
+			result.Removed++
+			metrics.FilesRemoved.WithLabelValues(accountLabel(ctx)).Inc()
 		}
 		delErr := c.DeleteAlbumEntry(ctx, albumEntry)
 		if delErr != nil {
@@ -116,8 +169,9 @@ func (c *ClICtrl) downloadEntry(ctx context.Context,
 		return nil
 	}
 	diskFileMeta := diskInfo.GetDiskFileMetadata(file)
+	isUpdate := diskFileMeta != nil
 	if diskFileMeta != nil {
-		removeErr := removeDiskFile(diskFileMeta, diskInfo)
+		removeErr := removeDiskFile(ctx, diskFileMeta, diskInfo)
 		if removeErr != nil {
 			return removeErr
 		}
@@ -127,98 +181,160 @@ func (c *ClICtrl) downloadEntry(ctx context.Context,
 		if err != nil {
 			return err
 		}
-		fileDiskMetadata := mapper.MapRemoteFileToDiskMetadata(file)
-		// Get the extension
-		extension := filepath.Ext(fileDiskMetadata.Title)
-		baseFileName := strings.TrimSuffix(filepath.Clean(filepath.Base(fileDiskMetadata.Title)), extension)
-		diskMetaFileName := diskInfo.GenerateUniqueMetaFileName(baseFileName, extension)
-		if file.IsLivePhoto() {
-			imagePath, videoPath, err := UnpackLive(*decrypt)
-			if err != nil {
-				return err
-			}
-			if imagePath == "" && videoPath == "" {
-				log.Printf("imagePath %s, videoPath %s", imagePath, videoPath)
-				return model.ErrLiveZip
-			}
-			if imagePath != "" {
-				imageExtn := filepath.Ext(imagePath)
-				imageFileName := diskInfo.GenerateUniqueFileName(baseFileName, imageExtn)
-				imageFilePath := filepath.Join(diskInfo.ExportRoot, diskInfo.AlbumMeta.FolderName, imageFileName)
-				moveErr := Move(imagePath, imageFilePath)
-				if moveErr != nil {
-					return moveErr
-				}
-				fileDiskMetadata.AddFileName(imageFileName)
-			}
-			if videoPath == "" {
-				videoExtn := filepath.Ext(videoPath)
-				videoFileName := diskInfo.GenerateUniqueFileName(baseFileName, videoExtn)
-				videoFilePath := filepath.Join(diskInfo.ExportRoot, diskInfo.AlbumMeta.FolderName, videoFileName)
-				// move the decrypt file to filePath
-				moveErr := Move(videoPath, videoFilePath)
-				if moveErr != nil {
-					return moveErr
-				}
-				fileDiskMetadata.AddFileName(videoFileName)
-			}
+		if err = writeDecryptedFile(ctx, diskInfo, file, *decrypt); err != nil {
+			// the decrypted file is only left behind if it wasn't moved to the export
+			_ = os.Remove(*decrypt)
+			return err
+		}
+		fireFileHook(ctx, hooks.FileAdded, diskInfo, diskInfo.GetDiskFileMetadata(file))
+		result.Downloaded++
+		metrics.FilesDownloaded.WithLabelValues(accountLabel(ctx)).Inc()
+		if isUpdate {
+			result.Updated++
 		} else {
-			fileName := diskInfo.GenerateUniqueFileName(baseFileName, extension)
-			filePath := filepath.Join(diskInfo.ExportRoot, diskInfo.AlbumMeta.FolderName, fileName)
-			// move the decrypt file to filePath
-			err = Move(*decrypt, filePath)
-			if err != nil {
-				return err
-			}
-			fileDiskMetadata.AddFileName(fileName)
+			result.Created++
 		}
+		albumEntry.SyncedLocally = true
+		putErr := c.UpsertAlbumEntry(ctx, albumEntry)
+		if putErr != nil {
+			return putErr
+		}
+	}
+	return nil
+}
 
-		fileDiskMetadata.MetaFileName = diskMetaFileName
-		err = diskInfo.AddEntry(fileDiskMetadata)
+// writeDecryptedFile moves the decrypted file to the album folder of the export, unpacking live photos,
+// and writes its metadata sidecar.
+func writeDecryptedFile(ctx context.Context, diskInfo *albumDiskInfo, file model.RemoteFile, decryptedPath string) (err error) {
+	fileDiskMetadata := mapper.MapRemoteFileToDiskMetadata(file)
+	// files already moved to the export, they are removed again if a later step fails so that the
+	// export doesn't keep files without their metadata
+	var written []string
+	defer func() {
+		if err != nil {
+			rollbackFiles(ctx, diskInfo, written)
+		}
+	}()
+	// Get the extension
+	extension := filepath.Ext(fileDiskMetadata.Title)
+	baseFileName := strings.TrimSuffix(filepath.Clean(filepath.Base(fileDiskMetadata.Title)), extension)
+	diskMetaFileName := diskInfo.GenerateUniqueMetaFileName(baseFileName, extension)
+	if file.IsLivePhoto() {
+		imagePath, videoPath, err := UnpackLive(decryptedPath)
 		if err != nil {
 			return err
 		}
-
-		err = writeJSONToFile(filepath.Join(diskInfo.ExportRoot, diskInfo.AlbumMeta.FolderName, ".meta", diskMetaFileName), fileDiskMetadata)
+		if imagePath == "" && videoPath == "" {
+			logging.FromContext(ctx, nil).Error("live photo has neither an image nor a video", "file", file.GetTitle())
+			return model.ErrLiveZip
+		}
+		if imagePath != "" {
+			imageExtn := filepath.Ext(imagePath)
+			imageFileName := diskInfo.GenerateUniqueFileName(baseFileName, imageExtn)
+			imageFilePath := path.Join(diskInfo.AlbumMeta.FolderName, imageFileName)
+			moveErr := diskInfo.Target.PutFile(ctx, imagePath, imageFilePath)
+			if moveErr != nil {
+				return moveErr
+			}
+			written = append(written, imageFilePath)
+			fileDiskMetadata.AddFileName(imageFileName)
+		}
+		if videoPath != "" {
+			videoExtn := filepath.Ext(videoPath)
+			videoFileName := diskInfo.GenerateUniqueFileName(baseFileName, videoExtn)
+			videoFilePath := path.Join(diskInfo.AlbumMeta.FolderName, videoFileName)
+			// move the decrypt file to filePath
+			moveErr := diskInfo.Target.PutFile(ctx, videoPath, videoFilePath)
+			if moveErr != nil {
+				return moveErr
+			}
+			written = append(written, videoFilePath)
+			fileDiskMetadata.AddFileName(videoFileName)
+		}
+	} else {
+		fileName := diskInfo.GenerateUniqueFileName(baseFileName, extension)
+		filePath := path.Join(diskInfo.AlbumMeta.FolderName, fileName)
+		// move the decrypt file to filePath
+		err = diskInfo.Target.PutFile(ctx, decryptedPath, filePath)
 		if err != nil {
 			return err
 		}
-		albumEntry.SyncedLocally = true
-		putErr := c.UpsertAlbumEntry(ctx, albumEntry)
-		if putErr != nil {
-			return putErr
+		written = append(written, filePath)
+		fileDiskMetadata.AddFileName(fileName)
+	}
+
+	fileDiskMetadata.MetaFileName = diskMetaFileName
+	if err = diskInfo.AddEntry(fileDiskMetadata); err != nil {
+		return err
+	}
+	err = diskInfo.Target.WriteSidecar(ctx, path.Join(diskInfo.AlbumMeta.FolderName, albumMetaFolder, diskMetaFileName), fileDiskMetadata)
+	if err != nil {
+		_ = diskInfo.RemoveEntry(fileDiskMetadata)
+	}
+	return err
+}
+
+// rollbackFiles removes the given files from the export target. It runs even when the context is cancelled,
+// as that's usually why the file couldn't be completed.
+func rollbackFiles(ctx context.Context, diskInfo *albumDiskInfo, filePaths []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, filePath := range filePaths {
+		if err := diskInfo.Target.Remove(ctx, filePath); err != nil {
+			logging.FromContext(ctx, nil).Warn("failed to remove incomplete file", "file", filePath, "error", err)
 		}
 	}
-	return nil
 }
 
-func removeDiskFile(diskFileMeta *export.DiskFileMetadata, diskInfo *albumDiskInfo) error {
-	// remove the file from disk
-	log.Printf("Removing file %s from disk", diskFileMeta.MetaFileName)
-	err := os.Remove(filepath.Join(diskInfo.ExportRoot, diskInfo.AlbumMeta.FolderName, ".meta", diskFileMeta.MetaFileName))
-	if err != nil && !os.IsNotExist(err) {
+func removeDiskFile(ctx context.Context, diskFileMeta *export.DiskFileMetadata, diskInfo *albumDiskInfo) error {
+	// remove the file from the export target
+	logging.FromContext(ctx, nil).Info("removing file", "file", diskFileMeta.MetaFileName, "album", diskInfo.AlbumMeta.AlbumName)
+	err := diskInfo.Target.Remove(ctx, path.Join(diskInfo.AlbumMeta.FolderName, albumMetaFolder, diskFileMeta.MetaFileName))
+	if err != nil {
 		return err
 	}
 	for _, fileName := range diskFileMeta.Info.FileNames {
-		err = os.Remove(filepath.Join(diskInfo.ExportRoot, diskInfo.AlbumMeta.FolderName, fileName))
-		if err != nil && !os.IsNotExist(err) {
+		err = diskInfo.Target.Remove(ctx, path.Join(diskInfo.AlbumMeta.FolderName, fileName))
+		if err != nil {
 			return err
 		}
 	}
-	return diskInfo.RemoveEntry(diskFileMeta)
+	if err = diskInfo.RemoveEntry(diskFileMeta); err != nil {
+		return err
+	}
+	fireFileHook(ctx, hooks.FileRemoved, diskInfo, diskFileMeta)
+	return nil
 }
 
-// readFolderMetadata reads the metadata of the files in the given path
+// fireFileHook sends the event for the file of the album to the hooks
+func fireFileHook(ctx context.Context, eventType hooks.EventType, diskInfo *albumDiskInfo, diskFileMeta *export.DiskFileMetadata) {
+	if diskFileMeta == nil {
+		return
+	}
+	files := make([]string, 0, len(diskFileMeta.Info.FileNames))
+	for _, fileName := range diskFileMeta.Info.FileNames {
+		files = append(files, path.Join(diskInfo.AlbumMeta.FolderName, fileName))
+	}
+	fireHook(ctx, hooks.Event{
+		Type:    eventType,
+		AlbumID: diskInfo.AlbumMeta.ID,
+		Album:   diskInfo.AlbumMeta.AlbumName,
+		Folder:  diskInfo.AlbumMeta.FolderName,
+		FileID:  diskFileMeta.Info.ID,
+		Files:   files,
+	})
+}
+
+// readFilesMetadata reads the metadata of the files in the given album folder of the export target.
 // For disk export, a particular albums files are stored in a folder named after the album.
 // Inside the folder, the files are stored at top level and its metadata is stored in a .meta folder
-func readFilesMetadata(home string, albumMeta *export.AlbumMetadata) (*albumDiskInfo, error) {
-	albumMetadataFolder := filepath.Join(home, albumMeta.FolderName, albumMetaFolder)
-	albumPath := filepath.Join(home, albumMeta.FolderName)
+func readFilesMetadata(ctx context.Context, target storage.Backend, albumMeta *export.AlbumMetadata) (*albumDiskInfo, error) {
+	albumMetadataFolder := path.Join(albumMeta.FolderName, albumMetaFolder)
+	albumPath := albumMeta.FolderName
 	// verify the both the album folder and the .meta folder exist
-	if _, err := os.Stat(albumMetadataFolder); err != nil {
+	if _, err := target.Stat(ctx, albumMetadataFolder); err != nil {
 		return nil, err
 	}
-	if _, err := os.Stat(albumPath); err != nil {
+	if _, err := target.Stat(ctx, albumPath); err != nil {
 		return nil, err
 	}
 	result := make(map[string]*export.DiskFileMetadata)
@@ -226,39 +342,35 @@ func readFilesMetadata(home string, albumMeta *export.AlbumMetadata) (*albumDisk
 	fileIdToMetadata := make(map[int64]*export.DiskFileMetadata)
 	claimedFileName := make(map[string]bool)
 	// Read the top-level directories in the given path
-	albumFileEntries, err := os.ReadDir(albumPath)
+	albumFileEntries, err := target.List(ctx, albumPath)
 	if err != nil {
 		return nil, err
 	}
 	for _, entry := range albumFileEntries {
-		if !entry.IsDir() {
-			claimedFileName[strings.ToLower(entry.Name())] = true
+		if !entry.IsDir {
+			claimedFileName[strings.ToLower(entry.Name)] = true
 		}
 	}
-	metaEntries, err := os.ReadDir(albumMetadataFolder)
+	metaEntries, err := target.List(ctx, albumMetadataFolder)
 	if err != nil {
 		return nil, err
 	}
 	for _, entry := range metaEntries {
-		if !entry.IsDir() {
-			fileName := entry.Name()
+		if !entry.IsDir {
+			fileName := entry.Name
 			if fileName == albumMetaFile {
 				continue
 			}
 			if !strings.HasSuffix(fileName, ".json") {
-				log.Printf("Skipping file %s as it is not a JSON file", fileName)
+				logging.FromContext(ctx, nil).Debug("skipping file which is not a JSON file", "file", fileName)
 				continue
 			}
-			fileMetadataPath := filepath.Join(albumMetadataFolder, fileName)
+			fileMetadataPath := path.Join(albumMetadataFolder, fileName)
 			// Initialize as nil, will remain nil if JSON file is not found or not readable
 			result[strings.ToLower(fileName)] = nil
 			// Read the JSON file if it exists
 			var metaData export.DiskFileMetadata
-			metaDataBytes, err := os.ReadFile(fileMetadataPath)
-			if err != nil {
-				continue // Skip this entry if reading fails
-			}
-			if err := json.Unmarshal(metaDataBytes, &metaData); err == nil {
+			if err := target.ReadSidecar(ctx, fileMetadataPath, &metaData); err == nil {
 				metaData.MetaFileName = fileName
 				result[strings.ToLower(fileName)] = &metaData
 				fileIdToMetadata[metaData.Info.ID] = &metaData
@@ -266,7 +378,7 @@ func readFilesMetadata(home string, albumMeta *export.AlbumMetadata) (*albumDisk
 		}
 	}
 	return &albumDiskInfo{
-		ExportRoot:                home,
+		Target:                    target,
 		AlbumMeta:                 albumMeta,
 		FileNames:                 &claimedFileName,
 		MetaFileNameToDiskFileMap: &result,
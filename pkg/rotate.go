@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ente-io/cli/pkg/model"
+	"github.com/ente-io/cli/pkg/secrets"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// RotateDeviceKey re-encrypts the secrets of the accounts and the keys of their albums and files from oldKey to
+// newKey, in one transaction. save is called before the transaction is committed, to store the new key; the
+// database is left unchanged when it fails. It returns the number of re-encrypted values.
+func (c *ClICtrl) RotateDeviceKey(oldKey, newKey []byte, save func() error) (int, error) {
+	count := 0
+	err := c.DB.Update(func(tx *bolt.Tx) error {
+		accounts := tx.Bucket([]byte(AccBucket))
+		if accounts == nil {
+			return nil
+		}
+		updates, err := rotateValues(accounts, func(v []byte) ([]byte, error) {
+			var account model.Account
+			if err := json.Unmarshal(v, &account); err != nil {
+				return nil, err
+			}
+			n, err := rotateAccount(&account, oldKey, newKey)
+			if err != nil {
+				return nil, fmt.Errorf("account %s: %w", account.Email, err)
+			}
+			count += n
+			return json.Marshal(account)
+		})
+		if err != nil {
+			return err
+		}
+		for accountKey := range updates {
+			dataBucket := tx.Bucket([]byte(accountKey))
+			if dataBucket == nil {
+				continue
+			}
+			stores := map[model.PhotosStore]string{model.RemoteAlbums: "albumKey", model.RemoteFiles: "key"}
+			for store, field := range stores {
+				bucket := dataBucket.Bucket([]byte(store))
+				if bucket == nil {
+					continue
+				}
+				if _, err = rotateValues(bucket, func(v []byte) ([]byte, error) {
+					count++
+					return rotateField(v, field, oldKey, newKey)
+				}); err != nil {
+					return fmt.Errorf("%s of %s: %w", store, accountKey, err)
+				}
+			}
+		}
+		return save()
+	})
+	if err != nil {
+		return 0, err
+	}
+	if c.KeyHolder != nil {
+		c.KeyHolder = secrets.NewKeyHolder(newKey)
+	}
+	return count, nil
+}
+
+// rotateValues replaces every value of the bucket with its rotation, and returns the new values by key
+func rotateValues(bucket *bolt.Bucket, rotate func(v []byte) ([]byte, error)) (map[string][]byte, error) {
+	updates := make(map[string][]byte)
+	// the bucket can't be modified while iterating over it
+	err := bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		rotated, err := rotate(v)
+		if err != nil {
+			return err
+		}
+		updates[string(k)] = rotated
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range updates {
+		if err = bucket.Put([]byte(k), v); err != nil {
+			return nil, err
+		}
+	}
+	return updates, nil
+}
+
+func rotateAccount(account *model.Account, oldKey, newKey []byte) (int, error) {
+	values := []*model.EncString{&account.MasterKey, &account.SecretKey, &account.Token}
+	if account.ExportKey != nil {
+		values = append(values, account.ExportKey)
+	}
+	if account.S3 != nil {
+		values = append(values, &account.S3.SecretKey)
+	}
+	for _, value := range values {
+		if err := value.Rotate(oldKey, newKey); err != nil {
+			return 0, fmt.Errorf("failed to decrypt with the device key: %w", err)
+		}
+	}
+	return len(values), nil
+}
+
+// rotateField re-encrypts the EncString field of a JSON object, keeping the other fields as they are
+func rotateField(v []byte, field string, oldKey, newKey []byte) ([]byte, error) {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(v, &object); err != nil {
+		return nil, err
+	}
+	var value model.EncString
+	if err := json.Unmarshal(object[field], &value); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	if err := value.Rotate(oldKey, newKey); err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s with the device key: %w", field, err)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	object[field] = encoded
+	return json.Marshal(object)
+}
@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/ente-io/cli/pkg/model"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func newRotateTestCtrl(t *testing.T, deviceKey []byte) (*ClICtrl, model.Account) {
+	db, err := GetDB(filepath.Join(t.TempDir(), "ente-cli.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	account := model.Account{
+		Email:     "user@example.org",
+		UserID:    1,
+		App:       "photos",
+		MasterKey: *model.MakeEncString([]byte("master"), deviceKey),
+		SecretKey: *model.MakeEncString([]byte("secret"), deviceKey),
+		Token:     *model.MakeEncString([]byte("token"), deviceKey),
+		ExportKey: model.MakeEncString([]byte("export"), deviceKey),
+	}
+	album, _ := json.Marshal(model.RemoteAlbum{ID: 2, AlbumName: "Trip", AlbumKey: *model.MakeEncString([]byte("album"), deviceKey)})
+	file, _ := json.Marshal(model.RemoteFile{ID: 3, Key: *model.MakeEncString([]byte("file"), deviceKey),
+		Metadata: map[string]interface{}{"title": "a.jpg", "creationTime": 1700000000000001}})
+	accountBytes, _ := json.Marshal(account)
+	err = db.Update(func(tx *bolt.Tx) error {
+		accounts, err := tx.CreateBucketIfNotExists([]byte(AccBucket))
+		if err != nil {
+			return err
+		}
+		if err = accounts.Put([]byte(account.AccountKey()), accountBytes); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = createDataBuckets(db, account); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		dataBucket := tx.Bucket([]byte(account.AccountKey()))
+		if err := dataBucket.Bucket([]byte(model.RemoteAlbums)).Put([]byte("2"), album); err != nil {
+			return err
+		}
+		return dataBucket.Bucket([]byte(model.RemoteFiles)).Put([]byte("3"), file)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ClICtrl{DB: db}, account
+}
+
+func readRotateTestValues(t *testing.T, c *ClICtrl, account model.Account) (model.Account, model.RemoteAlbum, []byte) {
+	var stored model.Account
+	var album model.RemoteAlbum
+	var file []byte
+	err := c.DB.View(func(tx *bolt.Tx) error {
+		if err := json.Unmarshal(tx.Bucket([]byte(AccBucket)).Get([]byte(account.AccountKey())), &stored); err != nil {
+			return err
+		}
+		dataBucket := tx.Bucket([]byte(account.AccountKey()))
+		if err := json.Unmarshal(dataBucket.Bucket([]byte(model.RemoteAlbums)).Get([]byte("2")), &album); err != nil {
+			return err
+		}
+		file = append(file, dataBucket.Bucket([]byte(model.RemoteFiles)).Get([]byte("3"))...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stored, album, file
+}
+
+func TestRotateDeviceKey(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	c, account := newRotateTestCtrl(t, oldKey)
+
+	// a failure to store the new key leaves the database unchanged
+	if _, err := c.RotateDeviceKey(oldKey, newKey, func() error { return errors.New("keyring locked") }); err == nil {
+		t.Fatal("expected the error of the store")
+	}
+	stored, _, _ := readRotateTestValues(t, c, account)
+	if _, err := stored.MasterKey.Decrypt(oldKey); err != nil {
+		t.Fatalf("expected the secrets to be encrypted with the old key: %v", err)
+	}
+	if _, err := c.RotateDeviceKey(newKey, oldKey, func() error { return nil }); err == nil {
+		t.Fatal("expected an error when the current key doesn't match")
+	}
+
+	count, err := c.RotateDeviceKey(oldKey, newKey, func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Errorf("expected 6 re-encrypted values, got %d", count)
+	}
+	stored, album, file := readRotateTestValues(t, c, account)
+	for name, value := range map[string]*model.EncString{"master": &stored.MasterKey, "secret": &stored.SecretKey,
+		"token": &stored.Token, "export": stored.ExportKey, "album": &album.AlbumKey} {
+		if plain, err := value.Decrypt(newKey); err != nil || string(plain) != name {
+			t.Errorf("expected %s with the new key, got %q: %v", name, plain, err)
+		}
+	}
+	var remoteFile model.RemoteFile
+	if err = json.Unmarshal(file, &remoteFile); err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := remoteFile.Key.Decrypt(newKey); err != nil || string(plain) != "file" {
+		t.Errorf("expected the file key with the new key, got %q: %v", plain, err)
+	}
+	if album.AlbumName != "Trip" || !bytes.Contains(file, []byte(`"creationTime":1700000000000001`)) {
+		t.Errorf("expected the other fields to be kept, got %+v %s", album, file)
+	}
+}
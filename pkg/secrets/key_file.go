@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+)
+
+const (
+	keyFileVersion = 1
+	// the key is derived on every run of the cli, so the limits are the interactive ones of libsodium
+	keyFileMemLimit = 64 * 1024 * 1024
+	keyFileOpsLimit = 2
+)
+
+var ErrWrongPassphrase = errors.New("incorrect passphrase for the device key file")
+
+// keyFile is the device key encrypted with a key derived from a passphrase
+type keyFile struct {
+	Version      int    `json:"version"`
+	KDFSalt      string `json:"kdfSalt"`
+	MemLimit     int    `json:"memLimit"`
+	OpsLimit     int    `json:"opsLimit"`
+	EncryptedKey string `json:"encryptedKey"`
+	Nonce        string `json:"nonce"`
+}
+
+// keyFileStore keeps the device key in a file, encrypted with a passphrase
+type keyFileStore struct {
+	path       string
+	passphrase func(confirm bool) (string, error)
+}
+
+func (s keyFileStore) Name() string {
+	return "keyfile " + s.path
+}
+
+func (s keyFileStore) Load() ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading the device key file: %w", err)
+	}
+	var file keyFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid device key file %s: %w", s.path, err)
+	}
+	if file.Version != keyFileVersion {
+		return nil, fmt.Errorf("unsupported device key file version %d", file.Version)
+	}
+	passphrase, err := s.passphrase(false)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := eCrypto.DeriveArgonKey(passphrase, file.KDFSalt, file.MemLimit, file.OpsLimit)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := base64.StdEncoding.DecodeString(file.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device key file %s: %w", s.path, err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(file.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device key file %s: %w", s.path, err)
+	}
+	key, err := eCrypto.SecretBoxOpen(encryptedKey, nonce, kek)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+func (s keyFileStore) Save(key []byte) error {
+	passphrase, err := s.passphrase(true)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return err
+	}
+	file := keyFile{
+		Version:  keyFileVersion,
+		KDFSalt:  base64.StdEncoding.EncodeToString(salt),
+		MemLimit: keyFileMemLimit,
+		OpsLimit: keyFileOpsLimit,
+	}
+	kek, err := eCrypto.DeriveArgonKey(passphrase, file.KDFSalt, file.MemLimit, file.OpsLimit)
+	if err != nil {
+		return err
+	}
+	encryptedKey, nonce, err := eCrypto.SecretBoxSeal(key, kek)
+	if err != nil {
+		return err
+	}
+	file.EncryptedKey = base64.StdEncoding.EncodeToString(encryptedKey)
+	file.Nonce = base64.StdEncoding.EncodeToString(nonce)
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSecretFile(s.path, data)
+}
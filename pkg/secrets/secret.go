@@ -4,11 +4,8 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
-	"github.com/ente-io/cli/utils/constants"
-	"log"
 	"os"
-
-	"github.com/zalando/go-keyring"
+	"path/filepath"
 )
 
 func IsRunningInContainer() bool {
@@ -19,64 +16,154 @@ func IsRunningInContainer() bool {
 }
 
 const (
-	secretService = "ente"
-	secretUser    = "ente-cli-user"
+	// BackendAuto uses the keyring, and the secret file in the data dir when it's unavailable in a container
+	BackendAuto    = "auto"
+	BackendKeyring = "keyring"
+	// BackendKeyFile stores the device key encrypted with a passphrase
+	BackendKeyFile = "keyfile"
+	// BackendEnv reads the device key from DeviceKeyEnv
+	BackendEnv = "env"
+	// BackendFile reads the device key from a file, e.g. a secret mounted in a container
+	BackendFile = "file"
+
+	// DeviceKeyEnv is the environment variable with the base64 device key for BackendEnv
+	DeviceKeyEnv = "ENTE_DEVICE_KEY"
+
+	deviceKeySize = 32
 )
 
-func GetOrCreateClISecret() []byte {
-	// get password
-	secret, err := keyring.Get(secretService, secretUser)
-	if err != nil {
-		if !errors.Is(err, keyring.ErrNotFound) {
-			if IsRunningInContainer() {
-				return GetSecretFromSecretText()
-			} else {
-				log.Fatal(fmt.Errorf("error getting password from keyring: %w", err))
-			}
-		}
-		key := make([]byte, 32)
-		_, err = rand.Read(key)
-		if err != nil {
-			log.Fatal(fmt.Errorf("error generating key: %w", err))
-		}
-		secret = string(key)
-		keySetErr := keyring.Set(secretService, secretUser, string(secret))
-		if keySetErr != nil {
-			log.Fatal(fmt.Errorf("error setting password in keyring: %w", keySetErr))
-		}
+// Backends are the names of the device key backends
+var Backends = []string{BackendAuto, BackendKeyring, BackendKeyFile, BackendEnv, BackendFile}
+
+var (
+	// ErrNotFound is returned by the stores which don't have a device key yet
+	ErrNotFound = errors.New("no device key stored")
+	// ErrReadOnly is returned by the stores which can't save the device key
+	ErrReadOnly = errors.New("the device key can't be stored in this backend")
+)
 
+// Store keeps the device key, which encrypts the secrets of the accounts in the cli database
+type Store interface {
+	// Name describes the store in messages, e.g. keyfile /path/to/device-key.json
+	Name() string
+	// Load returns the device key, ErrNotFound when there is none
+	Load() ([]byte, error)
+	// Save stores the device key, replacing the existing one
+	Save(key []byte) error
+}
+
+// Options select the device key store
+type Options struct {
+	// Backend is one of Backends, BackendAuto when it's empty
+	Backend string
+	// DataDir is the directory of the cli database, the files of the stores default to it
+	DataDir string
+	// File is the path of the device key of BackendFile, .secret.txt in DataDir by default
+	File string
+	// KeyFile is the path of the passphrase protected key of BackendKeyFile, device-key.json in DataDir by default
+	KeyFile string
+	// Passphrase returns the passphrase of the key file, confirm is set when the key file is created
+	Passphrase func(confirm bool) (string, error)
+}
+
+// ValidateBackend checks that the backend is one of Backends
+func ValidateBackend(backend string) error {
+	for _, b := range Backends {
+		if backend == b {
+			return nil
+		}
 	}
-	return []byte(secret)
+	return fmt.Errorf("invalid secrets backend %q, expected one of auto, keyring, keyfile, env or file", backend)
 }
 
-// GetSecretFromSecretText reads the scecret from the secret text file.
-// If the file does not exist, it will be created and write random 32 byte secret to it.
-func GetSecretFromSecretText() []byte {
-	// Define the path to the secret text file
-	secretFilePath := fmt.Sprintf("%s.secret.txt", constants.CliDataPath)
-
-	// Check if file exists
-	_, err := os.Stat(secretFilePath)
-	if err != nil {
-		if !errors.Is(err, os.ErrNotExist) {
-			log.Fatal(fmt.Errorf("error checking secret file: %w", err))
+// NewStore returns the device key store selected by the options
+func NewStore(opts Options) (Store, error) {
+	file := opts.File
+	if file == "" {
+		file = filepath.Join(opts.DataDir, ".secret.txt")
+	}
+	switch opts.Backend {
+	case "", BackendAuto:
+		var fallback Store
+		if IsRunningInContainer() {
+			fallback = fileStore{path: file}
+		}
+		return autoStore{keyring: keyringStore{}, fallback: fallback}, nil
+	case BackendKeyring:
+		return keyringStore{}, nil
+	case BackendKeyFile:
+		keyFile := opts.KeyFile
+		if keyFile == "" {
+			keyFile = filepath.Join(opts.DataDir, "device-key.json")
 		}
-		// File does not exist; create and write a random 32-byte secret
-		key := make([]byte, 32)
-		_, err := rand.Read(key)
-		if err != nil {
-			log.Fatal(fmt.Errorf("error generating key: %w", err))
+		if opts.Passphrase == nil {
+			return nil, errors.New("the keyfile backend requires a passphrase")
 		}
-		err = os.WriteFile(secretFilePath, key, 0644)
-		if err != nil {
-			log.Fatal(fmt.Errorf("error writing to secret file: %w", err))
+		return keyFileStore{path: keyFile, passphrase: opts.Passphrase}, nil
+	case BackendEnv:
+		return envStore{}, nil
+	case BackendFile:
+		return fileStore{path: file}, nil
+	}
+	return nil, ValidateBackend(opts.Backend)
+}
+
+// LoadOrCreate returns the device key of the store. When it has none, a new random key is generated and saved.
+func LoadOrCreate(store Store) ([]byte, error) {
+	key, err := store.Load()
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if key, err = NewDeviceKey(); err != nil {
+		return nil, err
+	}
+	if err = store.Save(key); err != nil {
+		if errors.Is(err, ErrReadOnly) {
+			return nil, fmt.Errorf("%s has no device key", store.Name())
 		}
-		return key
+		return nil, err
 	}
-	// File exists; read the secret
-	secret, err := os.ReadFile(secretFilePath)
-	if err != nil {
-		log.Fatal(fmt.Errorf("error reading from secret file: %w", err))
+	return key, nil
+}
+
+// NewDeviceKey generates a random device key
+func NewDeviceKey() ([]byte, error) {
+	key := make([]byte, deviceKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("error generating key: %w", err)
+	}
+	return key, nil
+}
+
+// autoStore is the keyring, with the secret file in the data dir as a fallback in containers,
+// which usually don't have a keyring
+type autoStore struct {
+	keyring  Store
+	fallback Store
+}
+
+func (s autoStore) Name() string {
+	return "keyring"
+}
+
+func (s autoStore) Load() ([]byte, error) {
+	key, err := s.keyring.Load()
+	if err == nil || errors.Is(err, ErrNotFound) {
+		return key, err
+	}
+	if s.fallback != nil {
+		return s.fallback.Load()
+	}
+	return nil, fmt.Errorf("%w, select another backend with secrets.backend in the config file, see 'ente secrets'", err)
+}
+
+func (s autoStore) Save(key []byte) error {
+	err := s.keyring.Save(key)
+	if err != nil && s.fallback != nil {
+		return s.fallback.Save(key)
 	}
-	return secret
+	return err
 }
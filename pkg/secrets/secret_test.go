@@ -0,0 +1,127 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret", "device-key")
+	store := fileStore{path: path}
+	if _, err := store.Load(); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	key, err := LoadOrCreate(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0o600) {
+		t.Errorf("expected the key file to be only readable by the user: %v %v", info.Mode(), err)
+	}
+	if loaded, err := store.Load(); err != nil || !bytes.Equal(loaded, key) {
+		t.Errorf("expected the saved key, got %v: %v", loaded, err)
+	}
+	// the key files of earlier versions contain the raw key
+	if err = os.WriteFile(path, key, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := store.Load(); err != nil || !bytes.Equal(loaded, key) {
+		t.Errorf("expected the raw key, got %v: %v", loaded, err)
+	}
+	if err = os.WriteFile(path, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(); err == nil {
+		t.Error("expected an error for an invalid key file")
+	}
+}
+
+func TestEnvStore(t *testing.T) {
+	t.Setenv(DeviceKeyEnv, "")
+	if _, err := LoadOrCreate(envStore{}); err == nil || !strings.Contains(err.Error(), "has no device key") {
+		t.Errorf("expected an error without the variable, got %v", err)
+	}
+	key := bytes.Repeat([]byte{7}, deviceKeySize)
+	t.Setenv(DeviceKeyEnv, base64.StdEncoding.EncodeToString(key))
+	if loaded, err := LoadOrCreate(envStore{}); err != nil || !bytes.Equal(loaded, key) {
+		t.Errorf("expected the key of the variable, got %v: %v", loaded, err)
+	}
+	t.Setenv(DeviceKeyEnv, base64.StdEncoding.EncodeToString(key[:16]))
+	if _, err := LoadOrCreate(envStore{}); err == nil {
+		t.Error("expected an error for a short key")
+	}
+}
+
+func TestKeyFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "device-key.json")
+	passphrase, confirmed := "correct horse", false
+	store := keyFileStore{path: path, passphrase: func(confirm bool) (string, error) {
+		confirmed = confirmed || confirm
+		return passphrase, nil
+	}}
+	key, err := LoadOrCreate(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !confirmed {
+		t.Error("expected the passphrase to be confirmed when the key file is created")
+	}
+	if data, _ := os.ReadFile(path); bytes.Contains(data, []byte(base64.StdEncoding.EncodeToString(key))) {
+		t.Error("expected the key to be encrypted in the key file")
+	}
+	if loaded, err := store.Load(); err != nil || !bytes.Equal(loaded, key) {
+		t.Errorf("expected the saved key, got %v: %v", loaded, err)
+	}
+	passphrase = "wrong"
+	if _, err := store.Load(); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestAutoStore(t *testing.T) {
+	keyring.MockInit()
+	auto := autoStore{keyring: keyringStore{}}
+	key, err := LoadOrCreate(auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := (keyringStore{}).Load(); err != nil || !bytes.Equal(loaded, key) {
+		t.Errorf("expected the key in the keyring, got %v: %v", loaded, err)
+	}
+
+	keyring.MockInitWithError(errors.New("no secret service"))
+	if _, err := LoadOrCreate(auto); err == nil || !strings.Contains(err.Error(), "secrets.backend") {
+		t.Errorf("expected an error pointing to the other backends, got %v", err)
+	}
+	fallback := fileStore{path: filepath.Join(t.TempDir(), ".secret.txt")}
+	auto.fallback = fallback
+	key, err = LoadOrCreate(auto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := fallback.Load(); err != nil || !bytes.Equal(loaded, key) {
+		t.Errorf("expected the key in the fallback file, got %v: %v", loaded, err)
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(Options{Backend: BackendFile, DataDir: dir})
+	if err != nil || store.Name() != "file "+filepath.Join(dir, ".secret.txt") {
+		t.Errorf("unexpected store %v: %v", store, err)
+	}
+	if _, err := NewStore(Options{Backend: BackendKeyFile, DataDir: dir}); err == nil {
+		t.Error("expected an error for the keyfile backend without a passphrase")
+	}
+	if _, err := NewStore(Options{Backend: "vault"}); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
@@ -0,0 +1,110 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	secretService = "ente"
+	secretUser    = "ente-cli-user"
+)
+
+// keyringStore keeps the device key in the OS keyring, the Secret Service on Linux
+type keyringStore struct{}
+
+func (keyringStore) Name() string {
+	return "keyring"
+}
+
+func (keyringStore) Load() ([]byte, error) {
+	secret, err := keyring.Get(secretService, secretUser)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error getting password from keyring: %w", err)
+	}
+	return []byte(secret), nil
+}
+
+func (keyringStore) Save(key []byte) error {
+	if err := keyring.Set(secretService, secretUser, string(key)); err != nil {
+		return fmt.Errorf("error setting password in keyring: %w", err)
+	}
+	return nil
+}
+
+// envStore reads the base64 device key from DeviceKeyEnv
+type envStore struct{}
+
+func (envStore) Name() string {
+	return "$" + DeviceKeyEnv
+}
+
+func (envStore) Load() ([]byte, error) {
+	value := os.Getenv(DeviceKeyEnv)
+	if value == "" {
+		return nil, ErrNotFound
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(key) != deviceKeySize {
+		return nil, fmt.Errorf("$%s must be a base64 encoded %d bytes key", DeviceKeyEnv, deviceKeySize)
+	}
+	return key, nil
+}
+
+func (envStore) Save([]byte) error {
+	return ErrReadOnly
+}
+
+// fileStore keeps the device key in a file, base64 encoded. The raw keys written by earlier versions
+// in containers are read too.
+type fileStore struct {
+	path string
+}
+
+func (s fileStore) Name() string {
+	return "file " + s.path
+}
+
+func (s fileStore) Load() ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading from secret file: %w", err)
+	}
+	if key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data))); err == nil && len(key) == deviceKeySize {
+		return key, nil
+	}
+	if len(data) == deviceKeySize {
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s must contain a base64 encoded %d bytes key", s.path, deviceKeySize)
+}
+
+func (s fileStore) Save(key []byte) error {
+	return writeSecretFile(s.path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"))
+}
+
+// writeSecretFile replaces the file with data, readable only by the user
+func writeSecretFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("error writing to secret file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("error writing to secret file: %w", err)
+	}
+	return nil
+}
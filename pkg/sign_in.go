@@ -8,7 +8,6 @@ import (
 	eCrypto "github.com/ente-io/cli/internal/crypto"
 	"github.com/ente-io/cli/pkg/model"
 	"github.com/ente-io/cli/utils/encoding"
-	"log"
 
 	"github.com/kong/go-srp"
 )
@@ -43,7 +42,7 @@ func (c *ClICtrl) signInViaPassword(ctx context.Context, srpAttr *api.SRPAttribu
 		clientM := srpClient.ComputeM1()
 		authResp, err := c.Client.VerifySRPSession(ctx, srpAttr.SRPUserID, session.SessionID, encoding.EncodeBase64(clientM))
 		if err != nil {
-			log.Printf("failed to verify %v", err)
+			c.log(ctx).Warn("failed to verify", "error", err)
 			continue
 		}
 		return authResp, keyEncKey, nil
@@ -132,7 +131,7 @@ func (c *ClICtrl) validateTOTP(ctx context.Context, authResp *api.AuthorizationR
 		}
 		totpResp, err := c.Client.VerifyTotp(ctx, authResp.TwoFactorSessionID, totp)
 		if err != nil {
-			log.Printf("failed to verify %v", err)
+			c.log(ctx).Warn("failed to verify", "error", err)
 			continue
 		}
 		return totpResp, nil
@@ -152,7 +151,7 @@ func (c *ClICtrl) validateEmail(ctx context.Context, email string) (*api.Authori
 		}
 		authResponse, err := c.Client.VerifyEmail(ctx, email, ott)
 		if err != nil {
-			log.Printf("failed to verify %v", err)
+			c.log(ctx).Warn("failed to verify", "error", err)
 			continue
 		}
 		return authResponse, nil
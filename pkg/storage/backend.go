@@ -0,0 +1,58 @@
+// Package storage contains the backends an export can be written to.
+//
+// The sync logic only talks to the Backend interface, so adding a new destination
+// (SFTP, WebDAV, ...) only requires a new implementation of it.
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Entry describes a file or a directory present in a backend
+type Entry struct {
+	Name  string
+	IsDir bool
+	Size  int64
+}
+
+// Backend abstracts the destination of an export.
+// All paths are slash separated and relative to the root of the backend. Missing paths are reported
+// with an error satisfying errors.Is(err, fs.ErrNotExist).
+type Backend interface {
+	// List returns the entries directly inside the given directory. Use "" for the root.
+	List(ctx context.Context, dir string) ([]Entry, error)
+	Stat(ctx context.Context, path string) (*Entry, error)
+	MkdirAll(ctx context.Context, dir string) error
+	// Open returns the content of the file at the given path
+	Open(ctx context.Context, path string) (io.ReadCloser, error)
+	// Put atomically writes the content of the reader to the given path.
+	// Readers of the backend either see the previous file or the complete new one.
+	Put(ctx context.Context, path string, r io.Reader) error
+	// PutFile atomically moves the local file at srcPath to the given path.
+	// The local file is removed once it's stored.
+	PutFile(ctx context.Context, srcPath string, path string) error
+	// Rename moves a file or a directory
+	Rename(ctx context.Context, oldPath string, newPath string) error
+	// Remove deletes a file, ignoring files which do not exist
+	Remove(ctx context.Context, path string) error
+	// RemoveAll deletes a directory and everything inside it
+	RemoveAll(ctx context.Context, dir string) error
+	// WriteSidecar stores data as the json sidecar file at the given path
+	WriteSidecar(ctx context.Context, path string, data interface{}) error
+	// ReadSidecar decodes the json sidecar file at the given path into data
+	ReadSidecar(ctx context.Context, path string, data interface{}) error
+}
+
+// cleanPath normalises a backend path, "" is the root
+func cleanPath(p string) string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	return p
+}
+
+func notExist(op, p string) error {
+	return &fs.PathError{Op: op, Path: p, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type sidecar struct {
+	Title string `json:"title"`
+}
+
+// testBackend runs the same checks against every backend, so that they stay interchangeable
+func testBackend(t *testing.T, b Backend) {
+	ctx := context.Background()
+	if err := b.MkdirAll(ctx, "album/.meta"); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := b.Put(ctx, "album/a.jpg", strings.NewReader("content")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	srcPath := filepath.Join(t.TempDir(), "download")
+	if err := os.WriteFile(srcPath, []byte("video"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := b.PutFile(ctx, srcPath, "album/b.mp4"); err != nil {
+		t.Fatalf("PutFile failed: %v", err)
+	}
+	if _, err := os.Stat(srcPath); !os.IsNotExist(err) {
+		t.Fatalf("PutFile should remove the source file")
+	}
+	if err := b.WriteSidecar(ctx, "album/.meta/a.jpg.json", sidecar{Title: "a.jpg"}); err != nil {
+		t.Fatalf("WriteSidecar failed: %v", err)
+	}
+
+	entries, err := b.List(ctx, "album")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	names := make(map[string]bool)
+	for _, entry := range entries {
+		names[entry.Name] = entry.IsDir
+	}
+	if len(names) != 3 || !names[".meta"] || names["a.jpg"] || names["b.mp4"] {
+		t.Fatalf("Unexpected entries %v", entries)
+	}
+	stat, err := b.Stat(ctx, "album/a.jpg")
+	if err != nil || stat.Size != int64(len("content")) {
+		t.Fatalf("Unexpected stat %v, err %v", stat, err)
+	}
+	if _, err = b.Stat(ctx, "album/missing.jpg"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Expected fs.ErrNotExist, got %v", err)
+	}
+
+	if err = b.Rename(ctx, "album", "renamed"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	var meta sidecar
+	if err = b.ReadSidecar(ctx, "renamed/.meta/a.jpg.json", &meta); err != nil || meta.Title != "a.jpg" {
+		t.Fatalf("Unexpected sidecar %v, err %v", meta, err)
+	}
+	r, err := b.Open(ctx, "renamed/b.mp4")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(content) != "video" {
+		t.Fatalf("Unexpected content %q, err %v", content, err)
+	}
+
+	if err = b.Remove(ctx, "renamed/a.jpg"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err = b.Remove(ctx, "renamed/a.jpg"); err != nil {
+		t.Fatalf("Remove of a missing file should be ignored, got %v", err)
+	}
+	if err = b.RemoveAll(ctx, "renamed"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if entries, err = b.List(ctx, ""); err != nil || len(entries) != 0 {
+		t.Fatalf("Expected an empty root, got %v, err %v", entries, err)
+	}
+}
+
+func TestLocalBackend(t *testing.T) {
+	testBackend(t, NewLocal(t.TempDir()))
+}
+
+func TestMemoryBackend(t *testing.T) {
+	testBackend(t, NewMemory())
+}
+
+func TestEncryptedBackend(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemory()
+	key, err := SetupEncryption(ctx, inner, "passphrase")
+	if err != nil {
+		t.Fatalf("SetupEncryption failed: %v", err)
+	}
+	if _, err = SetupEncryption(ctx, inner, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Expected ErrWrongPassphrase, got %v", err)
+	}
+	b, err := NewEncrypted(ctx, inner, key)
+	if err != nil {
+		t.Fatalf("NewEncrypted failed: %v", err)
+	}
+	if err = b.MkdirAll(ctx, "album/.meta"); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err = b.Put(ctx, "album/a.jpg", strings.NewReader("secret content")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err = b.WriteSidecar(ctx, "album/.meta/a.jpg.json", sidecar{Title: "secret title"}); err != nil {
+		t.Fatalf("WriteSidecar failed: %v", err)
+	}
+	for p, content := range inner.Files() {
+		if strings.Contains(string(content), "secret") {
+			t.Fatalf("%s is stored in plain text", p)
+		}
+	}
+	r, err := b.Open(ctx, "album/a.jpg")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	content, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(content) != "secret content" {
+		t.Fatalf("Unexpected content %q, err %v", content, err)
+	}
+	var meta sidecar
+	if err = b.ReadSidecar(ctx, "album/.meta/a.jpg.json", &meta); err != nil || meta.Title != "secret title" {
+		t.Fatalf("Unexpected sidecar %v, err %v", meta, err)
+	}
+
+	for p := range inner.Files() {
+		if p != EncryptionParamsFile && (strings.Contains(p, "album") || strings.Contains(p, "a.jpg") || strings.Contains(p, ".meta")) {
+			t.Fatalf("%s has names in plain text", p)
+		}
+	}
+	entries, err := b.List(ctx, "album")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Unexpected entries %v, err %v", entries, err)
+	}
+	for _, entry := range entries {
+		if (entry.Name != ".meta" || !entry.IsDir) && (entry.Name != "a.jpg" || entry.IsDir) {
+			t.Fatalf("Unexpected entry %+v", entry)
+		}
+	}
+	if stat, err := b.Stat(ctx, "album/a.jpg"); err != nil || stat.Name != "a.jpg" {
+		t.Fatalf("Unexpected stat %v, err %v", stat, err)
+	}
+	if err = b.Rename(ctx, "album", "renamed"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err = b.ReadSidecar(ctx, "renamed/.meta/a.jpg.json", &meta); err != nil || meta.Title != "secret title" {
+		t.Fatalf("Unexpected sidecar after the rename %v, err %v", meta, err)
+	}
+	// files which aren't part of the export are skipped
+	if err = inner.Put(ctx, b.path("renamed")+"/.DS_Store", strings.NewReader("")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if entries, err = b.List(ctx, "renamed"); err != nil || len(entries) != 2 {
+		t.Fatalf("Unexpected entries %v, err %v", entries, err)
+	}
+	if err = b.RemoveAll(ctx, "renamed"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if entries, err = b.List(ctx, ""); err != nil || len(entries) != 1 || entries[0].Name != EncryptionParamsFile {
+		t.Fatalf("Expected only the encryption params at the root, got %v, err %v", entries, err)
+	}
+}
+
+func TestEncryptedBackendPlainNames(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemory()
+	key, err := SetupEncryption(ctx, inner, "passphrase")
+	if err != nil {
+		t.Fatalf("SetupEncryption failed: %v", err)
+	}
+	// an export created before the names were encrypted
+	params, err := ReadEncryptionParams(ctx, inner)
+	if err != nil {
+		t.Fatalf("ReadEncryptionParams failed: %v", err)
+	}
+	params.Version = plainNamesVersion
+	if err = inner.WriteSidecar(ctx, EncryptionParamsFile, params); err != nil {
+		t.Fatalf("WriteSidecar failed: %v", err)
+	}
+	b, err := NewEncrypted(ctx, inner, key)
+	if err != nil {
+		t.Fatalf("NewEncrypted failed: %v", err)
+	}
+	if err = b.MkdirAll(ctx, "album"); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err = b.Put(ctx, "album/a.jpg", strings.NewReader("secret content")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if _, ok := inner.Files()["album/a.jpg"]; !ok {
+		t.Fatalf("expected the names to be stored as is, got %v", inner.Files())
+	}
+}
+
+func TestNameCipher(t *testing.T) {
+	names := newNameCipher([]byte("0123456789abcdef0123456789abcdef"))
+	encName := names.encrypt("Trip to Paris.jpg")
+	if encName != names.encrypt("Trip to Paris.jpg") || encName == names.encrypt("Trip to Paris.JPG") {
+		t.Fatal("expected the encryption of the names to be deterministic")
+	}
+	if strings.ContainsAny(encName, "/\\.") {
+		t.Fatalf("%s isn't a valid file name", encName)
+	}
+	if name, err := names.decrypt(encName); err != nil || name != "Trip to Paris.jpg" {
+		t.Fatalf("Unexpected name %q, err %v", name, err)
+	}
+	tampered := []byte(encName)
+	tampered[len(tampered)-1] ^= 1
+	for _, invalid := range []string{string(tampered), ".DS_Store", "", encName[:10]} {
+		if _, err := names.decrypt(invalid); err == nil {
+			t.Errorf("expected an error decrypting %q", invalid)
+		}
+	}
+	other := newNameCipher([]byte("fedcba9876543210fedcba9876543210"))
+	if _, err := other.decrypt(encName); err == nil {
+		t.Error("expected an error decrypting with another key")
+	}
+}
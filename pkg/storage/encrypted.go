@@ -0,0 +1,307 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	eCrypto "github.com/ente-io/cli/internal/crypto"
+)
+
+const (
+	// EncryptionParamsFile is stored at the root of an encrypted export. It contains everything
+	// required to derive the export key from the passphrase.
+	EncryptionParamsFile = ".ente-encryption.json"
+
+	// encryptionVersion of the new exports, which also encrypt the names of the files and folders
+	encryptionVersion = 2
+	// plainNamesVersion is the version of the exports created before the names were encrypted
+	plainNamesVersion  = 1
+	encryptionMemLimit = 256 * 1024 * 1024
+	encryptionOpsLimit = 4
+	keyCheckValue      = "ente-encrypted-export"
+)
+
+var ErrWrongPassphrase = errors.New("incorrect passphrase for the encrypted export")
+
+// EncryptionParams are the key derivation parameters of an encrypted export. KeyCheck is a known value
+// encrypted with the derived key, used to verify the passphrase.
+type EncryptionParams struct {
+	Version       int    `json:"version"`
+	KDFSalt       string `json:"kdfSalt"`
+	MemLimit      int    `json:"memLimit"`
+	OpsLimit      int    `json:"opsLimit"`
+	KeyCheck      string `json:"keyCheck"`
+	KeyCheckNonce string `json:"keyCheckNonce"`
+}
+
+// encryptedSidecar wraps an encrypted sidecar, so that it can still be stored as json
+type encryptedSidecar struct {
+	Header string `json:"header"`
+	Data   string `json:"data"`
+}
+
+// Encrypted wraps another backend and encrypts the content of all files and sidecars stored in it
+// using secretstream. The names of the files and folders are encrypted too, except EncryptionParamsFile and
+// the names of the exports of plainNamesVersion, which are stored as is.
+type Encrypted struct {
+	Backend
+	key []byte
+	// names is nil when the names are stored as is
+	names *nameCipher
+}
+
+// ReadEncryptionParams returns the encryption params stored in the backend, or nil if it isn't encrypted
+func ReadEncryptionParams(ctx context.Context, b Backend) (*EncryptionParams, error) {
+	var params EncryptionParams
+	err := b.ReadSidecar(ctx, EncryptionParamsFile, &params)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if params.Version != encryptionVersion && params.Version != plainNamesVersion {
+		return nil, fmt.Errorf("unsupported encrypted export version %d", params.Version)
+	}
+	return &params, nil
+}
+
+// DeriveKey derives the export key from the passphrase and verifies it against the key check
+func (p *EncryptionParams) DeriveKey(passphrase string) ([]byte, error) {
+	key, err := eCrypto.DeriveArgonKey(passphrase, p.KDFSalt, p.MemLimit, p.OpsLimit)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.verifyKey(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (p *EncryptionParams) verifyKey(key []byte) error {
+	_, value, err := eCrypto.DecryptChaChaBase64(p.KeyCheck, key, p.KeyCheckNonce)
+	if err != nil || string(value) != keyCheckValue {
+		return ErrWrongPassphrase
+	}
+	return nil
+}
+
+// SetupEncryption returns the key for the export in the given backend. If the backend is already an
+// encrypted export, the passphrase must match it. Otherwise, new params are generated and stored,
+// which requires the backend to be empty.
+func SetupEncryption(ctx context.Context, b Backend, passphrase string) ([]byte, error) {
+	params, err := ReadEncryptionParams(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	if params != nil {
+		return params.DeriveKey(passphrase)
+	}
+	entries, err := b.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) > 0 {
+		return nil, errors.New("export already contains unencrypted files, choose an empty destination")
+	}
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+	params = &EncryptionParams{
+		Version:  encryptionVersion,
+		KDFSalt:  base64.StdEncoding.EncodeToString(salt),
+		MemLimit: encryptionMemLimit,
+		OpsLimit: encryptionOpsLimit,
+	}
+	key, err := eCrypto.DeriveArgonKey(passphrase, params.KDFSalt, params.MemLimit, params.OpsLimit)
+	if err != nil {
+		return nil, err
+	}
+	keyCheck, nonce, err := eCrypto.EncryptChaCha20poly1305([]byte(keyCheckValue), key)
+	if err != nil {
+		return nil, err
+	}
+	params.KeyCheck = base64.StdEncoding.EncodeToString(keyCheck)
+	params.KeyCheckNonce = base64.StdEncoding.EncodeToString(nonce)
+	if err = b.WriteSidecar(ctx, EncryptionParamsFile, params); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// NewEncrypted returns a backend which encrypts everything stored in b with the key.
+// The key is verified against the encryption params stored in b.
+func NewEncrypted(ctx context.Context, b Backend, key []byte) (*Encrypted, error) {
+	params, err := ReadEncryptionParams(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+	if params == nil {
+		return nil, fmt.Errorf("%s not found, the export is not encrypted", EncryptionParamsFile)
+	}
+	if err = params.verifyKey(key); err != nil {
+		return nil, err
+	}
+	e := &Encrypted{Backend: b, key: key}
+	if params.Version != plainNamesVersion {
+		e.names = newNameCipher(key)
+	}
+	return e, nil
+}
+
+// path returns the path in the wrapped backend
+func (e *Encrypted) path(p string) string {
+	if e.names == nil || cleanPath(p) == EncryptionParamsFile {
+		return p
+	}
+	return e.names.encryptPath(p)
+}
+
+// List returns the entries of the directory with their names decrypted. The entries which aren't part of the
+// export, like the temporary files of the backend, are skipped.
+func (e *Encrypted) List(ctx context.Context, dir string) ([]Entry, error) {
+	entries, err := e.Backend.List(ctx, e.path(dir))
+	if err != nil || e.names == nil {
+		return entries, err
+	}
+	result := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if cleanPath(dir) == "" && entry.Name == EncryptionParamsFile {
+			result = append(result, entry)
+			continue
+		}
+		if entry.Name, err = e.names.decrypt(entry.Name); err == nil {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+func (e *Encrypted) Stat(ctx context.Context, p string) (*Entry, error) {
+	entry, err := e.Backend.Stat(ctx, e.path(p))
+	if err != nil {
+		return nil, err
+	}
+	entry.Name = path.Base(cleanPath(p))
+	return entry, nil
+}
+
+func (e *Encrypted) MkdirAll(ctx context.Context, dir string) error {
+	return e.Backend.MkdirAll(ctx, e.path(dir))
+}
+
+func (e *Encrypted) Rename(ctx context.Context, oldPath string, newPath string) error {
+	return e.Backend.Rename(ctx, e.path(oldPath), e.path(newPath))
+}
+
+func (e *Encrypted) Remove(ctx context.Context, p string) error {
+	return e.Backend.Remove(ctx, e.path(p))
+}
+
+func (e *Encrypted) RemoveAll(ctx context.Context, dir string) error {
+	return e.Backend.RemoveAll(ctx, e.path(dir))
+}
+
+func (e *Encrypted) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	r, err := e.Backend.Open(ctx, e.path(p))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := eCrypto.NewStreamReader(r, e.key)
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to decrypt %s: %w", p, err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, r}, nil
+}
+
+func (e *Encrypted) Put(ctx context.Context, p string, r io.Reader) error {
+	pr, pw := io.Pipe()
+	go func() {
+		writer, err := eCrypto.NewStreamWriter(pw, e.key)
+		if err == nil {
+			if _, err = io.Copy(writer, r); err == nil {
+				err = writer.Close()
+			}
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	err := e.Backend.Put(ctx, e.path(p), pr)
+	// unblock the writer if the backend stopped reading early
+	_ = pr.CloseWithError(err)
+	return err
+}
+
+// PutFile encrypts the file next to the source before handing it over to the wrapped backend
+func (e *Encrypted) PutFile(ctx context.Context, srcPath string, p string) error {
+	encPath := srcPath + ".enc"
+	if err := e.encryptFile(srcPath, encPath); err != nil {
+		_ = os.Remove(encPath)
+		return err
+	}
+	if err := e.Backend.PutFile(ctx, encPath, e.path(p)); err != nil {
+		_ = os.Remove(encPath)
+		return err
+	}
+	return os.Remove(srcPath)
+}
+
+func (e *Encrypted) encryptFile(srcPath, encPath string) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(encPath)
+	if err != nil {
+		return err
+	}
+	writer, err := eCrypto.NewStreamWriter(dst, e.key)
+	if err == nil {
+		if _, err = io.Copy(writer, src); err == nil {
+			err = writer.Close()
+		}
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (e *Encrypted) WriteSidecar(ctx context.Context, p string, data interface{}) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	cipher, header, err := eCrypto.EncryptChaCha20poly1305(content, e.key)
+	if err != nil {
+		return err
+	}
+	return e.Backend.WriteSidecar(ctx, e.path(p), encryptedSidecar{
+		Header: base64.StdEncoding.EncodeToString(header),
+		Data:   base64.StdEncoding.EncodeToString(cipher),
+	})
+}
+
+func (e *Encrypted) ReadSidecar(ctx context.Context, p string, data interface{}) error {
+	var sidecar encryptedSidecar
+	if err := e.Backend.ReadSidecar(ctx, e.path(p), &sidecar); err != nil {
+		return err
+	}
+	_, content, err := eCrypto.DecryptChaChaBase64(sidecar.Data, e.key, sidecar.Header)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", p, err)
+	}
+	return json.Unmarshal(content, data)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/chacha20"
+)
+
+const nameTagSize = 16
+
+var errInvalidName = errors.New("not an encrypted name of the export")
+
+// nameCipher encrypts the file and folder names of an encrypted export. The encryption is deterministic, the
+// nonce is a MAC of the name like in SIV modes, so that paths can be looked up without an index. Each name is
+// encrypted on its own, a renamed folder keeps the encrypted names of its content.
+// An encrypted name is 4/3 of the length of the name plus 22 characters, so the names of up to about 170 bytes
+// fit in the 255 bytes limit of most filesystems.
+type nameCipher struct {
+	macKey []byte
+	encKey []byte
+}
+
+func newNameCipher(key []byte) *nameCipher {
+	return &nameCipher{macKey: nameSubKey(key, "ente-export-names-mac"), encKey: nameSubKey(key, "ente-export-names-enc")}
+}
+
+func nameSubKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// tag authenticates the name, it's also the nonce of its encryption
+func (n *nameCipher) tag(name []byte) []byte {
+	mac := hmac.New(sha256.New, n.macKey)
+	mac.Write(name)
+	return mac.Sum(nil)[:nameTagSize]
+}
+
+func (n *nameCipher) xor(tag, in []byte) []byte {
+	nonce := make([]byte, chacha20.NonceSizeX)
+	copy(nonce, tag)
+	c, err := chacha20.NewUnauthenticatedCipher(n.encKey, nonce)
+	if err != nil {
+		// the key and the nonce always have the expected sizes
+		panic(err)
+	}
+	out := make([]byte, len(in))
+	c.XORKeyStream(out, in)
+	return out
+}
+
+func (n *nameCipher) encrypt(name string) string {
+	tag := n.tag([]byte(name))
+	return base64.RawURLEncoding.EncodeToString(append(tag, n.xor(tag, []byte(name))...))
+}
+
+func (n *nameCipher) decrypt(encName string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encName)
+	if err != nil || len(data) < nameTagSize {
+		return "", errInvalidName
+	}
+	tag := data[:nameTagSize]
+	name := n.xor(tag, data[nameTagSize:])
+	if !hmac.Equal(tag, n.tag(name)) {
+		return "", errInvalidName
+	}
+	return string(name), nil
+}
+
+// encryptPath encrypts each name of the path
+func (n *nameCipher) encryptPath(p string) string {
+	p = cleanPath(p)
+	if p == "" {
+		return ""
+	}
+	names := strings.Split(p, "/")
+	for i, name := range names {
+		names[i] = n.encrypt(name)
+	}
+	return strings.Join(names, "/")
+}
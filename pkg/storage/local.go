@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Local stores the export in a directory on the local filesystem. This is the default backend.
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// Path returns the local filesystem path for the given backend path
+func (l *Local) Path(p string) string {
+	return filepath.Join(l.root, filepath.FromSlash(cleanPath(p)))
+}
+
+func (l *Local) List(_ context.Context, dir string) ([]Entry, error) {
+	entries, err := os.ReadDir(l.Path(dir))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, Entry{Name: entry.Name(), IsDir: entry.IsDir()})
+	}
+	return result, nil
+}
+
+func (l *Local) Stat(_ context.Context, p string) (*Entry, error) {
+	stat, err := os.Stat(l.Path(p))
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Name: stat.Name(), IsDir: stat.IsDir(), Size: stat.Size()}, nil
+}
+
+func (l *Local) MkdirAll(_ context.Context, dir string) error {
+	return os.MkdirAll(l.Path(dir), 0755)
+}
+
+func (l *Local) Open(_ context.Context, p string) (io.ReadCloser, error) {
+	return os.Open(l.Path(p))
+}
+
+// Put writes the content to a temporary file next to the destination and renames it into place
+func (l *Local) Put(_ context.Context, p string, r io.Reader) error {
+	destination := l.Path(p)
+	tmp, err := os.CreateTemp(filepath.Dir(destination), "."+filepath.Base(destination)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), destination)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func (l *Local) PutFile(ctx context.Context, srcPath string, p string) error {
+	if err := os.Rename(srcPath, l.Path(p)); err == nil {
+		return nil
+	}
+	// the source is on a different device, copy it instead
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	err = l.Put(ctx, p, src)
+	src.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(srcPath)
+}
+
+func (l *Local) Rename(_ context.Context, oldPath string, newPath string) error {
+	return os.Rename(l.Path(oldPath), l.Path(newPath))
+}
+
+func (l *Local) Remove(_ context.Context, p string) error {
+	err := os.Remove(l.Path(p))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) RemoveAll(_ context.Context, dir string) error {
+	return os.RemoveAll(l.Path(dir))
+}
+
+func (l *Local) WriteSidecar(ctx context.Context, p string, data interface{}) error {
+	content, err := marshalSidecar(data)
+	if err != nil {
+		return err
+	}
+	return l.Put(ctx, p, bytes.NewReader(content))
+}
+
+func (l *Local) ReadSidecar(_ context.Context, p string, data interface{}) error {
+	file, err := os.Open(l.Path(p))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewDecoder(file).Decode(data)
+}
+
+// marshalSidecar encodes data the same way for every backend, so that exports can be copied between them
+func marshalSidecar(data interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
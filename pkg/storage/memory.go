@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory keeps the export in memory. It mirrors the behaviour of Local and is meant for tests.
+type Memory struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+	dirs  map[string]bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		files: make(map[string][]byte),
+		dirs:  map[string]bool{"": true},
+	}
+}
+
+// Files returns a copy of all the files stored in the backend, keyed by their path
+func (m *Memory) Files() map[string][]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make(map[string][]byte, len(m.files))
+	for p, content := range m.files {
+		result[p] = append([]byte(nil), content...)
+	}
+	return result
+}
+
+func (m *Memory) List(_ context.Context, dir string) ([]Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	dir = cleanPath(dir)
+	if !m.dirs[dir] {
+		return nil, notExist("list", dir)
+	}
+	result := make([]Entry, 0)
+	for p := range m.dirs {
+		if p != "" && path.Dir("/"+p) == "/"+dir {
+			result = append(result, Entry{Name: path.Base(p), IsDir: true})
+		}
+	}
+	for p, content := range m.files {
+		if path.Dir("/"+p) == "/"+dir {
+			result = append(result, Entry{Name: path.Base(p), Size: int64(len(content))})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (m *Memory) Stat(_ context.Context, p string) (*Entry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	p = cleanPath(p)
+	if content, ok := m.files[p]; ok {
+		return &Entry{Name: path.Base(p), Size: int64(len(content))}, nil
+	}
+	if m.dirs[p] {
+		return &Entry{Name: path.Base(p), IsDir: true}, nil
+	}
+	return nil, notExist("stat", p)
+}
+
+func (m *Memory) MkdirAll(_ context.Context, dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dir = cleanPath(dir); dir != ""; dir = cleanPath(path.Dir(dir)) {
+		if _, ok := m.files[dir]; ok {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: os.ErrExist}
+		}
+		m.dirs[dir] = true
+	}
+	return nil
+}
+
+func (m *Memory) Open(_ context.Context, p string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	content, ok := m.files[cleanPath(p)]
+	if !ok {
+		return nil, notExist("open", p)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (m *Memory) Put(_ context.Context, p string, r io.Reader) error {
+	// read everything before taking the lock, the file becomes visible all at once
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p = cleanPath(p)
+	if !m.dirs[cleanPath(path.Dir(p))] {
+		return notExist("put", p)
+	}
+	m.files[p] = content
+	return nil
+}
+
+func (m *Memory) PutFile(ctx context.Context, srcPath string, p string) error {
+	content, err := os.ReadFile(srcPath)
+	if err != nil {
+		return err
+	}
+	if err = m.Put(ctx, p, bytes.NewReader(content)); err != nil {
+		return err
+	}
+	return os.Remove(srcPath)
+}
+
+func (m *Memory) Rename(_ context.Context, oldPath string, newPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldPath, newPath = cleanPath(oldPath), cleanPath(newPath)
+	if !m.dirs[cleanPath(path.Dir(newPath))] {
+		return notExist("rename", newPath)
+	}
+	if content, ok := m.files[oldPath]; ok {
+		delete(m.files, oldPath)
+		m.files[newPath] = content
+		return nil
+	}
+	if !m.dirs[oldPath] {
+		return notExist("rename", oldPath)
+	}
+	oldPrefix := oldPath + "/"
+	for p := range m.dirs {
+		if p == oldPath || strings.HasPrefix(p, oldPrefix) {
+			delete(m.dirs, p)
+			m.dirs[newPath+strings.TrimPrefix(p, oldPath)] = true
+		}
+	}
+	for p, content := range m.files {
+		if strings.HasPrefix(p, oldPrefix) {
+			delete(m.files, p)
+			m.files[newPath+strings.TrimPrefix(p, oldPath)] = content
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Remove(_ context.Context, p string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, cleanPath(p))
+	return nil
+}
+
+func (m *Memory) RemoveAll(_ context.Context, dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir = cleanPath(dir)
+	prefix := dir + "/"
+	for p := range m.dirs {
+		if p != "" && (p == dir || strings.HasPrefix(p, prefix)) {
+			delete(m.dirs, p)
+		}
+	}
+	for p := range m.files {
+		if p == dir || strings.HasPrefix(p, prefix) {
+			delete(m.files, p)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) WriteSidecar(ctx context.Context, p string, data interface{}) error {
+	content, err := marshalSidecar(data)
+	if err != nil {
+		return err
+	}
+	return m.Put(ctx, p, bytes.NewReader(content))
+}
+
+func (m *Memory) ReadSidecar(ctx context.Context, p string, data interface{}) error {
+	r, err := m.Open(ctx, p)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return json.NewDecoder(r).Decode(data)
+}
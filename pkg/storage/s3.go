@@ -0,0 +1,353 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ente-io/cli/internal/logging"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+const (
+	s3PartSize   = 16 * 1024 * 1024
+	jsonMimeType = "application/json"
+)
+
+// S3Options are the settings required to connect to an S3 compatible bucket
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	Insecure  bool
+	// Transport of the connections to the endpoint, the default one of minio when it's nil
+	Transport http.RoundTripper
+}
+
+// Index persists the state of the uploaded objects, so that incremental exports
+// don't need to list the bucket or download the sidecar files.
+type Index interface {
+	// Get returns nil if the key doesn't exist
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+	// Scan calls fn for all keys with the given prefix, in order. Returning ErrStopScan from fn stops the scan.
+	Scan(ctx context.Context, prefix string, fn func(key string, value []byte) error) error
+}
+
+// ErrStopScan can be returned from the Index.Scan callback to stop the iteration without an error
+var ErrStopScan = errors.New("stop scan")
+
+// s3IndexEntry is the state of an uploaded object
+type s3IndexEntry struct {
+	Size int64 `json:"size"`
+	// Content of the json sidecar files
+	Content json.RawMessage `json:"content,omitempty"`
+}
+
+// S3 stores the export in an S3 compatible bucket. Objects use the same layout as
+// the local export, with the optional prefix prepended to their keys.
+type S3 struct {
+	client *minio.Client
+	index  Index
+	bucket string
+	prefix string
+}
+
+// NewS3Client returns a client for the configured endpoint after verifying that the bucket exists
+func NewS3Client(ctx context.Context, opts S3Options) (*minio.Client, error) {
+	client, err := minio.New(opts.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure:    !opts.Insecure,
+		Region:    opts.Region,
+		Transport: opts.Transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+	exists, err := client.BucketExists(ctx, opts.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check bucket %s: %w", opts.Bucket, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("bucket %s does not exist", opts.Bucket)
+	}
+	return client, nil
+}
+
+// NewS3 connects to the bucket and populates the index from it if the index is empty
+func NewS3(ctx context.Context, opts S3Options, index Index) (*S3, error) {
+	client, err := NewS3Client(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &S3{
+		client: client,
+		index:  index,
+		bucket: opts.Bucket,
+		prefix: strings.Trim(opts.Prefix, "/"),
+	}
+	if err = s.rebuildIndexIfEmpty(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *S3) objectKey(p string) string {
+	return path.Join(s.prefix, cleanPath(p))
+}
+
+// dirPrefix returns the index prefix for the entries inside the given directory
+func dirPrefix(dir string) string {
+	dir = cleanPath(dir)
+	if dir == "" {
+		return ""
+	}
+	return dir + "/"
+}
+
+// rebuildIndexIfEmpty populates the index from the bucket. This is required when the
+// local database was reset or the bucket was already used for an export by another machine.
+func (s *S3) rebuildIndexIfEmpty(ctx context.Context) error {
+	isEmpty := true
+	err := s.index.Scan(ctx, "", func(_ string, _ []byte) error {
+		isEmpty = false
+		return ErrStopScan
+	})
+	if err != nil || !isEmpty {
+		return err
+	}
+	listPrefix := dirPrefix(s.prefix)
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: listPrefix, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		relPath := strings.TrimPrefix(object.Key, listPrefix)
+		entry := s3IndexEntry{Size: object.Size}
+		if strings.HasSuffix(relPath, ".json") {
+			content, readErr := s.getObject(ctx, relPath)
+			if readErr != nil {
+				return readErr
+			}
+			entry.Content = content
+		}
+		if err = s.putIndex(ctx, relPath, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3) getObject(ctx context.Context, p string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.objectKey(p), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+	return io.ReadAll(object)
+}
+
+func (s *S3) putIndex(ctx context.Context, p string, entry s3IndexEntry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.index.Put(ctx, cleanPath(p), value)
+}
+
+func (s *S3) getIndex(ctx context.Context, p string) (*s3IndexEntry, error) {
+	value, err := s.index.Get(ctx, cleanPath(p))
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, notExist("stat", p)
+	}
+	var entry s3IndexEntry
+	if err = json.Unmarshal(value, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// listIndex returns all the index entries inside the given directory, recursively
+func (s *S3) listIndex(ctx context.Context, dir string) (map[string]s3IndexEntry, error) {
+	result := make(map[string]s3IndexEntry)
+	err := s.index.Scan(ctx, dirPrefix(dir), func(key string, value []byte) error {
+		var entry s3IndexEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return err
+		}
+		result[key] = entry
+		return nil
+	})
+	return result, err
+}
+
+func (s *S3) List(ctx context.Context, dir string) ([]Entry, error) {
+	entries, err := s.listIndex(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := dirPrefix(dir)
+	seen := make(map[string]bool)
+	result := make([]Entry, 0)
+	for key, entry := range entries {
+		name, rest, isDir := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if isDir && rest != "" {
+			result = append(result, Entry{Name: name, IsDir: true})
+		} else {
+			result = append(result, Entry{Name: name, Size: entry.Size})
+		}
+	}
+	return result, nil
+}
+
+func (s *S3) Stat(ctx context.Context, p string) (*Entry, error) {
+	entry, err := s.getIndex(ctx, p)
+	if err == nil {
+		return &Entry{Name: path.Base(p), Size: entry.Size}, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	// directories only exist implicitly as the prefix of other objects
+	entries, err := s.listIndex(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, notExist("stat", p)
+	}
+	return &Entry{Name: path.Base(p), IsDir: true}, nil
+}
+
+func (s *S3) MkdirAll(_ context.Context, _ string) error {
+	// S3 has no directories, they are created implicitly by the objects inside them
+	return nil
+}
+
+func (s *S3) Open(ctx context.Context, p string) (io.ReadCloser, error) {
+	if _, err := s.getIndex(ctx, p); err != nil {
+		return nil, err
+	}
+	return s.client.GetObject(ctx, s.bucket, s.objectKey(p), minio.GetObjectOptions{})
+}
+
+// Put uploads the content as a single object, S3 objects only become visible once the upload completes
+func (s *S3) Put(ctx context.Context, p string, r io.Reader) error {
+	info, err := s.client.PutObject(ctx, s.bucket, s.objectKey(p), r, -1, minio.PutObjectOptions{PartSize: s3PartSize})
+	if err != nil {
+		return err
+	}
+	return s.putIndex(ctx, p, s3IndexEntry{Size: info.Size})
+}
+
+func (s *S3) PutFile(ctx context.Context, srcPath string, p string) error {
+	info, err := s.client.FPutObject(ctx, s.bucket, s.objectKey(p), srcPath, minio.PutObjectOptions{PartSize: s3PartSize})
+	if err != nil {
+		return err
+	}
+	if err = s.putIndex(ctx, p, s3IndexEntry{Size: info.Size}); err != nil {
+		return err
+	}
+	return os.Remove(srcPath)
+}
+
+func (s *S3) WriteSidecar(ctx context.Context, p string, data interface{}) error {
+	content, err := marshalSidecar(data)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, s.objectKey(p), bytes.NewReader(content), int64(len(content)),
+		minio.PutObjectOptions{ContentType: jsonMimeType})
+	if err != nil {
+		return err
+	}
+	return s.putIndex(ctx, p, s3IndexEntry{Size: int64(len(content)), Content: content})
+}
+
+func (s *S3) ReadSidecar(ctx context.Context, p string, data interface{}) error {
+	entry, err := s.getIndex(ctx, p)
+	if err != nil {
+		return err
+	}
+	content := []byte(entry.Content)
+	if content == nil {
+		if content, err = s.getObject(ctx, p); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(content, data)
+}
+
+// Rename copies the objects to the new keys and removes the old ones, as S3 doesn't support renames
+func (s *S3) Rename(ctx context.Context, oldPath string, newPath string) error {
+	oldPath, newPath = cleanPath(oldPath), cleanPath(newPath)
+	entries := make(map[string]s3IndexEntry)
+	if entry, err := s.getIndex(ctx, oldPath); err == nil {
+		entries[oldPath] = *entry
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	} else {
+		if entries, err = s.listIndex(ctx, oldPath); err != nil {
+			return err
+		}
+	}
+	if len(entries) == 0 {
+		return notExist("rename", oldPath)
+	}
+	for key, entry := range entries {
+		newKey := newPath + strings.TrimPrefix(key, oldPath)
+		_, err := s.client.ComposeObject(ctx,
+			minio.CopyDestOptions{Bucket: s.bucket, Object: s.objectKey(newKey)},
+			minio.CopySrcOptions{Bucket: s.bucket, Object: s.objectKey(key)})
+		if err != nil {
+			return fmt.Errorf("failed to copy %s to %s: %w", key, newKey, err)
+		}
+		if err = s.putIndex(ctx, newKey, entry); err != nil {
+			return err
+		}
+		if err = s.Remove(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3) Remove(ctx context.Context, p string) error {
+	err := s.client.RemoveObject(ctx, s.bucket, s.objectKey(p), minio.RemoveObjectOptions{})
+	if err != nil {
+		return err
+	}
+	return s.index.Delete(ctx, cleanPath(p))
+}
+
+func (s *S3) RemoveAll(ctx context.Context, dir string) error {
+	entries, err := s.listIndex(ctx, dir)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx, nil).Info("removing objects", "count", len(entries), "dir", dir)
+	for key := range entries {
+		if err = s.Remove(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
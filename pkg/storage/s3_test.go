@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// memoryIndex is an Index kept in memory, in place of the store of the cli database
+type memoryIndex struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func newMemoryIndex() *memoryIndex {
+	return &memoryIndex{entries: make(map[string][]byte)}
+}
+
+func (m *memoryIndex) Get(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entries[key], nil
+}
+
+func (m *memoryIndex) Put(_ context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = value
+	return nil
+}
+
+func (m *memoryIndex) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *memoryIndex) Scan(_ context.Context, prefix string, fn func(key string, value []byte) error) error {
+	m.mu.Lock()
+	keys := make([]string, 0, len(m.entries))
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = m.entries[key]
+	}
+	m.mu.Unlock()
+	for i, key := range keys {
+		if err := fn(key, values[i]); err != nil {
+			if err == ErrStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// fakeS3 implements the part of the S3 API used by the S3 backend, for a single bucket
+type fakeS3 struct {
+	bucket string
+	mu     sync.Mutex
+	// objects by key
+	objects map[string][]byte
+	// uploads are the parts of the multipart uploads in progress, by upload id
+	uploads map[string]map[int][]byte
+	// multipartUploads counts the completed multipart uploads, with their number of parts
+	multipartUploads []int
+}
+
+type fakeS3Object struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified string
+}
+
+type fakeS3ListResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string
+	Prefix      string
+	KeyCount    int
+	MaxKeys     int
+	IsTruncated bool
+	Contents    []fakeS3Object
+}
+
+var fakeS3ModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func etag(content []byte) string {
+	sum := md5.Sum(content)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.writeError(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	query := r.URL.Query()
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, query.Get("prefix"))
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		content, ok := f.objects[key]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("ETag", etag(content))
+		w.Header().Set("Last-Modified", fakeS3ModTime.Format(http.TimeFormat))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(content)
+		}
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		uploadID := strconv.Itoa(len(f.uploads) + len(f.multipartUploads) + 1)
+		f.uploads[uploadID] = make(map[int][]byte)
+		f.writeXML(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: uploadID})
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		partNumber, _ := strconv.Atoi(query.Get("partNumber"))
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			// ComposeObject copies the objects with a multipart upload
+			content, ok := f.objects[f.sourceKey(source)]
+			if !ok {
+				f.writeError(w, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			parts[partNumber] = content
+			f.writeXML(w, struct {
+				XMLName      xml.Name `xml:"CopyPartResult"`
+				ETag         string
+				LastModified string
+			}{ETag: etag(content), LastModified: fakeS3ModTime.Format(time.RFC3339)})
+			return
+		}
+		content, _ := io.ReadAll(r.Body)
+		parts[partNumber] = content
+		w.Header().Set("ETag", etag(content))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "NoSuchUpload")
+			return
+		}
+		var content []byte
+		for i := 1; i <= len(parts); i++ {
+			content = append(content, parts[i]...)
+		}
+		f.objects[key] = content
+		f.multipartUploads = append(f.multipartUploads, len(parts))
+		delete(f.uploads, query.Get("uploadId"))
+		f.writeXML(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: etag(content)})
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		content, ok := f.objects[f.sourceKey(r.Header.Get("X-Amz-Copy-Source"))]
+		if !ok {
+			f.writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		f.objects[key] = content
+		f.writeXML(w, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			ETag         string
+			LastModified string
+		}{ETag: etag(content), LastModified: fakeS3ModTime.Format(time.RFC3339)})
+	case r.Method == http.MethodPut:
+		content, _ := io.ReadAll(r.Body)
+		f.objects[key] = content
+		w.Header().Set("ETag", etag(content))
+	default:
+		f.writeError(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	result := fakeS3ListResult{Name: f.bucket, Prefix: prefix, MaxKeys: 1000}
+	for key, content := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, fakeS3Object{Key: key, Size: int64(len(content)),
+				ETag: etag(content), LastModified: fakeS3ModTime.Format(time.RFC3339)})
+		}
+	}
+	sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+	result.KeyCount = len(result.Contents)
+	f.writeXML(w, result)
+}
+
+func (f *fakeS3) writeXML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeS3) writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_ = xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+	}{Code: code})
+}
+
+// sourceKey returns the object key of an x-amz-copy-source header
+func (f *fakeS3) sourceKey(header string) string {
+	source, _ := url.PathUnescape(header)
+	_, key, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	return key
+}
+
+// newTestS3Options returns the options of an empty bucket prefix. The tests use an S3 API fake, unless
+// ENTE_TEST_S3_ENDPOINT, ENTE_TEST_S3_BUCKET, ENTE_TEST_S3_ACCESS_KEY and ENTE_TEST_S3_SECRET_KEY are set,
+// e.g. to run them against MinIO. The fake is returned when it's used.
+func newTestS3Options(t *testing.T) (S3Options, *fakeS3) {
+	if endpoint := os.Getenv("ENTE_TEST_S3_ENDPOINT"); endpoint != "" {
+		return S3Options{
+			Endpoint:  endpoint,
+			Region:    os.Getenv("ENTE_TEST_S3_REGION"),
+			Bucket:    os.Getenv("ENTE_TEST_S3_BUCKET"),
+			Prefix:    fmt.Sprintf("ente-cli-test/%s-%d", t.Name(), time.Now().UnixNano()),
+			AccessKey: os.Getenv("ENTE_TEST_S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("ENTE_TEST_S3_SECRET_KEY"),
+			Insecure:  os.Getenv("ENTE_TEST_S3_INSECURE") == "true",
+		}, nil
+	}
+	fake := &fakeS3{bucket: "ente", objects: make(map[string][]byte), uploads: make(map[string]map[int][]byte)}
+	// over https, the requests aren't signed in chunks, which keeps the fake simple
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+	return S3Options{
+		Endpoint:  strings.TrimPrefix(server.URL, "https://"),
+		Region:    "us-east-1",
+		Bucket:    fake.bucket,
+		Prefix:    "export",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Transport: server.Client().Transport,
+	}, fake
+}
+
+func newTestS3(t *testing.T, opts S3Options, index Index) *S3 {
+	s, err := NewS3(context.Background(), opts, index)
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+	return s
+}
+
+// objectKeys lists the keys of the objects below the prefix of the options, with the S3 API
+func objectKeys(t *testing.T, s *S3) []string {
+	var keys []string
+	for object := range s.client.ListObjects(context.Background(), s.bucket,
+		minio.ListObjectsOptions{Prefix: dirPrefix(s.prefix), Recursive: true}) {
+		if object.Err != nil {
+			t.Fatalf("ListObjects failed: %v", object.Err)
+		}
+		keys = append(keys, object.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestS3Backend(t *testing.T) {
+	opts, _ := newTestS3Options(t)
+	s := newTestS3(t, opts, newMemoryIndex())
+	testBackend(t, s)
+	if keys := objectKeys(t, s); len(keys) != 0 {
+		t.Errorf("expected all the objects to be removed, got %v", keys)
+	}
+}
+
+func TestS3MissingBucket(t *testing.T) {
+	opts, fake := newTestS3Options(t)
+	if fake == nil {
+		t.Skip("only with the fake")
+	}
+	opts.Bucket = "missing"
+	if _, err := NewS3(context.Background(), opts, newMemoryIndex()); err == nil {
+		t.Error("expected an error for a missing bucket")
+	}
+}
+
+func TestS3MultipartPut(t *testing.T) {
+	opts, fake := newTestS3Options(t)
+	s := newTestS3(t, opts, newMemoryIndex())
+	ctx := context.Background()
+	content := bytes.Repeat([]byte("0123456789"), s3PartSize/10+10)
+	if err := s.Put(ctx, "album/large.mp4", bytes.NewReader(content)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if fake != nil && (len(fake.multipartUploads) != 1 || fake.multipartUploads[0] != 2) {
+		t.Errorf("expected a multipart upload in 2 parts, got %v", fake.multipartUploads)
+	}
+	stat, err := s.Stat(ctx, "album/large.mp4")
+	if err != nil || stat.Size != int64(len(content)) {
+		t.Fatalf("Unexpected stat %v, err %v", stat, err)
+	}
+	r, err := s.Open(ctx, "album/large.mp4")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer r.Close()
+	if read, err := io.ReadAll(r); err != nil || !bytes.Equal(read, content) {
+		t.Fatalf("Unexpected content of %d bytes, err %v", len(read), err)
+	}
+}
+
+func TestS3RebuildIndex(t *testing.T) {
+	opts, _ := newTestS3Options(t)
+	ctx := context.Background()
+	first := newTestS3(t, opts, newMemoryIndex())
+	if err := first.Put(ctx, "album/a.jpg", strings.NewReader("content")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := first.WriteSidecar(ctx, "album/.meta/a.jpg.json", sidecar{Title: "a.jpg"}); err != nil {
+		t.Fatalf("WriteSidecar failed: %v", err)
+	}
+	// an object next to the prefix isn't part of the export
+	if _, err := first.client.PutObject(ctx, opts.Bucket, opts.Prefix+"-other/b.jpg", strings.NewReader("other"), 5,
+		minio.PutObjectOptions{}); err != nil {
+		t.Fatalf("PutObject failed: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = first.client.RemoveObject(ctx, opts.Bucket, opts.Prefix+"-other/b.jpg", minio.RemoveObjectOptions{})
+	})
+
+	// like an export from another machine, or after the cli database was reset
+	index := newMemoryIndex()
+	second := newTestS3(t, opts, index)
+	entries, err := second.List(ctx, "")
+	if err != nil || len(entries) != 1 || entries[0].Name != "album" || !entries[0].IsDir {
+		t.Fatalf("Unexpected root entries %v, err %v", entries, err)
+	}
+	stat, err := second.Stat(ctx, "album/a.jpg")
+	if err != nil || stat.Size != int64(len("content")) {
+		t.Fatalf("Unexpected stat %v, err %v", stat, err)
+	}
+	var meta sidecar
+	if err = second.ReadSidecar(ctx, "album/.meta/a.jpg.json", &meta); err != nil || meta.Title != "a.jpg" {
+		t.Fatalf("Unexpected sidecar %v, err %v", meta, err)
+	}
+	if entry, _ := index.Get(ctx, "album/.meta/a.jpg.json"); !bytes.Contains(entry, []byte(`"content"`)) {
+		t.Errorf("expected the content of the sidecar in the index, got %s", entry)
+	}
+
+	// an index which isn't empty is kept as it is
+	if err = index.Delete(ctx, "album/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	third := newTestS3(t, opts, index)
+	if _, err = third.Stat(ctx, "album/a.jpg"); err == nil {
+		t.Error("expected the index not to be rebuilt")
+	}
+	if err = first.RemoveAll(ctx, ""); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+}
+
+func TestS3RenameThenRemove(t *testing.T) {
+	opts, _ := newTestS3Options(t)
+	ctx := context.Background()
+	index := newMemoryIndex()
+	s := newTestS3(t, opts, index)
+	if err := s.Put(ctx, "album/a.jpg", strings.NewReader("content")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.WriteSidecar(ctx, "album/.meta/a.jpg.json", sidecar{Title: "a.jpg"}); err != nil {
+		t.Fatalf("WriteSidecar failed: %v", err)
+	}
+	if err := s.Rename(ctx, "album", "renamed"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	prefix := dirPrefix(s.prefix)
+	want := []string{prefix + "renamed/.meta/a.jpg.json", prefix + "renamed/a.jpg"}
+	if keys := objectKeys(t, s); strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected the objects to be moved to %v, got %v", want, keys)
+	}
+	if err := s.Rename(ctx, "album", "again"); err == nil {
+		t.Error("expected an error when renaming a missing directory")
+	}
+
+	if err := s.Remove(ctx, "renamed/a.jpg"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := s.RemoveAll(ctx, "renamed"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if keys := objectKeys(t, s); len(keys) != 0 {
+		t.Errorf("expected no objects left, got %v", keys)
+	}
+	if len(index.entries) != 0 {
+		t.Errorf("expected an empty index, got %v", index.entries)
+	}
+}
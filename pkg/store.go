@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/ente-io/cli/pkg/model"
-	"log"
 	"strconv"
 	"time"
 
@@ -12,11 +11,7 @@ import (
 )
 
 func GetDB(path string) (*bolt.DB, error) {
-	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
-	if err != nil {
-		log.Fatal(err)
-	}
-	return db, err
+	return bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
 }
 
 func (c *ClICtrl) GetInt64ConfigValue(ctx context.Context, key string) (int64, error) {
@@ -3,101 +3,225 @@ package pkg
 import (
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"github.com/ente-io/cli/internal"
 	"github.com/ente-io/cli/internal/api"
+	"github.com/ente-io/cli/internal/bandwidth"
+	"github.com/ente-io/cli/internal/hooks"
+	"github.com/ente-io/cli/internal/logging"
+	"github.com/ente-io/cli/internal/metrics"
+	"github.com/ente-io/cli/internal/progress"
 	"github.com/ente-io/cli/pkg/model"
 	bolt "go.etcd.io/bbolt"
-	"log"
 	"time"
 )
 
-func (c *ClICtrl) Export() error {
-	accounts, err := c.GetAccounts(context.Background())
+// syncRetryPolicy restarts the sync of an account when it fails after the retries of the single requests,
+// e.g. while the network is down. The files which were already exported aren't downloaded again.
+var syncRetryPolicy = api.RetryPolicy{MaxRetries: 10, BaseDelay: 30 * time.Second, MaxDelay: 10 * time.Minute}
+
+// Export syncs all the configured photos accounts to their export destination and returns the outcome
+// for each account. Progress is logged and reported to the progress tracker of the context, if any,
+// the caller decides how to present the result. Cancelling the context stops the export after the file
+// being synced, the accounts which weren't synced yet are left out of the result. An account which fails
+// doesn't stop the export of the next ones, the returned error combines the failures of all the accounts.
+func (c *ClICtrl) Export(ctx context.Context) (*model.ExportResult, error) {
+	accounts, err := c.GetAccounts(ctx)
 	if err != nil {
-		return err
-	}
-	if len(accounts) == 0 {
-		fmt.Printf("No accounts to sync\n Add account using `account add` cmd\n")
-		return nil
+		return nil, err
 	}
+	result := &model.ExportResult{Accounts: make([]*model.AccountExportResult, 0)}
+	var failures []error
 	for _, account := range accounts {
-		log.SetPrefix(fmt.Sprintf("[%s-%s] ", account.App, account.Email))
-		if account.ExportDir == "" {
-			log.Printf("Skip account %s: no export directory configured", account.Email)
-			continue
+		if err = ctx.Err(); err != nil {
+			return result, err
 		}
-		_, err = internal.ValidateDirForWrite(account.ExportDir)
+		logger := c.log(ctx).With("app", account.App, "email", account.Email)
+		settings, err := c.accountSettings(account, accounts)
 		if err != nil {
-			log.Printf("Skip export, error: %v while validing exportDir %s\n", err, account.ExportDir)
+			logger.Error("skip account", "error", err)
+			result.Accounts = append(result.Accounts, &model.AccountExportResult{Email: account.Email, App: account.App,
+				Status: model.ExportStatusFailed, Reason: err.Error(), Albums: make([]*model.AlbumExportResult, 0)})
+			failures = append(failures, err)
 			continue
 		}
-		if account.App == api.AppAuth {
-			log.Printf("Skip account %s: auth export is not supported", account.Email)
+		if settings.Skip {
+			logger.Info("skip account, it's excluded in the config file")
 			continue
 		}
-		log.Println("start sync")
+		if settings.Bandwidth != nil {
+			account.Bandwidth = settings.Bandwidth
+		}
+		accResult := &model.AccountExportResult{Email: account.Email, App: account.App, Albums: make([]*model.AlbumExportResult, 0)}
+		result.Accounts = append(result.Accounts, accResult)
+		if reason := skipExportReason(account); reason != "" {
+			logger.Warn("skip account", "reason", reason)
+			accResult.Status = model.ExportStatusSkipped
+			accResult.Reason = reason
+			continue
+		}
+		logger.Info("start sync")
+		hooks.FromContext(ctx).Fire(hooks.Event{Type: hooks.SyncStarted, Account: account.Email, App: string(account.App)})
 		retryCount := 0
 		for {
-			err = c.SyncAccount(account)
+			err = c.SyncAccount(ctx, account, accResult)
+			accResult.UpdateTotals()
 			if err != nil {
-				if model.ShouldRetrySync(err) && retryCount < 20 {
+				if ctx.Err() == nil && model.ShouldRetrySync(err) && retryCount < syncRetryPolicy.MaxRetries {
 					retryCount = retryCount + 1
-					timeInSecond := time.Duration(retryCount*10) * time.Second
-					log.Printf("Connection err, waiting for %s before trying again", timeInSecond.String())
-					time.Sleep(timeInSecond)
+					timeInSecond := syncRetryPolicy.Delay(retryCount, err)
+					logger.Warn("sync failed, waiting before trying again", "wait", timeInSecond, "kind", api.ClassifyError(err), "error", err)
+					metrics.Retries.WithLabelValues("sync").Inc()
+					select {
+					case <-ctx.Done():
+					case <-time.After(timeInSecond):
+					}
 					continue
 				}
-				fmt.Printf("Error syncing account %s: %s\n", account.Email, err)
-				return err
+				if ctx.Err() != nil {
+					logger.Warn("sync interrupted", "downloaded", accResult.Downloaded, "removed", accResult.Removed)
+					accResult.Status = model.ExportStatusInterrupted
+					fireSyncFinished(ctx, accResult)
+					return result, ctx.Err()
+				}
+				logger.Error("sync failed", "error", err)
+				accResult.Status = model.ExportStatusFailed
+				accResult.Reason = err.Error()
+				fireSyncFinished(ctx, accResult)
+				failures = append(failures, fmt.Errorf("export of %s (%s) failed: %w", account.Email, account.App, err))
+				break
 			} else {
-				log.Println("sync done")
+				logger.Info("sync done", "downloaded", accResult.Downloaded, "removed", accResult.Removed, "failed", accResult.Failed)
+				accResult.Status = model.ExportStatusSynced
+				metrics.LastSuccess.WithLabelValues(account.AccountKey()).SetToCurrentTime()
+				fireSyncFinished(ctx, accResult)
 				break
 			}
 		}
+	}
+	return result, errors.Join(failures...)
+}
+
+// fireSyncFinished sends the outcome of the sync of the account to the hooks
+func fireSyncFinished(ctx context.Context, result *model.AccountExportResult) {
+	hooks.FromContext(ctx).Fire(hooks.Event{
+		Type:    hooks.SyncFinished,
+		Account: result.Email,
+		App:     string(result.App),
+		Status:  result.Status,
+		Reason:  result.Reason,
+		Result:  result.ExportCounts,
+	})
+}
 
+// fireHook sends the event to the hooks, for the account of the context
+func fireHook(ctx context.Context, event hooks.Event) {
+	event.Account, _ = ctx.Value("email").(string)
+	event.App, _ = ctx.Value("app").(string)
+	hooks.FromContext(ctx).Fire(event)
+}
+
+// skipExportReason returns why the account can't be exported, or an empty string if it can
+func skipExportReason(account model.Account) string {
+	if account.S3 == nil {
+		if account.ExportDir == "" {
+			return "no export directory configured"
+		}
+		if _, err := internal.ValidateDirForWrite(account.ExportDir); err != nil {
+			return fmt.Sprintf("error: %v while validating exportDir %s", err, account.ExportDir)
+		}
 	}
-	return nil
+	if account.App == api.AppAuth {
+		return "auth export is not supported"
+	}
+	return ""
 }
 
-func (c *ClICtrl) SyncAccount(account model.Account) error {
-	secretInfo, err := c.KeyHolder.LoadSecrets(account)
+// SyncAccount exports the account once, adding the per-album counts to result
+func (c *ClICtrl) SyncAccount(ctx context.Context, account model.Account, result *model.AccountExportResult) error {
+	progress.FromContext(ctx).StartAccount(account.Email)
+	ctx, err := c.loadAccount(ctx, account)
 	if err != nil {
 		return err
 	}
-	ctx := c.buildRequestContext(context.Background(), account)
-	err = createDataBuckets(c.DB, account)
+	if account.Bandwidth != nil {
+		limiter, err := newBandwidthLimiter(*account.Bandwidth)
+		if err != nil {
+			return err
+		}
+		ctx = bandwidth.NewContext(ctx, limiter)
+	}
+	target, err := c.getExportBackend(ctx, account)
 	if err != nil {
+		c.log(ctx).Error("failed to initialize export backend", "error", err)
 		return err
 	}
-	c.Client.AddToken(account.AccountKey(), base64.URLEncoding.EncodeToString(secretInfo.Token))
 	err = c.fetchRemoteCollections(ctx)
 	if err != nil {
-		log.Printf("Error fetching collections: %s", err)
+		c.log(ctx).Error("failed to fetch collections", "error", err)
 		return err
 	}
 	err = c.fetchRemoteFiles(ctx)
 	if err != nil {
-		log.Printf("Error fetching files: %s", err)
+		c.log(ctx).Error("failed to fetch files", "error", err)
 		return err
 	}
-	err = c.createLocalFolderForRemoteAlbums(ctx, account)
+	err = c.createLocalFolderForRemoteAlbums(ctx, target)
 	if err != nil {
-		log.Printf("Error creating local folders: %s", err)
+		c.log(ctx).Error("failed to create album folders", "error", err)
 		return err
 	}
-	err = c.syncFiles(ctx, account)
+	err = c.syncFiles(ctx, target, result)
 	if err != nil {
-		log.Printf("Error syncing files: %s", err)
+		c.log(ctx).Error("failed to sync files", "error", err)
 		return err
 	}
 	return nil
 }
 
+// loadAccount loads the secrets for the given account, creates its data buckets if required
+// and returns the context which should be used for all the requests made for the account.
+func (c *ClICtrl) loadAccount(ctx context.Context, account model.Account) (context.Context, error) {
+	secretInfo, err := c.KeyHolder.LoadSecrets(account)
+	if err != nil {
+		return nil, err
+	}
+	ctx = c.buildRequestContext(ctx, account)
+	err = createDataBuckets(c.DB, account)
+	if err != nil {
+		return nil, err
+	}
+	c.Client.AddToken(account.AccountKey(), base64.URLEncoding.EncodeToString(secretInfo.Token))
+	return ctx, nil
+}
+
+// newBandwidthLimiter returns the limiter for the bandwidth settings of an account, nil if they don't limit it
+func newBandwidthLimiter(config model.BandwidthConfig) (*bandwidth.Limiter, error) {
+	windows := make([]bandwidth.Window, 0, len(config.FullSpeedWindows))
+	for _, value := range config.FullSpeedWindows {
+		window, err := bandwidth.ParseWindow(value)
+		if err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	return bandwidth.NewLimiter(config.MaxBytesPerSec, windows), nil
+}
+
+// accountLabel returns the key of the account of the context, which identifies it in the metrics
+func accountLabel(ctx context.Context) string {
+	accountKey, _ := ctx.Value("account_key").(string)
+	return accountKey
+}
+
 func (c *ClICtrl) buildRequestContext(ctx context.Context, account model.Account) context.Context {
 	ctx = context.WithValue(ctx, "app", string(account.App))
 	ctx = context.WithValue(ctx, "account_key", account.AccountKey())
+	ctx = context.WithValue(ctx, "endpoints", account.Endpoints())
 	ctx = context.WithValue(ctx, "user_id", account.UserID)
+	ctx = context.WithValue(ctx, "email", account.Email)
+	ctx = logging.NewContext(ctx, c.log(ctx).With("app", account.App, "email", account.Email))
 	return ctx
 }
 
@@ -107,7 +231,7 @@ func createDataBuckets(db *bolt.DB, account model.Account) error {
 		if err != nil {
 			return fmt.Errorf("create bucket: %s", err)
 		}
-		for _, subBucket := range []model.PhotosStore{model.KVConfig, model.RemoteAlbums, model.RemoteFiles, model.RemoteAlbumEntries} {
+		for _, subBucket := range []model.PhotosStore{model.KVConfig, model.RemoteAlbums, model.RemoteFiles, model.RemoteAlbumEntries, model.ExportIndex} {
 			_, err := dataBucket.CreateBucketIfNotExists([]byte(subBucket))
 			if err != nil {
 				return err
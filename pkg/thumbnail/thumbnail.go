@@ -0,0 +1,160 @@
+// Package thumbnail generates the jpeg previews shown for files in the apps and the gallery
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxDimension is the size of the thumbnails uploaded with the files
+	MaxDimension = 720
+	quality      = 85
+	// ffmpegTimeout bounds the frame extraction, which can be slow for large videos on network drives
+	ffmpegTimeout = time.Minute
+)
+
+// ErrUnsupported is returned for files which can neither be decoded natively nor with ffmpeg
+var ErrUnsupported = errors.New("can't generate a thumbnail for this format")
+
+// Generate returns a jpeg thumbnail for the file at the given path, whose longer side is at most maxDimension.
+// For files which can not be decoded, a plain placeholder is returned.
+func Generate(ctx context.Context, filePath string, maxDimension int) ([]byte, error) {
+	data, err := FromFile(ctx, filePath, maxDimension)
+	if errors.Is(err, ErrUnsupported) {
+		return Placeholder()
+	}
+	return data, err
+}
+
+// FromFile returns a jpeg thumbnail for the image or video at the given path, whose longer side is at most
+// maxDimension. Videos, and images in formats like HEIC, are decoded with ffmpeg when it's installed.
+// ErrUnsupported is returned for the files which can't be decoded. Cancelling ctx stops ffmpeg.
+func FromFile(ctx context.Context, filePath string, maxDimension int) ([]byte, error) {
+	img, err := decodeImage(filePath)
+	if errors.Is(err, image.ErrFormat) {
+		img, err = extractFrame(ctx, filePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return encode(resizeImage(img, maxDimension))
+}
+
+// Placeholder returns a plain grey thumbnail, as the server expects every file to have one
+func Placeholder() ([]byte, error) {
+	return encode(placeholderThumbnail())
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeImage(filePath string) (image.Image, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
+// extractFrame decodes the first frame of a video, or an image in a format unknown to Go, with ffmpeg
+func extractFrame(ctx context.Context, filePath string) (image.Image, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("%w, ffmpeg isn't installed", ErrUnsupported)
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-nostdin", "-v", "error", "-i", filePath,
+		"-frames:v", "1", "-f", "image2pipe", "-c:v", "png", "-")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err = cmd.Run()
+	// an interrupted import stops instead of uploading the file with a placeholder
+	if parent.Err() != nil {
+		return nil, parent.Err()
+	}
+	if err != nil || stdout.Len() == 0 {
+		return nil, fmt.Errorf("%w, ffmpeg failed: %v %s", ErrUnsupported, err, strings.TrimSpace(stderr.String()))
+	}
+	return png.Decode(&stdout)
+}
+
+// resizeImage scales down the image so that the longer side is at most maxDimension,
+// averaging the source pixels covered by each target pixel.
+func resizeImage(src image.Image, maxDimension int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= maxDimension && height <= maxDimension {
+		return src
+	}
+	newWidth, newHeight := maxDimension, maxDimension
+	if width > height {
+		newHeight = height * maxDimension / width
+	} else {
+		newWidth = width * maxDimension / height
+	}
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0 := bounds.Min.Y + y*height/newHeight
+		y1 := bounds.Min.Y + (y+1)*height/newHeight
+		for x := 0; x < newWidth; x++ {
+			x0 := bounds.Min.X + x*width/newWidth
+			x1 := bounds.Min.X + (x+1)*width/newWidth
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					count++
+				}
+			}
+			if count == 0 {
+				continue
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+	return dst
+}
+
+func placeholderThumbnail() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	grey := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, grey)
+		}
+	}
+	return img
+}
@@ -0,0 +1,88 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFromFile(t *testing.T) {
+	dir := t.TempDir()
+	src := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 200; x++ {
+			src.Set(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	imagePath := filepath.Join(dir, "a.png")
+	if err := os.WriteFile(imagePath, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	data, err := FromFile(context.Background(), imagePath, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := thumb.Bounds().Size(); size.X != 50 || size.Y != 25 {
+		t.Errorf("expected a 50x25 thumbnail, got %v", size)
+	}
+
+	// without ffmpeg, the formats unknown to Go are reported instead of getting a placeholder
+	t.Setenv("PATH", "")
+	videoPath := filepath.Join(dir, "a.mp4")
+	if err = os.WriteFile(videoPath, []byte("not an image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = FromFile(context.Background(), videoPath, 50); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err = Generate(context.Background(), videoPath, 50); err != nil {
+		t.Errorf("expected the placeholder, got %v", err)
+	}
+}
+
+func TestFromFileCancelsFFmpeg(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as ffmpeg")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep isn't available")
+	}
+	dir := t.TempDir()
+	// an ffmpeg which hangs, like one reading a large video from a slow drive
+	if err = os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte("#!/bin/sh\nexec "+sleep+" 60\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	videoPath := filepath.Join(dir, "a.mp4")
+	if err = os.WriteFile(videoPath, []byte("not an image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := FromFile(ctx, videoPath, 50); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error of the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected ffmpeg to be stopped with the context, took %s", elapsed)
+	}
+}
@@ -0,0 +1,29 @@
+package encoding
+
+import "math/big"
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// EncodeBase58 encodes the given bytes using the bitcoin base58 alphabet.
+// This is used by ente clients for encoding the collection key in the public link fragment.
+func EncodeBase58(b []byte) string {
+	num := new(big.Int).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	result := make([]byte, 0, len(b)*138/100+1)
+	for num.Sign() > 0 {
+		num.DivMod(num, radix, mod)
+		result = append(result, base58Alphabet[mod.Int64()])
+	}
+	// each leading zero byte is encoded as the first character of the alphabet
+	for _, v := range b {
+		if v != 0 {
+			break
+		}
+		result = append(result, base58Alphabet[0])
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return string(result)
+}
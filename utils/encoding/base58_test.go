@@ -0,0 +1,16 @@
+package encoding
+
+import "testing"
+
+func TestEncodeBase58(t *testing.T) {
+	cases := map[string]string{
+		"":            "",
+		"hello world": "StV1DL6CwTryKyV",
+		"\x00\x00abc": "11ZiCa",
+	}
+	for input, expected := range cases {
+		if actual := EncodeBase58([]byte(input)); actual != expected {
+			t.Fatalf("EncodeBase58(%q) = %s, expected %s", input, actual, expected)
+		}
+	}
+}
@@ -2,13 +2,13 @@ package utils
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 )
 
 func TimeTrack(start time.Time, name string) {
 	elapsed := time.Since(start)
-	log.Printf("%s took %s", name, elapsed)
+	slog.Info("step completed", "step", name, "took", elapsed)
 }
 
 func ByteCountDecimal(b int64) string {